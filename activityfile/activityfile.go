@@ -0,0 +1,233 @@
+package activityfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kpi-studio/go-strava-api/fit"
+	"github.com/kpi-studio/go-strava-api/gpx"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/tcx"
+)
+
+// Format is an activity file format accepted by the upload endpoint
+type Format string
+
+const (
+	FormatFIT Format = "fit"
+	FormatTCX Format = "tcx"
+	FormatGPX Format = "gpx"
+)
+
+// sniffLength is how much of a file is searched for an XML root element
+const sniffLength = 4096
+
+// MaxDecompressedSize is the most Parse reads from gzip content, in bytes.
+// Activity files are at most a few tens of megabytes uncompressed, so the
+// limit only stops small archives that expand to exhaust memory.
+var MaxDecompressedSize int64 = 256 << 20
+
+var (
+	// ErrUnknownFormat is returned when the content is not FIT, TCX or GPX
+	ErrUnknownFormat = errors.New("activityfile: unknown file format")
+
+	// ErrNoPoints is returned for files without any track points
+	ErrNoPoints = errors.New("activityfile: file has no track points")
+
+	// ErrZeroLength is returned for tracks that cover no time and no distance
+	ErrZeroLength = errors.New("activityfile: track has zero length")
+
+	// ErrMissingTimestamps is returned when some or all points have no time
+	ErrMissingTimestamps = errors.New("activityfile: points are missing timestamps")
+
+	// ErrTimeOrder is returned when point times go backwards
+	ErrTimeOrder = errors.New("activityfile: time is not increasing")
+
+	// ErrDataType is returned when an upload's data type does not match the file
+	ErrDataType = errors.New("activityfile: data type does not match file")
+
+	// ErrTooLarge is returned for gzip content larger than MaxDecompressedSize
+	ErrTooLarge = errors.New("activityfile: file is too large")
+)
+
+// File is a parsed activity file
+type File struct {
+	Format     Format
+	Compressed bool
+
+	// Activity holds the summary read or computed from the file
+	Activity *models.Activity
+	Streams  *models.StreamSet
+
+	// ExternalID is a hash of the uncompressed content, suitable for
+	// UploadOptions.ExternalID to detect duplicate uploads
+	ExternalID string
+
+	// Data is the file's content as read, compressed or not
+	Data []byte
+}
+
+// DataType returns the upload data type of the file, such as "gpx.gz"
+func (f *File) DataType() string {
+	if f.Compressed {
+		return string(f.Format) + ".gz"
+	}
+	return string(f.Format)
+}
+
+// Sniff detects the format of uncompressed file content
+func Sniff(data []byte) (Format, error) {
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return FormatFIT, nil
+	}
+
+	head := data
+	if len(head) > sniffLength {
+		head = head[:sniffLength]
+	}
+	switch {
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return FormatTCX, nil
+	case bytes.Contains(head, []byte("<gpx")):
+		return FormatGPX, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// IsGzip reports whether data starts with the gzip magic number
+func IsGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// ExternalID returns a hash of uncompressed file content for deduplicating
+// uploads. Compressed and uncompressed copies of a file get the same ID.
+func ExternalID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Parse reads an activity file, decompressing gzip content up to
+// MaxDecompressedSize and detecting the format from the content rather than
+// the file name
+func Parse(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Data: data, Compressed: IsGzip(data)}
+	content := data
+	if f.Compressed {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("activityfile: %w", err)
+		}
+		if content, err = io.ReadAll(io.LimitReader(zr, MaxDecompressedSize+1)); err != nil {
+			return nil, fmt.Errorf("activityfile: %w", err)
+		}
+		if int64(len(content)) > MaxDecompressedSize {
+			return nil, fmt.Errorf("%w: more than %d bytes uncompressed", ErrTooLarge, MaxDecompressedSize)
+		}
+	}
+
+	if f.Format, err = Sniff(content); err != nil {
+		return nil, err
+	}
+	f.ExternalID = ExternalID(content)
+
+	switch f.Format {
+	case FormatFIT:
+		f.Activity, f.Streams, err = fit.Parse(bytes.NewReader(content))
+	case FormatTCX:
+		f.Activity, f.Streams, err = tcx.Parse(bytes.NewReader(content))
+	case FormatGPX:
+		f.Activity, f.Streams, err = gpx.Parse(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Activity.ExternalID = f.ExternalID
+	return f, nil
+}
+
+// ParseFile reads and parses the activity file at path
+func ParseFile(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Validate checks the problems that make Strava reject an upload: no points,
+// a track with zero length, missing timestamps and time going backwards. All
+// problems found are returned together.
+func (f *File) Validate() error {
+	n := streamLength(f.Streams)
+	if n == 0 {
+		return ErrNoPoints
+	}
+
+	var errs []error
+	if f.Streams.Time == nil {
+		errs = append(errs, ErrMissingTimestamps)
+	} else {
+		ts := f.Streams.Time.Data
+		for i := 1; i < len(ts); i++ {
+			if ts[i] < ts[i-1] {
+				errs = append(errs, fmt.Errorf("%w at point %d", ErrTimeOrder, i))
+				break
+			}
+		}
+	}
+	if n < 2 || (f.Activity.ElapsedTime <= 0 && f.Activity.Distance <= 0) {
+		errs = append(errs, ErrZeroLength)
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckUpload validates the file and checks that the upload options match
+// it. An empty DataType is accepted; use DataType to fill it in.
+func (f *File) CheckUpload(opts *models.UploadOptions) error {
+	errs := []error{f.Validate()}
+	if opts != nil && opts.DataType != "" && opts.DataType != f.DataType() {
+		errs = append(errs, fmt.Errorf("%w: upload has %q, file is %q", ErrDataType, opts.DataType, f.DataType()))
+	}
+	return errors.Join(errs...)
+}
+
+// UploadOptions returns upload options for the file, with its content, data
+// type, external ID and the activity name and type read from it
+func (f *File) UploadOptions() *models.UploadOptions {
+	return &models.UploadOptions{
+		File:         bytes.NewReader(f.Data),
+		Name:         f.Activity.Name,
+		ActivityType: f.Activity.Type,
+		DataType:     f.DataType(),
+		ExternalID:   f.ExternalID,
+	}
+}
+
+// streamLength returns the number of points in the streams
+func streamLength(streams *models.StreamSet) int {
+	switch {
+	case streams == nil:
+		return 0
+	case streams.Time != nil:
+		return len(streams.Time.Data)
+	case streams.LatLng != nil:
+		return len(streams.LatLng.Data)
+	case streams.Distance != nil:
+		return len(streams.Distance.Data)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,275 @@
+package activityfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// sample returns a short run with the streams every format can hold
+func sample() (*models.Activity, *models.StreamSet) {
+	activity := &models.Activity{
+		Name:           "Lunch Run",
+		Type:           models.ActivityTypeRun,
+		StartDate:      time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC),
+		StartDateLocal: time.Date(2024, 5, 4, 14, 0, 0, 0, time.UTC),
+	}
+	streams := &models.StreamSet{
+		Time:      &models.TimeStream{},
+		LatLng:    &models.LatLngStream{},
+		Distance:  &models.DistanceStream{},
+		Altitude:  &models.AltitudeStream{},
+		Heartrate: &models.HeartrateStream{},
+	}
+	for i := 0; i < 20; i++ {
+		streams.Time.Data = append(streams.Time.Data, i*5)
+		streams.LatLng.Data = append(streams.LatLng.Data, []float64{51.5 + float64(i)*0.0001, -0.1})
+		streams.Distance.Data = append(streams.Distance.Data, float64(i)*11.1)
+		streams.Altitude.Data = append(streams.Altitude.Data, 20+float64(i%5))
+		streams.Heartrate.Data = append(streams.Heartrate.Data, 140+i)
+	}
+	return activity, streams
+}
+
+// encode returns the sample run in a format
+func encode(t *testing.T, format Format) []byte {
+	t.Helper()
+	activity, streams := sample()
+	f, err := New(format, activity, streams)
+	if err != nil {
+		t.Fatalf("New(%s): %v", format, err)
+	}
+	return f.Data
+}
+
+// gzipped compresses data
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Format
+		err  error
+	}{
+		{"fit", encode(t, FormatFIT), FormatFIT, nil},
+		{"tcx", encode(t, FormatTCX), FormatTCX, nil},
+		{"gpx", encode(t, FormatGPX), FormatGPX, nil},
+		{"truncated fit header", encode(t, FormatFIT)[:10], "", ErrUnknownFormat},
+		{"gzipped", gzipped(t, encode(t, FormatGPX)), "", ErrUnknownFormat},
+		{"html", []byte("<html><body>gpx</body></html>"), "", ErrUnknownFormat},
+		{"empty", nil, "", ErrUnknownFormat},
+		{"root after the sniffed head", []byte("<?xml version=\"1.0\"?><!--" + strings.Repeat(" ", sniffLength) + "--><gpx>"), "", ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sniff(tt.data)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("Sniff = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, format := range []Format{FormatFIT, FormatTCX, FormatGPX} {
+		plain := encode(t, format)
+		compressed := gzipped(t, plain)
+
+		tests := []struct {
+			name       string
+			data       []byte
+			compressed bool
+			dataType   string
+		}{
+			{"plain", plain, false, string(format)},
+			{"gzipped", compressed, true, string(format) + ".gz"},
+		}
+		for _, tt := range tests {
+			t.Run(string(format)+" "+tt.name, func(t *testing.T) {
+				f, err := Parse(bytes.NewReader(tt.data))
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if f.Format != format || f.Compressed != tt.compressed || f.DataType() != tt.dataType {
+					t.Errorf("file = %s compressed %v data type %q, want %s %v %q",
+						f.Format, f.Compressed, f.DataType(), format, tt.compressed, tt.dataType)
+				}
+				if !bytes.Equal(f.Data, tt.data) {
+					t.Error("Data is not the content as read")
+				}
+
+				// Compressed and uncompressed copies share an ID
+				if want := ExternalID(plain); f.ExternalID != want || f.Activity.ExternalID != want {
+					t.Errorf("external ID = %q, activity %q, want %q", f.ExternalID, f.Activity.ExternalID, want)
+				}
+				if n := len(f.Streams.Time.Data); n != 20 {
+					t.Errorf("%d points, want 20", n)
+				}
+				if err := f.Validate(); err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	fit := encode(t, FormatFIT)
+	gpx := encode(t, FormatGPX)
+	compressed := gzipped(t, gpx)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"truncated fit", fit[:len(fit)/2], nil},
+		{"truncated gpx", gpx[:len(gpx)/2], nil},
+		{"truncated gzip", compressed[:len(compressed)/2], nil},
+		{"gzipped unknown format", gzipped(t, []byte("hello")), ErrUnknownFormat},
+		{"unknown format", []byte("hello"), ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(bytes.NewReader(tt.data))
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("Parse = %v, want an error matching %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseSizeLimit(t *testing.T) {
+	defer func(size int64) { MaxDecompressedSize = size }(MaxDecompressedSize)
+
+	gpx := encode(t, FormatGPX)
+	compressed := gzipped(t, gpx)
+
+	tests := []struct {
+		name string
+		max  int64
+		err  error
+	}{
+		{"under the limit", int64(len(gpx)) + 1, nil},
+		{"at the limit", int64(len(gpx)), nil},
+		{"over the limit", int64(len(gpx)) - 1, ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MaxDecompressedSize = tt.max
+			if _, err := Parse(bytes.NewReader(compressed)); !errors.Is(err, tt.err) {
+				t.Errorf("Parse = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// Uncompressed files are not limited
+	MaxDecompressedSize = 1
+	if _, err := Parse(bytes.NewReader(gpx)); err != nil {
+		t.Errorf("Parse uncompressed = %v, want no error", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *models.Activity, s *models.StreamSet) *models.StreamSet
+		want   []error
+	}{
+		{"valid", func(a *models.Activity, s *models.StreamSet) *models.StreamSet { return s }, nil},
+		{"no streams", func(a *models.Activity, s *models.StreamSet) *models.StreamSet { return nil }, []error{ErrNoPoints}},
+		{"no points", func(a *models.Activity, s *models.StreamSet) *models.StreamSet { return &models.StreamSet{} }, []error{ErrNoPoints}},
+		{"no timestamps", func(a *models.Activity, s *models.StreamSet) *models.StreamSet {
+			s.Time = nil
+			return s
+		}, []error{ErrMissingTimestamps}},
+		{"time goes backwards", func(a *models.Activity, s *models.StreamSet) *models.StreamSet {
+			s.Time.Data[5] = 0
+			return s
+		}, []error{ErrTimeOrder}},
+		{"one point", func(a *models.Activity, s *models.StreamSet) *models.StreamSet {
+			return &models.StreamSet{Time: &models.TimeStream{Data: []int{0}}}
+		}, []error{ErrZeroLength}},
+		{"zero length", func(a *models.Activity, s *models.StreamSet) *models.StreamSet {
+			a.ElapsedTime, a.Distance = 0, 0
+			return s
+		}, []error{ErrZeroLength}},
+		{"every problem", func(a *models.Activity, s *models.StreamSet) *models.StreamSet {
+			return &models.StreamSet{Distance: &models.DistanceStream{Data: []float64{0}}}
+		}, []error{ErrMissingTimestamps, ErrZeroLength}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activity, streams := sample()
+			activity.ElapsedTime, activity.Distance = 95, 210.9
+			f := &File{Format: FormatGPX, Activity: activity, Streams: tt.modify(activity, streams)}
+			checkErrors(t, "Validate", f.Validate(), tt.want)
+		})
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	compressed, err := Parse(bytes.NewReader(gzipped(t, encode(t, FormatTCX))))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   *models.UploadOptions
+		modify func(f *File)
+		want   []error
+	}{
+		{"no options", nil, nil, nil},
+		{"own options", compressed.UploadOptions(), nil, nil},
+		{"empty data type", &models.UploadOptions{}, nil, nil},
+		{"uncompressed data type", &models.UploadOptions{DataType: "tcx"}, nil, []error{ErrDataType}},
+		{"other format", &models.UploadOptions{DataType: "gpx.gz"}, nil, []error{ErrDataType}},
+		{"invalid file", &models.UploadOptions{DataType: "fit"}, func(f *File) { f.Streams = nil }, []error{ErrNoPoints, ErrDataType}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := *compressed
+			if tt.modify != nil {
+				tt.modify(&f)
+			}
+			checkErrors(t, "CheckUpload", f.CheckUpload(tt.opts), tt.want)
+		})
+	}
+}
+
+// checkErrors checks that err matches every wanted error, or is nil when
+// none are wanted
+func checkErrors(t *testing.T, name string, err error, want []error) {
+	t.Helper()
+	if len(want) == 0 && err != nil {
+		t.Errorf("%s = %v, want no error", name, err)
+	}
+	for _, w := range want {
+		if !errors.Is(err, w) {
+			t.Errorf("%s = %v, want %v", name, err, w)
+		}
+	}
+}
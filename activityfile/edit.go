@@ -0,0 +1,539 @@
+package activityfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/fit"
+	"github.com/kpi-studio/go-strava-api/geo"
+	"github.com/kpi-studio/go-strava-api/gpx"
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/services"
+	"github.com/kpi-studio/go-strava-api/tcx"
+)
+
+var (
+	// ErrNoDistance is returned when editing by distance a file without distances
+	ErrNoDistance = errors.New("activityfile: points have no distance")
+
+	// ErrOutOfRange is returned when an edit would leave a file without points
+	ErrOutOfRange = errors.New("activityfile: edit is outside the track")
+)
+
+// MergeOptions contains options for merging two recordings
+type MergeOptions struct {
+	// CloseGap removes the time between the recordings, so the second one
+	// continues right after the first instead of after a pause
+	CloseGap bool
+}
+
+// New encodes an activity and its streams as a file of the given format,
+// ready to upload. Use it for streams fetched from the API or edited by hand.
+// FIT and TCX need a time stream and GPX needs a latlng stream.
+func New(format Format, activity *models.Activity, streams *models.StreamSet) (*File, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatFIT:
+		err = fit.WriteActivity(&buf, activity, streams)
+	case FormatTCX:
+		err = tcx.Write(&buf, activity, streams, nil)
+	case FormatGPX:
+		err = gpx.Write(&buf, activity, streams, nil)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a := *activity
+	a.ExternalID = ExternalID(buf.Bytes())
+	return &File{
+		Format:     format,
+		Activity:   &a,
+		Streams:    streams,
+		ExternalID: a.ExternalID,
+		Data:       buf.Bytes(),
+	}, nil
+}
+
+// TrimTime returns a copy of the file without the first start and the last
+// end of its recording
+func (f *File) TrimTime(start, end time.Duration) (*File, error) {
+	ts, err := f.times()
+	if err != nil {
+		return nil, err
+	}
+	last := ts[len(ts)-1]
+	keep := make([]bool, len(ts))
+	for i, t := range ts {
+		keep[i] = float64(t-ts[0]) >= start.Seconds() && float64(last-t) >= end.Seconds()
+	}
+	return f.edit(keep)
+}
+
+// TrimDistance returns a copy of the file without the first start and the
+// last end meters of its recording
+func (f *File) TrimDistance(start, end float64) (*File, error) {
+	ds, err := f.distances()
+	if err != nil {
+		return nil, err
+	}
+	last := ds[len(ds)-1]
+	keep := make([]bool, len(ds))
+	for i, d := range ds {
+		keep[i] = d-ds[0] >= start && last-d >= end
+	}
+	return f.edit(keep)
+}
+
+// CropTime returns a copy of the file without the section recorded from
+// from to to after the start. Later points keep their times, so the section
+// becomes a pause, and distance continues from before the section.
+func (f *File) CropTime(from, to time.Duration) (*File, error) {
+	ts, err := f.times()
+	if err != nil {
+		return nil, err
+	}
+	keep := make([]bool, len(ts))
+	for i, t := range ts {
+		offset := float64(t - ts[0])
+		keep[i] = offset < from.Seconds() || offset >= to.Seconds()
+	}
+	return f.edit(keep)
+}
+
+// CropDistance returns a copy of the file without the section from from to
+// to meters into the recording. Later points keep their times, so the section
+// becomes a pause, and distance continues from before the section.
+func (f *File) CropDistance(from, to float64) (*File, error) {
+	ds, err := f.distances()
+	if err != nil {
+		return nil, err
+	}
+	keep := make([]bool, len(ds))
+	for i, d := range ds {
+		keep[i] = d-ds[0] < from || d-ds[0] >= to
+	}
+	return f.edit(keep)
+}
+
+// SplitAt splits the file into the points recorded before at and the points
+// recorded from at on. Both files keep the original format and name.
+func (f *File) SplitAt(at time.Time) (*File, *File, error) {
+	ts, err := f.times()
+	if err != nil {
+		return nil, nil, err
+	}
+	offset := at.Sub(f.Activity.StartDate).Seconds()
+	before := make([]bool, len(ts))
+	after := make([]bool, len(ts))
+	for i, t := range ts {
+		before[i] = float64(t) < offset
+		after[i] = !before[i]
+	}
+
+	first, err := f.edit(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	second, err := f.edit(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	return first, second, nil
+}
+
+// Merge joins two recordings of one activity, such as a workout recorded on
+// two devices or after restarting a watch, in the format of the earlier one.
+// Points of the later recording that overlap the earlier one are dropped and
+// the time between them is kept as a pause unless opts.CloseGap is set; with
+// the gap kept, the straight-line distance between the recordings is added.
+// Only streams present in both recordings are kept, and each recording keeps
+// its laps or becomes one lap.
+func Merge(a, b *File, opts *MergeOptions) (*File, error) {
+	if b.Activity.StartDate.Before(a.Activity.StartDate) {
+		a, b = b, a
+	}
+	ta, err := a.times()
+	if err != nil {
+		return nil, err
+	}
+	tb, err := b.times()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the later recording's points that overlap the earlier one
+	offset := int(b.Activity.StartDate.Sub(a.Activity.StartDate).Seconds())
+	end := ta[len(ta)-1]
+	keep := make([]bool, len(tb))
+	for i, t := range tb {
+		keep[i] = offset+t > end
+	}
+	second, secondStreams, err := edit(b.Activity, b.Streams, keep)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the recordings overlap completely", err)
+	}
+	first := copyActivity(a.Activity)
+	firstStreams := a.Streams
+	withLap(first, len(ta))
+	withLap(second, len(secondStreams.Time.Data))
+
+	gap := int(second.StartDate.Sub(first.StartDate).Seconds()) - end
+	var bridge float64
+	if opts != nil && opts.CloseGap {
+		gap = 1
+	} else if firstStreams.LatLng != nil && secondStreams.LatLng != nil {
+		p, okP := models.LatLngFromSlice(firstStreams.LatLng.Data[len(ta)-1])
+		q, okQ := models.LatLngFromSlice(secondStreams.LatLng.Data[0])
+		if okP && okQ {
+			bridge = geo.Distance(p, q)
+		}
+	}
+
+	// Shift the later recording to follow the earlier one
+	secondStreams.Time.Data = shiftInts(secondStreams.Time.Data, end+gap)
+	if firstStreams.Distance != nil && secondStreams.Distance != nil {
+		ds := firstStreams.Distance.Data
+		secondStreams.Distance.Data = shiftFloats(secondStreams.Distance.Data, ds[len(ds)-1]+bridge)
+	}
+
+	streams := concatStreams(firstStreams, secondStreams)
+	merged := copyActivity(first)
+	merged.Laps = append(merged.Laps, second.Laps...)
+	for k := len(first.Laps); k < len(merged.Laps); k++ {
+		merged.Laps[k].StartIndex += len(ta)
+		merged.Laps[k].EndIndex += len(ta)
+	}
+	summarize(merged, streams, float64(gap-1))
+	merged.Calories = first.Calories + second.Calories
+
+	return New(a.Format, merged, streams)
+}
+
+// Replace uploads the file as a replacement for an activity, waits for Strava
+// to process it and then deletes the original. The original is kept when the
+// upload fails.
+func Replace(ctx context.Context, uploads *services.UploadsService, activities *services.ActivitiesService, originalID int64, f *File) (*models.Upload, error) {
+	upload, err := uploads.Upload(ctx, f.UploadOptions())
+	if err != nil {
+		return upload, err
+	}
+	if upload, err = uploads.WaitForUpload(ctx, upload.ID, 0); err != nil {
+		return upload, err
+	}
+	return upload, activities.Delete(ctx, originalID)
+}
+
+// times returns the file's time stream
+func (f *File) times() ([]int, error) {
+	if streamLength(f.Streams) == 0 {
+		return nil, ErrNoPoints
+	}
+	if f.Streams.Time == nil {
+		return nil, ErrMissingTimestamps
+	}
+	return f.Streams.Time.Data, nil
+}
+
+// distances returns the file's distance stream
+func (f *File) distances() ([]float64, error) {
+	if streamLength(f.Streams) == 0 {
+		return nil, ErrNoPoints
+	}
+	if f.Streams.Distance == nil {
+		return nil, ErrNoDistance
+	}
+	return f.Streams.Distance.Data, nil
+}
+
+// edit returns a copy of the file with only the kept points, in its format
+func (f *File) edit(keep []bool) (*File, error) {
+	activity, streams, err := edit(f.Activity, f.Streams, keep)
+	if err != nil {
+		return nil, err
+	}
+	return New(f.Format, activity, streams)
+}
+
+// edit returns copies of the activity and streams with only the kept points.
+// Time and distance restart from zero at the first kept point and do not
+// advance over removed points, and the start date moves to the first kept
+// point. Laps without kept points are dropped and the summary is recomputed.
+func edit(activity *models.Activity, streams *models.StreamSet, keep []bool) (*models.Activity, *models.StreamSet, error) {
+	var first, kept int
+	index := make([]int, len(keep)) // new index of each point
+	for i, k := range keep {
+		if !k {
+			continue
+		}
+		if kept == 0 {
+			first = i
+		}
+		index[i] = kept
+		kept++
+	}
+	if kept == 0 {
+		return nil, nil, ErrOutOfRange
+	}
+
+	edited := selectStreams(streams, keep)
+	a := copyActivity(activity)
+	a.Laps = nil
+
+	var pause float64
+	if edited.Time != nil {
+		ts := streams.Time.Data
+		a.StartDate = activity.StartDate.Add(time.Duration(ts[first]) * time.Second)
+		a.StartDateLocal = activity.StartDateLocal.Add(time.Duration(ts[first]) * time.Second)
+		edited.Time.Data = shiftInts(edited.Time.Data, -ts[first])
+		for i, prev := first+1, first; i < len(keep); i++ {
+			if !keep[i] {
+				continue
+			}
+			if prev != i-1 {
+				pause += float64(ts[i] - ts[prev])
+			}
+			prev = i
+		}
+	}
+	if edited.Distance != nil {
+		ds := streams.Distance.Data
+		data := edited.Distance.Data
+		data[0] = 0
+		for i, prev := first+1, first; i < len(keep); i++ {
+			if !keep[i] {
+				continue
+			}
+			data[index[i]] = data[index[prev]]
+			if prev == i-1 {
+				data[index[i]] += ds[i] - ds[prev]
+			}
+			prev = i
+		}
+	}
+
+	for k, lap := range activity.Laps {
+		next := len(keep)
+		if k+1 < len(activity.Laps) {
+			next = activity.Laps[k+1].StartIndex
+		}
+		start, end := -1, -1
+		for i := lap.StartIndex; i < next && i < len(keep); i++ {
+			if keep[i] {
+				if start < 0 {
+					start = index[i]
+				}
+				end = index[i]
+			}
+		}
+		if start < 0 {
+			continue
+		}
+		a.Laps = append(a.Laps, models.Lap{StartIndex: start, EndIndex: end})
+	}
+	if len(a.Laps) > 0 {
+		a.Laps[0].StartIndex = 0
+	}
+
+	summarize(a, edited, pause)
+	if activity.MovingTime > 0 {
+		a.Calories = activity.Calories * a.MovingTime / activity.MovingTime
+	}
+	return a, edited, nil
+}
+
+// copyActivity returns a copy of an activity that can be edited without
+// changing the original, dropping the map and ID of the recorded activity
+func copyActivity(activity *models.Activity) *models.Activity {
+	a := *activity
+	a.ID = 0
+	a.Map = nil
+	a.Laps = append([]models.Lap(nil), activity.Laps...)
+	return &a
+}
+
+// withLap gives an activity without laps a single lap over its n points
+func withLap(activity *models.Activity, n int) {
+	if len(activity.Laps) == 0 {
+		activity.Laps = []models.Lap{{EndIndex: n - 1}}
+	}
+}
+
+// summarize recomputes the activity's summary from edited streams. pause is
+// time in seconds removed from the moving time. Laps are numbered and dated
+// from the time stream.
+func summarize(activity *models.Activity, streams *models.StreamSet, pause float64) {
+	activity.ElapsedTime, activity.MovingTime, activity.Distance = 0, 0, 0
+	activity.AverageSpeed, activity.MaxSpeed, activity.TotalElevationGain = 0, 0, 0
+	activity.StartLatlng, activity.EndLatlng = nil, nil
+	activity.HasHeartrate, activity.AverageHeartrate, activity.MaxHeartrate = false, 0, 0
+	activity.AverageCadence = 0
+	activity.DeviceWatts, activity.AverageWatts, activity.MaxWatts = false, 0, 0
+	activity.AverageTemp = 0
+	track.Summarize(activity, streams)
+	if pause > 0 && activity.MovingTime > pause {
+		activity.MovingTime -= pause
+	}
+
+	for k := range activity.Laps {
+		lap := &activity.Laps[k]
+		lap.Name = fmt.Sprintf("Lap %d", k+1)
+		lap.LapIndex, lap.Split = k+1, k+1
+		if streams.Time != nil && lap.StartIndex < len(streams.Time.Data) {
+			offset := time.Duration(streams.Time.Data[lap.StartIndex]) * time.Second
+			lap.StartDate = activity.StartDate.Add(offset)
+			lap.StartDateLocal = activity.StartDateLocal.Add(offset)
+		}
+	}
+}
+
+// shiftInts returns the values plus delta
+func shiftInts(values []int, delta int) []int {
+	out := make([]int, len(values))
+	for i, v := range values {
+		out[i] = v + delta
+	}
+	return out
+}
+
+// shiftFloats returns the values plus delta
+func shiftFloats(values []float64, delta float64) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = v + delta
+	}
+	return out
+}
+
+// selectStreams returns a copy of the streams with only the kept points
+func selectStreams(streams *models.StreamSet, keep []bool) *models.StreamSet {
+	return &models.StreamSet{
+		Time:           selectStream(streams.Time, keep, timeFields),
+		Distance:       selectStream(streams.Distance, keep, distanceFields),
+		LatLng:         selectStream(streams.LatLng, keep, latlngFields),
+		Altitude:       selectStream(streams.Altitude, keep, altitudeFields),
+		VelocitySmooth: selectStream(streams.VelocitySmooth, keep, velocityFields),
+		Heartrate:      selectStream(streams.Heartrate, keep, heartrateFields),
+		Cadence:        selectStream(streams.Cadence, keep, cadenceFields),
+		Watts:          selectStream(streams.Watts, keep, wattsFields),
+		Temperature:    selectStream(streams.Temperature, keep, temperatureFields),
+		Moving:         selectStream(streams.Moving, keep, movingFields),
+		GradeSmooth:    selectStream(streams.GradeSmooth, keep, gradeFields),
+	}
+}
+
+// concatStreams returns the streams of a followed by those of b, keeping
+// only the streams both have
+func concatStreams(a, b *models.StreamSet) *models.StreamSet {
+	return &models.StreamSet{
+		Time:           concatStream(a.Time, b.Time, timeFields),
+		Distance:       concatStream(a.Distance, b.Distance, distanceFields),
+		LatLng:         concatStream(a.LatLng, b.LatLng, latlngFields),
+		Altitude:       concatStream(a.Altitude, b.Altitude, altitudeFields),
+		VelocitySmooth: concatStream(a.VelocitySmooth, b.VelocitySmooth, velocityFields),
+		Heartrate:      concatStream(a.Heartrate, b.Heartrate, heartrateFields),
+		Cadence:        concatStream(a.Cadence, b.Cadence, cadenceFields),
+		Watts:          concatStream(a.Watts, b.Watts, wattsFields),
+		Temperature:    concatStream(a.Temperature, b.Temperature, temperatureFields),
+		Moving:         concatStream(a.Moving, b.Moving, movingFields),
+		GradeSmooth:    concatStream(a.GradeSmooth, b.GradeSmooth, gradeFields),
+	}
+}
+
+// selectStream returns a copy of a stream with only the kept points, or nil
+// when the stream is missing or not aligned with keep
+func selectStream[S, T any](s *S, keep []bool, fields func(*S) (*models.BaseStream, *[]T)) *S {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	base, data := fields(&c)
+	if len(*data) != len(keep) {
+		return nil
+	}
+	out := make([]T, 0, len(*data))
+	for i, v := range *data {
+		if keep[i] {
+			out = append(out, v)
+		}
+	}
+	*data = out
+	base.OriginalSize = len(out)
+	return &c
+}
+
+// concatStream returns a copy of stream a followed by the points of b, or nil
+// when either is missing
+func concatStream[S, T any](a, b *S, fields func(*S) (*models.BaseStream, *[]T)) *S {
+	if a == nil || b == nil {
+		return nil
+	}
+	c := *a
+	base, data := fields(&c)
+	_, other := fields(b)
+	*data = append(append(make([]T, 0, len(*data)+len(*other)), *data...), *other...)
+	base.OriginalSize = len(*data)
+	return &c
+}
+
+// timeFields returns the fields of a time stream
+func timeFields(s *models.TimeStream) (*models.BaseStream, *[]int) {
+	return &s.BaseStream, &s.Data
+}
+
+// distanceFields returns the fields of a distance stream
+func distanceFields(s *models.DistanceStream) (*models.BaseStream, *[]float64) {
+	return &s.BaseStream, &s.Data
+}
+
+// latlngFields returns the fields of a latlng stream
+func latlngFields(s *models.LatLngStream) (*models.BaseStream, *[][]float64) {
+	return &s.BaseStream, &s.Data
+}
+
+// altitudeFields returns the fields of an altitude stream
+func altitudeFields(s *models.AltitudeStream) (*models.BaseStream, *[]float64) {
+	return &s.BaseStream, &s.Data
+}
+
+// velocityFields returns the fields of a velocity stream
+func velocityFields(s *models.VelocityStream) (*models.BaseStream, *[]float64) {
+	return &s.BaseStream, &s.Data
+}
+
+// heartrateFields returns the fields of a heartrate stream
+func heartrateFields(s *models.HeartrateStream) (*models.BaseStream, *[]int) {
+	return &s.BaseStream, &s.Data
+}
+
+// cadenceFields returns the fields of a cadence stream
+func cadenceFields(s *models.CadenceStream) (*models.BaseStream, *[]int) {
+	return &s.BaseStream, &s.Data
+}
+
+// wattsFields returns the fields of a watts stream
+func wattsFields(s *models.PowerStream) (*models.BaseStream, *[]int) {
+	return &s.BaseStream, &s.Data
+}
+
+// temperatureFields returns the fields of a temperature stream
+func temperatureFields(s *models.TemperatureStream) (*models.BaseStream, *[]int) {
+	return &s.BaseStream, &s.Data
+}
+
+// movingFields returns the fields of a moving stream
+func movingFields(s *models.MovingStream) (*models.BaseStream, *[]bool) {
+	return &s.BaseStream, &s.Data
+}
+
+// gradeFields returns the fields of a grade stream
+func gradeFields(s *models.GradeStream) (*models.BaseStream, *[]float64) {
+	return &s.BaseStream, &s.Data
+}
@@ -0,0 +1,373 @@
+package activityfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/geo"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/services"
+)
+
+// lapFile returns the sample run as a TCX file starting offset after the
+// sample's start, with laps starting at points 0 and 10. Points are 5 s and
+// 11.1 m apart.
+func lapFile(t *testing.T, offset time.Duration) *File {
+	t.Helper()
+	activity, streams := sample()
+	activity.StartDate = activity.StartDate.Add(offset)
+	activity.StartDateLocal = activity.StartDateLocal.Add(offset)
+	activity.Laps = []models.Lap{{StartIndex: 0, EndIndex: 9}, {StartIndex: 10, EndIndex: 19}}
+	f, err := New(FormatTCX, activity, streams)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return f
+}
+
+// lapBounds returns the start and end index of each lap
+func lapBounds(a *models.Activity) [][2]int {
+	var bounds [][2]int
+	for _, lap := range a.Laps {
+		bounds = append(bounds, [2]int{lap.StartIndex, lap.EndIndex})
+	}
+	return bounds
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(f *File) (*File, error)
+		err      error
+		points   int
+		start    time.Duration // new start after the original
+		time     []int         // first and last time
+		distance []float64     // first and last distance
+		laps     [][2]int
+	}{
+		{
+			name:   "trim nothing",
+			edit:   func(f *File) (*File, error) { return f.TrimTime(0, 0) },
+			points: 20, time: []int{0, 95}, distance: []float64{0, 210.9},
+			laps: [][2]int{{0, 9}, {10, 19}},
+		},
+		{
+			name:   "trim time",
+			edit:   func(f *File) (*File, error) { return f.TrimTime(10*time.Second, 20*time.Second) },
+			points: 14, start: 10 * time.Second, time: []int{0, 65}, distance: []float64{0, 144.3},
+			laps: [][2]int{{0, 7}, {8, 13}},
+		},
+		{
+			name:   "trim the first lap",
+			edit:   func(f *File) (*File, error) { return f.TrimTime(50*time.Second, 0) },
+			points: 10, start: 50 * time.Second, time: []int{0, 45}, distance: []float64{0, 99.9},
+			laps: [][2]int{{0, 9}},
+		},
+		{
+			name:   "trim distance",
+			edit:   func(f *File) (*File, error) { return f.TrimDistance(33.3, 11.1) },
+			points: 16, start: 15 * time.Second, time: []int{0, 75}, distance: []float64{0, 166.5},
+			laps: [][2]int{{0, 6}, {7, 15}},
+		},
+		{
+			name: "trim everything",
+			edit: func(f *File) (*File, error) { return f.TrimTime(50*time.Second, 50*time.Second) },
+			err:  ErrOutOfRange,
+		},
+		{
+			name: "trim past the end",
+			edit: func(f *File) (*File, error) { return f.TrimDistance(1000, 0) },
+			err:  ErrOutOfRange,
+		},
+		{
+			// Points at 20, 25, 30 and 35 s go; later ones keep their times
+			// and distance continues from 33.3 m
+			name:   "crop time",
+			edit:   func(f *File) (*File, error) { return f.CropTime(20*time.Second, 40*time.Second) },
+			points: 16, time: []int{0, 95}, distance: []float64{0, 155.4},
+			laps: [][2]int{{0, 5}, {6, 15}},
+		},
+		{
+			name:   "crop distance from the start",
+			edit:   func(f *File) (*File, error) { return f.CropDistance(0, 90) },
+			points: 11, start: 45 * time.Second, time: []int{0, 50}, distance: []float64{0, 111},
+			laps: [][2]int{{0, 0}, {1, 10}},
+		},
+		{
+			name: "crop everything",
+			edit: func(f *File) (*File, error) { return f.CropDistance(0, 1000) },
+			err:  ErrOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := lapFile(t, 0)
+			got, err := tt.edit(f)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			ts, ds := got.Streams.Time.Data, got.Streams.Distance.Data
+			if len(ts) != tt.points || len(ds) != tt.points {
+				t.Fatalf("%d points, want %d", len(ts), tt.points)
+			}
+			if want := f.Activity.StartDate.Add(tt.start); !got.Activity.StartDate.Equal(want) {
+				t.Errorf("start date = %v, want %v", got.Activity.StartDate, want)
+			}
+			if first, last := ts[0], ts[len(ts)-1]; first != tt.time[0] || last != tt.time[1] {
+				t.Errorf("time = %d to %d, want %d to %d", first, last, tt.time[0], tt.time[1])
+			}
+			if first, last := ds[0], ds[len(ds)-1]; math.Abs(first-tt.distance[0]) > 1e-9 || math.Abs(last-tt.distance[1]) > 1e-9 {
+				t.Errorf("distance = %v to %v, want %v to %v", first, last, tt.distance[0], tt.distance[1])
+			}
+			if got := lapBounds(got.Activity); !slices.Equal(got, tt.laps) {
+				t.Errorf("laps = %v, want %v", got, tt.laps)
+			}
+
+			// The original is left as it was
+			if len(f.Streams.Time.Data) != 20 || len(f.Activity.Laps) != 2 {
+				t.Error("the original file was changed")
+			}
+		})
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	tests := []struct {
+		name          string
+		at            time.Duration
+		err           error
+		first, second int
+	}{
+		{"at a lap start", 50 * time.Second, nil, 10, 10},
+		{"between points", 52 * time.Second, nil, 11, 9},
+		{"at the last point", 95 * time.Second, nil, 19, 1},
+		{"at the first point", 0, ErrOutOfRange, 0, 0},
+		{"before the start", -time.Minute, ErrOutOfRange, 0, 0},
+		{"after the end", 96 * time.Second, ErrOutOfRange, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := lapFile(t, 0)
+			first, second, err := f.SplitAt(f.Activity.StartDate.Add(tt.at))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if n := len(first.Streams.Time.Data); n != tt.first {
+				t.Errorf("first file has %d points, want %d", n, tt.first)
+			}
+			if n := len(second.Streams.Time.Data); n != tt.second {
+				t.Errorf("second file has %d points, want %d", n, tt.second)
+			}
+			if !first.Activity.StartDate.Equal(f.Activity.StartDate) {
+				t.Errorf("first file starts at %v, want %v", first.Activity.StartDate, f.Activity.StartDate)
+			}
+
+			// The second file starts at its first point, at or after the split
+			offset := time.Duration(f.Streams.Time.Data[tt.first]) * time.Second
+			if want := f.Activity.StartDate.Add(offset); !second.Activity.StartDate.Equal(want) {
+				t.Errorf("second file starts at %v, want %v", second.Activity.StartDate, want)
+			}
+			if second.Streams.Time.Data[0] != 0 || second.Streams.Distance.Data[0] != 0 {
+				t.Error("second file does not restart time and distance from zero")
+			}
+			if first.Format != f.Format || second.Format != f.Format {
+				t.Errorf("formats = %s and %s, want %s", first.Format, second.Format, f.Format)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// bridge is the straight-line distance from the sample's last point, 95 s
+	// and 210.9 m in, to a point of the second recording
+	bridge := func(lat float64) float64 {
+		return geo.Distance(models.LatLng{Lat: 51.5019, Lng: -0.1}, models.LatLng{Lat: lat, Lng: -0.1})
+	}
+
+	tests := []struct {
+		name     string
+		offset   time.Duration // start of the second recording after the first
+		swap     bool
+		opts     *MergeOptions
+		err      error
+		points   int
+		resume   int     // time of the second recording's first point, the 21st
+		distance float64 // distance at the last point
+		laps     [][2]int
+	}{
+		{
+			name:   "after a pause",
+			offset: 200 * time.Second,
+			points: 40, resume: 200, distance: 421.8 + bridge(51.5),
+			laps: [][2]int{{0, 9}, {10, 19}, {20, 29}, {30, 39}},
+		},
+		{
+			name:   "passed in reverse order",
+			offset: 200 * time.Second, swap: true,
+			points: 40, resume: 200, distance: 421.8 + bridge(51.5),
+			laps: [][2]int{{0, 9}, {10, 19}, {20, 29}, {30, 39}},
+		},
+		{
+			name:   "closing the gap",
+			offset: 200 * time.Second, opts: &MergeOptions{CloseGap: true},
+			points: 40, resume: 96, distance: 421.8,
+			laps: [][2]int{{0, 9}, {10, 19}, {20, 29}, {30, 39}},
+		},
+		{
+			// The second recording's points up to 95 s into the first are
+			// dropped, which leaves its second lap
+			name:   "overlapping",
+			offset: 50 * time.Second,
+			points: 30, resume: 100, distance: 210.9 + 99.9 + bridge(51.501),
+			laps: [][2]int{{0, 9}, {10, 19}, {20, 29}},
+		},
+		{
+			name:   "overlapping by one point",
+			offset: 95 * time.Second,
+			points: 39, resume: 100, distance: 210.9 + 199.8 + bridge(51.5001),
+			laps: [][2]int{{0, 9}, {10, 19}, {20, 28}, {29, 38}},
+		},
+		{
+			name:   "overlapping completely",
+			offset: 0,
+			err:    ErrOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := lapFile(t, 0), lapFile(t, tt.offset)
+			if tt.swap {
+				a, b = b, a
+			}
+			merged, err := Merge(a, b, tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			ts, ds := merged.Streams.Time.Data, merged.Streams.Distance.Data
+			if len(ts) != tt.points {
+				t.Fatalf("%d points, want %d", len(ts), tt.points)
+			}
+			first := lapFile(t, 0)
+			if !merged.Activity.StartDate.Equal(first.Activity.StartDate) {
+				t.Errorf("start date = %v, want the earlier recording's %v", merged.Activity.StartDate, first.Activity.StartDate)
+			}
+			if resume := ts[20]; resume != tt.resume {
+				t.Errorf("second recording resumes at %d s, want %d s", resume, tt.resume)
+			}
+			if !slices.IsSorted(ts) {
+				t.Errorf("time goes backwards: %v", ts)
+			}
+			if last := ds[len(ds)-1]; math.Abs(last-tt.distance) > 1e-6 {
+				t.Errorf("distance = %v, want %v", last, tt.distance)
+			}
+			if got := lapBounds(merged.Activity); !slices.Equal(got, tt.laps) {
+				t.Errorf("laps = %v, want %v", got, tt.laps)
+			}
+		})
+	}
+}
+
+// uploadClient is a services.Client that accepts uploads and records deletes
+type uploadClient struct {
+	status  models.Upload
+	deleted []string
+}
+
+func (c *uploadClient) Get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	data, err := json.Marshal(c.status)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (c *uploadClient) Post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	return errors.New("unexpected post")
+}
+
+func (c *uploadClient) Put(ctx context.Context, path string, body interface{}, result interface{}) error {
+	return errors.New("unexpected put")
+}
+
+func (c *uploadClient) Delete(ctx context.Context, path string) error {
+	c.deleted = append(c.deleted, path)
+	return nil
+}
+
+func (c *uploadClient) PostMultipart(ctx context.Context, path string, contentType string, body io.Reader, result interface{}) error {
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return err
+	}
+	*result.(*models.Upload) = models.Upload{ID: c.status.ID, Status: "Your activity is still being processed."}
+	return nil
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  models.Upload
+		err     error
+		deleted []string
+	}{
+		{"processed", models.Upload{ID: 7, ActivityID: 99}, nil, []string{"/activities/42"}},
+		{"duplicate", models.Upload{ID: 7, Error: "duplicate of activity 42"}, services.ErrUploadFailed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &uploadClient{status: tt.status}
+			upload, err := Replace(context.Background(),
+				services.NewUploadsService(client), services.NewActivitiesService(client), 42, lapFile(t, 0))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if upload.ID != 7 {
+				t.Errorf("upload ID = %d, want 7", upload.ID)
+			}
+			if !slices.Equal(client.deleted, tt.deleted) {
+				t.Errorf("deleted %v, want %v", client.deleted, tt.deleted)
+			}
+		})
+	}
+}
+
+// Files read back from their data keep their edits
+func TestEditRoundTrip(t *testing.T) {
+	trimmed, err := lapFile(t, 0).TrimTime(10*time.Second, 0)
+	if err != nil {
+		t.Fatalf("TrimTime: %v", err)
+	}
+	parsed, err := Parse(bytes.NewReader(trimmed.Data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !slices.Equal(parsed.Streams.Time.Data, trimmed.Streams.Time.Data) {
+		t.Errorf("time = %v, want %v", parsed.Streams.Time.Data, trimmed.Streams.Time.Data)
+	}
+	if !parsed.Activity.StartDate.Equal(trimmed.Activity.StartDate) {
+		t.Errorf("start date = %v, want %v", parsed.Activity.StartDate, trimmed.Activity.StartDate)
+	}
+}
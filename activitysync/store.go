@@ -0,0 +1,215 @@
+package activitysync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// ErrNotFound is returned by stores for activities and streams they do not have
+var ErrNotFound = errors.New("activitysync: not found")
+
+// Store keeps the mirrored activities, their streams and the sync checkpoint.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Activity returns a stored activity, or ErrNotFound
+	Activity(ctx context.Context, id int64) (*models.Activity, error)
+
+	// PutActivity stores an activity, replacing any stored version
+	PutActivity(ctx context.Context, activity *models.Activity) error
+
+	// DeleteActivity removes an activity and its streams. Deleting an
+	// activity that is not stored is not an error.
+	DeleteActivity(ctx context.Context, id int64) error
+
+	// ActivityIDs returns the IDs of all stored activities, in ascending order
+	ActivityIDs(ctx context.Context) ([]int64, error)
+
+	// Streams returns an activity's stored streams, or ErrNotFound
+	Streams(ctx context.Context, id int64) (*models.StreamSet, error)
+
+	// PutStreams stores an activity's streams
+	PutStreams(ctx context.Context, id int64, streams *models.StreamSet) error
+
+	// Checkpoint returns the saved checkpoint, or a zero checkpoint when none
+	// has been saved
+	Checkpoint(ctx context.Context) (*Checkpoint, error)
+
+	// PutCheckpoint saves the checkpoint
+	PutCheckpoint(ctx context.Context, checkpoint *Checkpoint) error
+}
+
+// Checkpoint is the progress of syncing, saved after every step so that an
+// interrupted sync resumes where it stopped
+type Checkpoint struct {
+	// After is the start time of the newest activity listed by the last
+	// finished backfill, as a unix timestamp. The next backfill lists only
+	// activities started after it.
+	After int64 `json:"after"`
+
+	// Page is the last page listed by the backfill in progress, or 0
+	Page int `json:"page"`
+
+	// Newest is the start time of the newest activity listed by the
+	// backfill in progress, as a unix timestamp
+	Newest int64 `json:"newest"`
+
+	// Pending are the IDs of activities stored as summaries and waiting to
+	// be hydrated to detail, in the order they were listed
+	Pending []int64 `json:"pending"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JSONStore is a Store that keeps each activity and its streams in their own
+// JSON files:
+//
+//	checkpoint.json
+//	activities/<id>.json
+//	streams/<id>.json
+//
+// Files are replaced atomically, so a crash never leaves a partial file.
+type JSONStore struct {
+	dir string
+}
+
+// NewJSONStore creates a store in dir, creating the directory if needed
+func NewJSONStore(dir string) (*JSONStore, error) {
+	for _, sub := range []string{"activities", "streams"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &JSONStore{dir: dir}, nil
+}
+
+// Activity returns a stored activity, or ErrNotFound
+func (s *JSONStore) Activity(ctx context.Context, id int64) (*models.Activity, error) {
+	var activity models.Activity
+	if err := s.read(s.activityPath(id), &activity); err != nil {
+		return nil, err
+	}
+	return &activity, nil
+}
+
+// PutActivity stores an activity, replacing any stored version
+func (s *JSONStore) PutActivity(ctx context.Context, activity *models.Activity) error {
+	return s.write(s.activityPath(activity.ID), activity)
+}
+
+// DeleteActivity removes an activity and its streams
+func (s *JSONStore) DeleteActivity(ctx context.Context, id int64) error {
+	for _, path := range []string{s.streamsPath(id), s.activityPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActivityIDs returns the IDs of all stored activities, in ascending order
+func (s *JSONStore) ActivityIDs(ctx context.Context) ([]int64, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "activities"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.ParseInt(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// Streams returns an activity's stored streams, or ErrNotFound
+func (s *JSONStore) Streams(ctx context.Context, id int64) (*models.StreamSet, error) {
+	var streams models.StreamSet
+	if err := s.read(s.streamsPath(id), &streams); err != nil {
+		return nil, err
+	}
+	return &streams, nil
+}
+
+// PutStreams stores an activity's streams
+func (s *JSONStore) PutStreams(ctx context.Context, id int64, streams *models.StreamSet) error {
+	return s.write(s.streamsPath(id), streams)
+}
+
+// Checkpoint returns the saved checkpoint, or a zero checkpoint
+func (s *JSONStore) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	err := s.read(filepath.Join(s.dir, "checkpoint.json"), &checkpoint)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// PutCheckpoint saves the checkpoint
+func (s *JSONStore) PutCheckpoint(ctx context.Context, checkpoint *Checkpoint) error {
+	return s.write(filepath.Join(s.dir, "checkpoint.json"), checkpoint)
+}
+
+// activityPath returns the path of an activity's file
+func (s *JSONStore) activityPath(id int64) string {
+	return filepath.Join(s.dir, "activities", strconv.FormatInt(id, 10)+".json")
+}
+
+// streamsPath returns the path of an activity's streams file
+func (s *JSONStore) streamsPath(id int64) string {
+	return filepath.Join(s.dir, "streams", strconv.FormatInt(id, 10)+".json")
+}
+
+// read decodes a JSON file into v, returning ErrNotFound when it does not exist
+func (s *JSONStore) read(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// write replaces a file with v as JSON, through a temporary file
+func (s *JSONStore) write(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,117 @@
+package activitysync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+func TestJSONStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "mirror")
+	store, err := NewJSONStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+
+	// An empty store has no activities and a zero checkpoint
+	if _, err := store.Activity(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Activity = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Streams(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Streams = %v, want ErrNotFound", err)
+	}
+	if checkpoint, err := store.Checkpoint(ctx); err != nil || !reflect.DeepEqual(checkpoint, &Checkpoint{}) {
+		t.Errorf("Checkpoint = %+v, %v, want a zero checkpoint", checkpoint, err)
+	}
+
+	activities := []*models.Activity{
+		{ID: 30, Name: "Evening Ride", Type: models.ActivityTypeRide, ResourceState: models.ResourceStateDetail},
+		{ID: 4, Name: "Morning Run", Type: models.ActivityTypeRun, ResourceState: models.ResourceStateSummary},
+		{ID: 200, Name: "Swim", Type: models.ActivityTypeSwim, ResourceState: models.ResourceStateSummary},
+	}
+	for _, activity := range activities {
+		if err := store.PutActivity(ctx, activity); err != nil {
+			t.Fatalf("PutActivity(%d): %v", activity.ID, err)
+		}
+	}
+	streams := &models.StreamSet{
+		Time:     &models.TimeStream{Data: []int{0, 1, 2}},
+		Distance: &models.DistanceStream{Data: []float64{0, 4.5, 9}},
+	}
+	if err := store.PutStreams(ctx, 30, streams); err != nil {
+		t.Fatalf("PutStreams: %v", err)
+	}
+	checkpoint := &Checkpoint{
+		After:     1714824000,
+		Page:      2,
+		Newest:    1714910400,
+		Pending:   []int64{4, 200},
+		UpdatedAt: time.Date(2024, 5, 5, 12, 0, 0, 0, time.UTC),
+	}
+	if err := store.PutCheckpoint(ctx, checkpoint); err != nil {
+		t.Fatalf("PutCheckpoint: %v", err)
+	}
+
+	// Replacing an activity keeps one file
+	renamed := *activities[0]
+	renamed.Name = "Commute"
+	if err := store.PutActivity(ctx, &renamed); err != nil {
+		t.Fatalf("PutActivity: %v", err)
+	}
+
+	// Files that are not activities are ignored
+	if err := os.WriteFile(filepath.Join(dir, "activities", "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second store on the same directory reads everything back
+	reopened, err := NewJSONStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	if got, err := reopened.Activity(ctx, 30); err != nil || !reflect.DeepEqual(got, &renamed) {
+		t.Errorf("Activity = %+v, %v, want %+v", got, err, renamed)
+	}
+	if got, err := reopened.Streams(ctx, 30); err != nil || !reflect.DeepEqual(got.Distance.Data, streams.Distance.Data) {
+		t.Errorf("Streams = %+v, %v, want %+v", got, err, streams)
+	}
+	if got, err := reopened.Checkpoint(ctx); err != nil || !reflect.DeepEqual(got, checkpoint) {
+		t.Errorf("Checkpoint = %+v, %v, want %+v", got, err, checkpoint)
+	}
+	if ids, err := reopened.ActivityIDs(ctx); err != nil || !reflect.DeepEqual(ids, []int64{4, 30, 200}) {
+		t.Errorf("ActivityIDs = %v, %v, want [4 30 200]", ids, err)
+	}
+
+	// Deleting removes the activity and its streams, and deleting again is
+	// not an error
+	for i := 0; i < 2; i++ {
+		if err := reopened.DeleteActivity(ctx, 30); err != nil {
+			t.Fatalf("DeleteActivity: %v", err)
+		}
+	}
+	if _, err := reopened.Activity(ctx, 30); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Activity after delete = %v, want ErrNotFound", err)
+	}
+	if _, err := reopened.Streams(ctx, 30); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Streams after delete = %v, want ErrNotFound", err)
+	}
+
+	// Writes leave no temporary files behind
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && strings.HasPrefix(d.Name(), ".tmp-") {
+			t.Errorf("temporary file left behind: %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
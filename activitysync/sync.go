@@ -0,0 +1,340 @@
+package activitysync
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/services"
+)
+
+// defaultPerPage is the page size for listing activities, the API's maximum
+const defaultPerPage = 200
+
+// allStreams are the streams fetched by default
+var allStreams = []models.StreamType{
+	models.StreamTypeTime, models.StreamTypeDistance, models.StreamTypeLatLng,
+	models.StreamTypeAltitude, models.StreamTypeVelocity, models.StreamTypeHeartrate,
+	models.StreamTypeCadence, models.StreamTypePower, models.StreamTypeTemperature,
+	models.StreamTypeMoving, models.StreamTypeGrade,
+}
+
+// Options contains options for syncing
+type Options struct {
+	// PerPage is the number of activities listed per request (default: 200)
+	PerPage int
+
+	// IncludeAllEfforts fetches all segment efforts with each activity's
+	// detail (default: false)
+	IncludeAllEfforts bool
+
+	// StreamTypes are the streams fetched for an activity (default: all)
+	StreamTypes []models.StreamType
+}
+
+// Result counts what a sync did
+type Result struct {
+	// Listed is the number of activity summaries stored by the backfill
+	Listed int
+
+	// Hydrated is the number of activities fetched in detail
+	Hydrated int
+
+	// Removed is the number of pending activities found to be deleted
+	Removed int
+}
+
+// Engine mirrors the authenticated athlete's activities into a Store. Sync
+// lists new activities and hydrates them to detail, Apply applies webhook
+// events as they arrive and Streams fetches streams the first time they are
+// asked for. Progress is checkpointed in the store after every step. Apply
+// and Streams are safe to call while Sync runs.
+type Engine struct {
+	activities *services.ActivitiesService
+	streams    *services.StreamsService
+	store      Store
+	opts       Options
+
+	// mu serializes changes to the store, so that an activity deleted by a
+	// webhook event is not stored again by a sync in progress
+	mu sync.Mutex
+}
+
+// NewEngine creates a sync engine
+func NewEngine(activities *services.ActivitiesService, streams *services.StreamsService, store Store, opts *Options) *Engine {
+	o := Options{PerPage: defaultPerPage, StreamTypes: allStreams}
+	if opts != nil {
+		o.IncludeAllEfforts = opts.IncludeAllEfforts
+		if opts.PerPage > 0 {
+			o.PerPage = opts.PerPage
+		}
+		if len(opts.StreamTypes) > 0 {
+			o.StreamTypes = opts.StreamTypes
+		}
+	}
+	return &Engine{activities: activities, streams: streams, store: store, opts: o}
+}
+
+// Sync backfills and then hydrates. The backfill lists the activities
+// started after the checkpoint's After, or all activities on the first
+// sync, and stores their summaries, keeping activities already stored in
+// detail. Hydration replaces each stored summary with the activity's detail.
+// After an error, calling Sync again resumes from the checkpoint.
+//
+// Activities uploaded later with an earlier start time, such as old files,
+// are not listed by an incremental backfill; apply webhook create events to
+// pick them up.
+func (e *Engine) Sync(ctx context.Context) (*Result, error) {
+	result := &Result{}
+	if err := e.backfill(ctx, result); err != nil {
+		return result, err
+	}
+	return result, e.hydrate(ctx, result)
+}
+
+// Streams returns an activity's streams from the store, fetching and
+// storing them the first time. Activities without streams, such as manual
+// activities, get an empty stream set.
+func (e *Engine) Streams(ctx context.Context, activityID int64) (*models.StreamSet, error) {
+	streams, err := e.store.Streams(ctx, activityID)
+	if !errors.Is(err, ErrNotFound) {
+		return streams, err
+	}
+
+	streams, err = e.streams.GetActivityStreams(ctx, activityID, e.opts.StreamTypes, "")
+	if internal.IsNotFoundError(err) {
+		streams, err = &models.StreamSet{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Only keep streams of activities still in the mirror
+	if _, err := e.store.Activity(ctx, activityID); errors.Is(err, ErrNotFound) {
+		return streams, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return streams, e.store.PutStreams(ctx, activityID, streams)
+}
+
+// Apply applies a webhook event to the store. Created activities are
+// fetched in detail, deleted ones are removed with their streams, and
+// updates to the title or privacy are applied to the stored activity
+// without a request. Other updates, such as a new type, which changes the
+// sport type and derived fields too, fetch the activity again. Events about
+// athletes are ignored.
+func (e *Engine) Apply(ctx context.Context, event *models.WebhookEvent) error {
+	if event.ObjectType != models.WebhookObjectActivity {
+		return nil
+	}
+
+	switch event.AspectType {
+	case models.WebhookAspectDelete:
+		return e.remove(ctx, event.ObjectID)
+
+	case models.WebhookAspectUpdate:
+		applied, err := e.update(ctx, event)
+		if err != nil || applied {
+			return err
+		}
+		fallthrough
+
+	case models.WebhookAspectCreate:
+		activity, err := e.activities.Get(ctx, event.ObjectID, e.opts.IncludeAllEfforts)
+		if internal.IsNotFoundError(err) {
+			return e.remove(ctx, event.ObjectID)
+		}
+		if err != nil {
+			return err
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.store.PutActivity(ctx, activity)
+	}
+	return nil
+}
+
+// backfill lists activities page by page from the checkpoint, storing
+// summaries and queueing them for hydration
+func (e *Engine) backfill(ctx context.Context, result *Result) error {
+	for {
+		checkpoint, err := e.store.Checkpoint(ctx)
+		if err != nil {
+			return err
+		}
+		page := checkpoint.Page + 1
+		list, err := e.activities.List(ctx, &models.ListOptions{
+			After:   int(checkpoint.After),
+			Page:    page,
+			PerPage: e.opts.PerPage,
+		})
+		if err != nil {
+			return err
+		}
+
+		done, err := e.storeSummaries(ctx, page, list, result)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// storeSummaries stores a page of listed activities and advances the
+// checkpoint, reporting whether it was the last page
+func (e *Engine) storeSummaries(ctx context.Context, page int, list []*models.Activity, result *Result) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	checkpoint, err := e.store.Checkpoint(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, activity := range list {
+		stored, err := e.store.Activity(ctx, activity.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+		if stored == nil || stored.ResourceState < models.ResourceStateDetail {
+			if err := e.store.PutActivity(ctx, activity); err != nil {
+				return false, err
+			}
+			if !slices.Contains(checkpoint.Pending, activity.ID) {
+				checkpoint.Pending = append(checkpoint.Pending, activity.ID)
+			}
+			result.Listed++
+		}
+		checkpoint.Newest = max(checkpoint.Newest, activity.StartDate.Unix())
+	}
+
+	done := len(list) < e.opts.PerPage
+	checkpoint.Page = page
+	if done {
+		checkpoint.After = max(checkpoint.After, checkpoint.Newest)
+		checkpoint.Page, checkpoint.Newest = 0, 0
+	}
+	checkpoint.UpdatedAt = time.Now().UTC()
+	return done, e.store.PutCheckpoint(ctx, checkpoint)
+}
+
+// hydrate fetches the detail of each pending activity
+func (e *Engine) hydrate(ctx context.Context, result *Result) error {
+	for {
+		checkpoint, err := e.store.Checkpoint(ctx)
+		if err != nil {
+			return err
+		}
+		if len(checkpoint.Pending) == 0 {
+			return nil
+		}
+
+		id := checkpoint.Pending[0]
+		activity, err := e.activities.Get(ctx, id, e.opts.IncludeAllEfforts)
+		if internal.IsNotFoundError(err) {
+			activity, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := e.storeDetail(ctx, id, activity, result); err != nil {
+			return err
+		}
+	}
+}
+
+// storeDetail replaces a pending activity's summary with its detail, or
+// removes it when the activity no longer exists
+func (e *Engine) storeDetail(ctx context.Context, id int64, activity *models.Activity, result *Result) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	checkpoint, err := e.store.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(checkpoint.Pending, id)
+	if i < 0 {
+		// Deleted by a webhook event while fetching
+		return nil
+	}
+
+	if activity != nil {
+		err = e.store.PutActivity(ctx, activity)
+		result.Hydrated++
+	} else {
+		err = e.store.DeleteActivity(ctx, id)
+		result.Removed++
+	}
+	if err != nil {
+		return err
+	}
+
+	checkpoint.Pending = slices.Delete(checkpoint.Pending, i, i+1)
+	checkpoint.UpdatedAt = time.Now().UTC()
+	return e.store.PutCheckpoint(ctx, checkpoint)
+}
+
+// update applies a webhook update to the stored activity, reporting false
+// when the activity is not stored or the update has fields that need the
+// activity to be fetched again
+func (e *Engine) update(ctx context.Context, event *models.WebhookEvent) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	activity, err := e.store.Activity(ctx, event.ObjectID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for field := range event.Updates {
+		value, _ := event.Update(field)
+		switch field {
+		case "title":
+			activity.Name = value
+		case "private":
+			private, err := strconv.ParseBool(value)
+			if err != nil {
+				return false, nil
+			}
+			activity.Private = private
+		default:
+			return false, nil
+		}
+	}
+	return true, e.store.PutActivity(ctx, activity)
+}
+
+// remove deletes an activity from the store and from the pending activities
+func (e *Engine) remove(ctx context.Context, id int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.store.DeleteActivity(ctx, id); err != nil {
+		return err
+	}
+
+	checkpoint, err := e.store.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(checkpoint.Pending, id)
+	if i < 0 {
+		return nil
+	}
+	checkpoint.Pending = slices.Delete(checkpoint.Pending, i, i+1)
+	checkpoint.UpdatedAt = time.Now().UTC()
+	return e.store.PutCheckpoint(ctx, checkpoint)
+}
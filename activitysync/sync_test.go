@@ -0,0 +1,348 @@
+package activitysync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/services"
+)
+
+// fakeAPI is a services.Client serving an athlete's activities and logging
+// its requests
+type fakeAPI struct {
+	activities map[int64]*models.Activity
+	requests   []string
+
+	// fail, when set, returns an error for a request instead of serving it
+	fail func(path string, query url.Values) error
+}
+
+// newFakeAPI creates an API with n activities, one a day from 1 May 2024
+func newFakeAPI(n int) *fakeAPI {
+	api := &fakeAPI{activities: make(map[int64]*models.Activity)}
+	for i := 1; i <= n; i++ {
+		api.add(int64(i), time.Date(2024, 5, i, 8, 0, 0, 0, time.UTC))
+	}
+	return api
+}
+
+// add adds an activity started at start
+func (a *fakeAPI) add(id int64, start time.Time) {
+	a.activities[id] = &models.Activity{
+		ID:            id,
+		Name:          fmt.Sprintf("Run %d", id),
+		Type:          models.ActivityTypeRun,
+		StartDate:     start,
+		ResourceState: models.ResourceStateDetail,
+	}
+}
+
+func (a *fakeAPI) Get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	a.requests = append(a.requests, path+"?"+query.Encode())
+	if a.fail != nil {
+		if err := a.fail(path, query); err != nil {
+			return err
+		}
+	}
+
+	if path == "/athlete/activities" {
+		after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+		page, _ := strconv.Atoi(query.Get("page"))
+		perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+		var list []*models.Activity
+		for _, activity := range a.activities {
+			if activity.StartDate.Unix() > after {
+				summary := *activity
+				summary.ResourceState = models.ResourceStateSummary
+				list = append(list, &summary)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].StartDate.Before(list[j].StartDate) })
+		start := min((page-1)*perPage, len(list))
+		return roundTrip(list[start:min(start+perPage, len(list))], result)
+	}
+
+	var id int64
+	if _, err := fmt.Sscanf(path, "/activities/%d", &id); err != nil {
+		return fmt.Errorf("unexpected get %s", path)
+	}
+	activity, ok := a.activities[id]
+	if !ok {
+		return &internal.Error{StatusCode: http.StatusNotFound, Message: "Record Not Found"}
+	}
+	return roundTrip(activity, result)
+}
+
+func (a *fakeAPI) Post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	return errors.New("unexpected post")
+}
+
+func (a *fakeAPI) Put(ctx context.Context, path string, body interface{}, result interface{}) error {
+	return errors.New("unexpected put")
+}
+
+func (a *fakeAPI) Delete(ctx context.Context, path string) error {
+	return errors.New("unexpected delete")
+}
+
+// roundTrip copies v into result through JSON, like a response
+func roundTrip(v, result interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// newEngine creates an engine on the API with a store in a temporary
+// directory
+func newEngine(t *testing.T, api *fakeAPI, opts *Options) (*Engine, *JSONStore) {
+	t.Helper()
+	store, err := NewJSONStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	engine := NewEngine(services.NewActivitiesService(api), services.NewStreamsService(api), store, opts)
+	return engine, store
+}
+
+// checkpoint returns the store's checkpoint
+func checkpoint(t *testing.T, store Store) *Checkpoint {
+	t.Helper()
+	checkpoint, err := store.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	return checkpoint
+}
+
+func TestSyncResume(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(5)
+	engine, store := newEngine(t, api, &Options{PerPage: 2})
+
+	// Listing the second page fails after the first page is stored
+	api.fail = func(path string, query url.Values) error {
+		if path == "/athlete/activities" && query.Get("page") == "2" {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	result, err := engine.Sync(ctx)
+	if err == nil {
+		t.Fatal("Sync: no error")
+	}
+	if result.Listed != 2 || result.Hydrated != 0 {
+		t.Errorf("result = %+v, want 2 listed", result)
+	}
+	if cp := checkpoint(t, store); cp.Page != 1 || cp.After != 0 || !reflect.DeepEqual(cp.Pending, []int64{1, 2}) {
+		t.Errorf("checkpoint = %+v, want page 1 with 1 and 2 pending", cp)
+	}
+
+	// The next sync lists from the second page and fails hydrating 3
+	api.requests = nil
+	api.fail = func(path string, query url.Values) error {
+		if path == "/activities/3" {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	result, err = engine.Sync(ctx)
+	if err == nil {
+		t.Fatal("Sync: no error")
+	}
+	if api.requests[0] != "/athlete/activities?page=2&per_page=2" {
+		t.Errorf("first request = %s, want the second page", api.requests[0])
+	}
+	if result.Listed != 3 || result.Hydrated != 2 {
+		t.Errorf("result = %+v, want 3 listed and 2 hydrated", result)
+	}
+	newest := api.activities[5].StartDate.Unix()
+	if cp := checkpoint(t, store); cp.Page != 0 || cp.Newest != 0 || cp.After != newest || !reflect.DeepEqual(cp.Pending, []int64{3, 4, 5}) {
+		t.Errorf("checkpoint = %+v, want after %d with 3, 4 and 5 pending", cp, newest)
+	}
+
+	// The next sync hydrates the rest, removing an activity deleted since
+	api.fail = nil
+	delete(api.activities, 4)
+	result, err = engine.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if *result != (Result{Hydrated: 2, Removed: 1}) {
+		t.Errorf("result = %+v, want 2 hydrated and 1 removed", result)
+	}
+	if cp := checkpoint(t, store); len(cp.Pending) != 0 {
+		t.Errorf("pending = %v, want none", cp.Pending)
+	}
+	ids, err := store.ActivityIDs(ctx)
+	if err != nil || !reflect.DeepEqual(ids, []int64{1, 2, 3, 5}) {
+		t.Fatalf("ActivityIDs = %v, %v, want [1 2 3 5]", ids, err)
+	}
+	for _, id := range ids {
+		if activity, err := store.Activity(ctx, id); err != nil || activity.ResourceState != models.ResourceStateDetail {
+			t.Errorf("activity %d = %+v, %v, want its detail", id, activity, err)
+		}
+	}
+
+	// A later sync lists only newer activities
+	api.requests = nil
+	api.add(6, time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	result, err = engine.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if *result != (Result{Listed: 1, Hydrated: 1}) {
+		t.Errorf("result = %+v, want 1 listed and hydrated", result)
+	}
+	if want := fmt.Sprintf("/athlete/activities?after=%d&page=1&per_page=2", newest); api.requests[0] != want {
+		t.Errorf("first request = %s, want %s", api.requests[0], want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	stored := &models.Activity{Name: "Stored", Type: models.ActivityTypeRun}
+	fetched := &models.Activity{Name: "Ride", Type: models.ActivityTypeRide}
+
+	tests := []struct {
+		name  string
+		event models.WebhookEvent
+
+		// stored is whether activities 1 and 9 are stored and pending
+		// before the event
+		stored bool
+
+		// fetched is whether the event fetches the activity
+		fetched bool
+
+		// want is the stored activity 1 after the event, or nil when it is
+		// not stored
+		want *models.Activity
+	}{
+		{
+			name:    "create",
+			event:   models.WebhookEvent{AspectType: models.WebhookAspectCreate, ObjectID: 1},
+			fetched: true,
+			want:    fetched,
+		},
+		{
+			name:    "create of a deleted activity",
+			event:   models.WebhookEvent{AspectType: models.WebhookAspectCreate, ObjectID: 9},
+			stored:  true,
+			fetched: true,
+			want:    stored,
+		},
+		{
+			name:   "title update",
+			event:  models.WebhookEvent{AspectType: models.WebhookAspectUpdate, ObjectID: 1, Updates: map[string]interface{}{"title": "Hill Repeats"}},
+			stored: true,
+			want:   &models.Activity{Name: "Hill Repeats", Type: models.ActivityTypeRun},
+		},
+		{
+			name:   "privacy update",
+			event:  models.WebhookEvent{AspectType: models.WebhookAspectUpdate, ObjectID: 1, Updates: map[string]interface{}{"private": "true"}},
+			stored: true,
+			want:   &models.Activity{Name: "Stored", Type: models.ActivityTypeRun, Private: true},
+		},
+		{
+			name:    "type update",
+			event:   models.WebhookEvent{AspectType: models.WebhookAspectUpdate, ObjectID: 1, Updates: map[string]interface{}{"title": "Ride", "type": "Ride"}},
+			stored:  true,
+			fetched: true,
+			want:    fetched,
+		},
+		{
+			name:    "update of an activity not stored",
+			event:   models.WebhookEvent{AspectType: models.WebhookAspectUpdate, ObjectID: 1, Updates: map[string]interface{}{"title": "Hill Repeats"}},
+			fetched: true,
+			want:    fetched,
+		},
+		{
+			name:   "delete",
+			event:  models.WebhookEvent{AspectType: models.WebhookAspectDelete, ObjectID: 1},
+			stored: true,
+		},
+		{
+			name:   "athlete",
+			event:  models.WebhookEvent{AspectType: models.WebhookAspectDelete, ObjectID: 1, ObjectType: models.WebhookObjectAthlete},
+			stored: true,
+			want:   stored,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := newFakeAPI(1)
+			engine, store := newEngine(t, api, nil)
+
+			// The API has since renamed the activity and made it a ride
+			api.activities[1].Name, api.activities[1].Type = fetched.Name, fetched.Type
+			if tt.stored {
+				for _, id := range []int64{1, 9} {
+					activity := *stored
+					activity.ID = id
+					if err := store.PutActivity(ctx, &activity); err != nil {
+						t.Fatal(err)
+					}
+					if err := store.PutStreams(ctx, id, &models.StreamSet{}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := store.PutCheckpoint(ctx, &Checkpoint{Pending: []int64{1, 9}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			event := tt.event
+			if event.ObjectType == "" {
+				event.ObjectType = models.WebhookObjectActivity
+			}
+			if err := engine.Apply(ctx, &event); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got := len(api.requests) > 0; got != tt.fetched {
+				t.Errorf("requests = %v, want fetched %v", api.requests, tt.fetched)
+			}
+
+			got, err := store.Activity(ctx, 1)
+			if tt.want == nil {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Activity = %+v, %v, want ErrNotFound", got, err)
+				}
+			} else if err != nil || got.Name != tt.want.Name || got.Type != tt.want.Type || got.Private != tt.want.Private {
+				t.Errorf("Activity = %+v, %v, want %+v", got, err, tt.want)
+			}
+
+			// Deleted activities are removed with their streams and from the
+			// pending activities
+			removed := event.ObjectType == models.WebhookObjectActivity &&
+				(event.AspectType == models.WebhookAspectDelete || event.ObjectID == 9)
+			if !removed {
+				return
+			}
+			if _, err := store.Activity(ctx, event.ObjectID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Activity(%d) = %v, want ErrNotFound", event.ObjectID, err)
+			}
+			if _, err := store.Streams(ctx, event.ObjectID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Streams(%d) = %v, want ErrNotFound", event.ObjectID, err)
+			}
+			if cp := checkpoint(t, store); slices.Contains(cp.Pending, event.ObjectID) {
+				t.Errorf("pending = %v, want %d removed", cp.Pending, event.ObjectID)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// LocalStart returns an activity's local start time, or its start time when
+// it has no local one
+func LocalStart(a *models.Activity) time.Time {
+	if a.StartDateLocal.IsZero() {
+		return a.StartDate
+	}
+	return a.StartDateLocal
+}
+
+// Sport returns an activity's sport type, or its type for activities from
+// before sport types
+func Sport(a *models.Activity) string {
+	if a.SportType != "" {
+		return string(a.SportType)
+	}
+	return string(a.Type)
+}
@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// TargetDistance is a distance to find best efforts for
+type TargetDistance struct {
+	Name   string  `json:"name"`
+	Meters float64 `json:"meters"`
+}
+
+// DefaultTargetDistances are Strava's standard best effort distances, named as
+// they appear in an activity's best_efforts
+var DefaultTargetDistances = []TargetDistance{
+	{Name: "400m", Meters: 400},
+	{Name: "1/2 mile", Meters: 804.672},
+	{Name: "1k", Meters: 1000},
+	{Name: "1 mile", Meters: 1609.344},
+	{Name: "2 mile", Meters: 3218.688},
+	{Name: "5k", Meters: 5000},
+	{Name: "10k", Meters: 10000},
+	{Name: "15k", Meters: 15000},
+	{Name: "10 mile", Meters: 16093.44},
+	{Name: "20k", Meters: 20000},
+	{Name: "Half-Marathon", Meters: 21097.5},
+	{Name: "30k", Meters: 30000},
+	{Name: "Marathon", Meters: 42195},
+	{Name: "50k", Meters: 50000},
+}
+
+// DistanceEffort is the fastest contiguous window covering a target distance
+type DistanceEffort struct {
+	Name        string    `json:"name"`
+	Distance    float64   `json:"distance"`     // target distance in meters
+	ElapsedTime float64   `json:"elapsed_time"` // seconds, interpolated to the exact distance
+	StartIndex  int       `json:"start_index"`
+	EndIndex    int       `json:"end_index"`
+	StartOffset int       `json:"start_offset"` // seconds from the start of the activity
+	EndOffset   int       `json:"end_offset"`   // seconds from the start of the activity
+	ActivityID  int64     `json:"activity_id,omitempty"`
+	StartDate   time.Time `json:"start_date"`
+}
+
+// FindBestEfforts locates the fastest window for each target distance using the
+// distance and time streams. Targets longer than the activity are omitted.
+func FindBestEfforts(streams *models.StreamSet, targets []TargetDistance) []DistanceEffort {
+	if streams == nil || streams.Distance == nil || streams.Time == nil {
+		return nil
+	}
+	dist := streams.Distance.Data
+	ts := streams.Time.Data
+	if len(dist) < 2 || len(dist) != len(ts) {
+		return nil
+	}
+	if targets == nil {
+		targets = DefaultTargetDistances
+	}
+
+	var efforts []DistanceEffort
+	for _, target := range targets {
+		if target.Meters <= 0 || dist[len(dist)-1]-dist[0] < target.Meters {
+			continue
+		}
+
+		best := DistanceEffort{Name: target.Name, Distance: target.Meters, ElapsedTime: math.Inf(1)}
+		start := 0
+		for end := 1; end < len(dist); end++ {
+			// Move the start forward while the window still covers the target
+			for start+1 < end && dist[end]-dist[start+1] >= target.Meters {
+				start++
+			}
+			covered := dist[end] - dist[start]
+			if covered < target.Meters {
+				continue
+			}
+
+			// Interpolate the moment the window began covering exactly the target
+			startTime := float64(ts[start])
+			if next := start + 1; next <= end && dist[next] > dist[start] {
+				excess := covered - target.Meters
+				fraction := math.Min(1, excess/(dist[next]-dist[start]))
+				startTime += fraction * float64(ts[next]-ts[start])
+			}
+
+			if elapsed := float64(ts[end]) - startTime; elapsed < best.ElapsedTime {
+				best.ElapsedTime = elapsed
+				best.StartIndex = start
+				best.EndIndex = end
+				best.StartOffset = ts[start] - ts[0]
+				best.EndOffset = ts[end] - ts[0]
+			}
+		}
+
+		if !math.IsInf(best.ElapsedTime, 1) {
+			efforts = append(efforts, best)
+		}
+	}
+
+	return efforts
+}
+
+// ActivityBestEfforts finds best efforts in an activity's streams and stamps
+// them with the activity ID and the local start date of each effort
+func ActivityBestEfforts(activity *models.Activity, streams *models.StreamSet, targets []TargetDistance) []DistanceEffort {
+	efforts := FindBestEfforts(streams, targets)
+	for i := range efforts {
+		efforts[i].ActivityID = activity.ID
+		efforts[i].StartDate = LocalStart(activity).Add(time.Duration(efforts[i].StartOffset) * time.Second)
+	}
+	return efforts
+}
+
+// BestEffortComparison pairs a local best effort with the one Strava reported
+type BestEffortComparison struct {
+	Name       string  `json:"name"`
+	Local      float64 `json:"local"`      // seconds
+	Strava     float64 `json:"strava"`     // seconds
+	Difference float64 `json:"difference"` // local minus Strava, in seconds
+}
+
+// CompareBestEfforts matches local efforts to an activity's best_efforts by name
+func CompareBestEfforts(local []DistanceEffort, strava []models.BestEffort) []BestEffortComparison {
+	reported := make(map[string]float64, len(strava))
+	for _, e := range strava {
+		reported[e.Name] = e.ElapsedTime
+	}
+
+	var comparisons []BestEffortComparison
+	for _, e := range local {
+		elapsed, ok := reported[e.Name]
+		if !ok {
+			continue
+		}
+		comparisons = append(comparisons, BestEffortComparison{
+			Name:       e.Name,
+			Local:      e.ElapsedTime,
+			Strava:     elapsed,
+			Difference: e.ElapsedTime - elapsed,
+		})
+	}
+	return comparisons
+}
+
+// PersonalRecords is an athlete's fastest effort for each target distance
+// across activities
+type PersonalRecords struct {
+	Records map[string]DistanceEffort `json:"records"`
+}
+
+// NewPersonalRecords creates an empty personal records table
+func NewPersonalRecords() *PersonalRecords {
+	return &PersonalRecords{Records: make(map[string]DistanceEffort)}
+}
+
+// Add records any efforts that beat the current records and returns them
+func (p *PersonalRecords) Add(efforts []DistanceEffort) []DistanceEffort {
+	if p.Records == nil {
+		p.Records = make(map[string]DistanceEffort)
+	}
+
+	var improved []DistanceEffort
+	for _, e := range efforts {
+		current, ok := p.Records[e.Name]
+		if ok && current.ElapsedTime <= e.ElapsedTime {
+			continue
+		}
+		p.Records[e.Name] = e
+		improved = append(improved, e)
+	}
+	return improved
+}
+
+// List returns the records ordered by distance
+func (p *PersonalRecords) List() []DistanceEffort {
+	records := make([]DistanceEffort, 0, len(p.Records))
+	for _, e := range p.Records {
+		records = append(records, e)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Distance < records[j].Distance
+	})
+	return records
+}
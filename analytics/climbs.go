@@ -0,0 +1,241 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Climb categories on the same scale as models.Segment.ClimbCategory
+const (
+	ClimbCategoryNone = 0
+	ClimbCategory4    = 1
+	ClimbCategory3    = 2
+	ClimbCategory2    = 3
+	ClimbCategory1    = 4
+	ClimbCategoryHC   = 5
+)
+
+// climbScoreThresholds are the minimum distance × grade scores for categories 4 to HC
+var climbScoreThresholds = []float64{8000, 16000, 32000, 64000, 80000}
+
+// ClimbOptions contains thresholds for climb detection
+type ClimbOptions struct {
+	// MinGrade is the minimum average grade in percent (default: 3)
+	MinGrade float64
+
+	// MinLength is the minimum climb length in meters (default: 500)
+	MinLength float64
+
+	// MinGain is the minimum elevation gain in meters (default: 20)
+	MinGain float64
+
+	// MaxDescent is how far in meters the road may drop below the high point
+	// before the climb is considered over (default: 10)
+	MaxDescent float64
+
+	// Smoothing is applied to the altitude stream before detection
+	// (default: 100 m moving average)
+	Smoothing *ElevationOptions
+
+	// GradeWindow is the distance in meters over which max grade is measured (default: 100)
+	GradeWindow float64
+}
+
+// Climb represents a climb detected in an altitude stream
+type Climb struct {
+	StartIndex    int       `json:"start_index"`
+	EndIndex      int       `json:"end_index"`
+	StartDistance float64   `json:"start_distance"` // meters
+	EndDistance   float64   `json:"end_distance"`   // meters
+	Length        float64   `json:"length"`         // meters
+	ElevationGain float64   `json:"elevation_gain"` // meters
+	StartAltitude float64   `json:"start_altitude"` // meters
+	EndAltitude   float64   `json:"end_altitude"`   // meters
+	AverageGrade  float64   `json:"average_grade"`  // percent
+	MaxGrade      float64   `json:"max_grade"`      // percent
+	Duration      int       `json:"duration"`       // seconds, zero without a time stream
+	VAM           float64   `json:"vam"`            // meters climbed per hour, zero without a time stream
+	Category      int       `json:"category"`
+	StartLatlng   []float64 `json:"start_latlng,omitempty"`
+	EndLatlng     []float64 `json:"end_latlng,omitempty"`
+}
+
+// CategoryName returns the climb's category as Strava labels it
+func (c Climb) CategoryName() string {
+	return ClimbCategoryName(c.Category)
+}
+
+// ClimbCategoryFor categorises a climb from its length in meters and average
+// grade in percent using Strava's distance × grade score
+func ClimbCategoryFor(length, averageGrade float64) int {
+	if averageGrade < 3 {
+		return ClimbCategoryNone
+	}
+
+	score := length * averageGrade
+	category := ClimbCategoryNone
+	for i, threshold := range climbScoreThresholds {
+		if score >= threshold {
+			category = i + 1
+		}
+	}
+	return category
+}
+
+// ClimbCategoryName returns the label for a climb category
+func ClimbCategoryName(category int) string {
+	switch category {
+	case ClimbCategory4:
+		return "Cat 4"
+	case ClimbCategory3:
+		return "Cat 3"
+	case ClimbCategory2:
+		return "Cat 2"
+	case ClimbCategory1:
+		return "Cat 1"
+	case ClimbCategoryHC:
+		return "HC"
+	default:
+		return "NC"
+	}
+}
+
+// DetectClimbs finds climbs in the altitude and distance streams. It works on
+// activity, route and segment streams; VAM and duration need a time stream.
+func DetectClimbs(streams *models.StreamSet, opts *ClimbOptions) []Climb {
+	if streams == nil || streams.Altitude == nil || streams.Distance == nil {
+		return nil
+	}
+	dist := streams.Distance.Data
+	if len(dist) < 2 || len(dist) != len(streams.Altitude.Data) {
+		return nil
+	}
+
+	o := ClimbOptions{MinGrade: 3, MinLength: 500, MinGain: 20, MaxDescent: 10, GradeWindow: 100}
+	if opts != nil {
+		if opts.MinGrade > 0 {
+			o.MinGrade = opts.MinGrade
+		}
+		if opts.MinLength > 0 {
+			o.MinLength = opts.MinLength
+		}
+		if opts.MinGain > 0 {
+			o.MinGain = opts.MinGain
+		}
+		if opts.MaxDescent > 0 {
+			o.MaxDescent = opts.MaxDescent
+		}
+		o.Smoothing = opts.Smoothing
+		if opts.GradeWindow > 0 {
+			o.GradeWindow = opts.GradeWindow
+		}
+	}
+
+	alt := SmoothElevation(streams, o.Smoothing).Altitude
+
+	var climbs []Climb
+	emit := func(start, end int) {
+		start, end = trimClimb(alt, dist, start, end, o.GradeWindow, o.MinGrade/2)
+		if end <= start {
+			return
+		}
+		c := buildClimb(streams, alt, start, end, o.GradeWindow)
+		if c.Length >= o.MinLength && c.ElevationGain >= o.MinGain && c.AverageGrade >= o.MinGrade {
+			climbs = append(climbs, c)
+		}
+	}
+
+	start, peak := 0, 0
+	for i := 1; i < len(alt); i++ {
+		switch {
+		case peak == start && alt[i] <= alt[start]:
+			// Still heading down to the foot of the next climb
+			start, peak = i, i
+		case alt[i] > alt[peak]:
+			peak = i
+		case alt[peak]-alt[i] > o.MaxDescent || alt[i] <= alt[start]:
+			emit(start, peak)
+			start, peak = i, i
+		}
+	}
+	emit(start, peak)
+
+	return climbs
+}
+
+// buildClimb measures the climb between two indices of the smoothed altitude
+func buildClimb(streams *models.StreamSet, alt []float64, start, end int, gradeWindow float64) Climb {
+	dist := streams.Distance.Data
+	c := Climb{
+		StartIndex:    start,
+		EndIndex:      end,
+		StartDistance: dist[start],
+		EndDistance:   dist[end],
+		Length:        dist[end] - dist[start],
+		StartAltitude: alt[start],
+		EndAltitude:   alt[end],
+	}
+
+	for i := start + 1; i <= end; i++ {
+		if d := alt[i] - alt[i-1]; d > 0 {
+			c.ElevationGain += d
+		}
+	}
+	c.AverageGrade = utils.CalculateGrade(alt[end]-alt[start], c.Length)
+	c.Category = ClimbCategoryFor(c.Length, c.AverageGrade)
+
+	// Max grade over a sliding distance window so single-sample spikes don't count
+	j := start
+	for i := start; i < end; i++ {
+		for j < end && dist[j]-dist[i] < gradeWindow {
+			j++
+		}
+		if dist[j] > dist[i] {
+			c.MaxGrade = math.Max(c.MaxGrade, utils.CalculateGrade(alt[j]-alt[i], dist[j]-dist[i]))
+		}
+	}
+
+	if streams.Time != nil && len(streams.Time.Data) == len(dist) {
+		c.Duration = streams.Time.Data[end] - streams.Time.Data[start]
+		if c.Duration > 0 {
+			c.VAM = c.ElevationGain / float64(c.Duration) * 3600
+		}
+	}
+
+	if streams.LatLng != nil && len(streams.LatLng.Data) == len(dist) {
+		c.StartLatlng = streams.LatLng.Data[start]
+		c.EndLatlng = streams.LatLng.Data[end]
+	}
+
+	return c
+}
+
+// trimClimb drops the flat run-in and run-out of a climb, keeping the part
+// between the first and last windows steeper than minGrade
+func trimClimb(alt, dist []float64, start, end int, window, minGrade float64) (int, int) {
+	for start < end {
+		j := start
+		for j < end && dist[j]-dist[start] < window {
+			j++
+		}
+		if utils.CalculateGrade(alt[j]-alt[start], dist[j]-dist[start]) >= minGrade {
+			break
+		}
+		start++
+	}
+
+	for end > start {
+		j := end
+		for j > start && dist[end]-dist[j] < window {
+			j--
+		}
+		if utils.CalculateGrade(alt[end]-alt[j], dist[end]-dist[j]) >= minGrade {
+			break
+		}
+		end--
+	}
+
+	return start, end
+}
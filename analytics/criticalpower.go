@@ -0,0 +1,368 @@
+package analytics
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// DefaultCurveDurations are the durations in seconds used for power curves
+var DefaultCurveDurations = []int{1, 5, 10, 15, 30, 60, 120, 180, 300, 480, 600, 1200, 1800, 3600, 5400, 7200}
+
+// ErrInsufficientData is returned when there are too few points to fit a model
+var ErrInsufficientData = errors.New("analytics: insufficient data to fit model")
+
+// PowerCurvePoint is the best average power held for a duration
+type PowerCurvePoint struct {
+	Duration int     `json:"duration"` // seconds
+	Watts    float64 `json:"watts"`
+	Start    int     `json:"start"` // offset in seconds from the start of the activity
+}
+
+// CPModel is a fitted critical power model
+type CPModel struct {
+	CP     float64 `json:"cp"`      // critical power in watts
+	WPrime float64 `json:"w_prime"` // W′ in joules
+	Pmax   float64 `json:"pmax"`    // maximal instantaneous power, zero for the 2-parameter model
+	K      float64 `json:"k"`       // time asymptote of the 3-parameter model in seconds
+
+	R2     float64 `json:"r2"`     // coefficient of determination of the predicted powers
+	RMSE   float64 `json:"rmse"`   // root mean square error in watts
+	Points int     `json:"points"` // number of curve points used in the fit
+}
+
+// Power returns the power the model predicts can be held for a duration in seconds
+func (m CPModel) Power(duration float64) float64 {
+	if duration-m.K <= 0 {
+		return m.Pmax
+	}
+	return m.CP + m.WPrime/(duration-m.K)
+}
+
+// EstimatedFTP returns an FTP estimate from the model's critical power
+func (m CPModel) EstimatedFTP() int {
+	return int(math.Round(m.CP))
+}
+
+// CPFitOptions restricts which curve durations are used in a fit
+type CPFitOptions struct {
+	MinDuration int // seconds (default: 120 for 2-parameter, 30 for 3-parameter)
+	MaxDuration int // seconds (default: 1200)
+}
+
+// PowerCurve calculates the mean-maximal power for each duration from an
+// activity's power stream. Durations longer than the activity are omitted.
+func PowerCurve(streams *models.StreamSet, durations []int) []PowerCurvePoint {
+	if streams == nil || streams.Watts == nil {
+		return nil
+	}
+	if durations == nil {
+		durations = DefaultCurveDurations
+	}
+
+	watts := resampleWatts(streams.Watts, streams.Time)
+
+	// Prefix sums make each window average O(1)
+	sums := make([]float64, len(watts)+1)
+	for i, w := range watts {
+		sums[i+1] = sums[i] + w
+	}
+
+	var curve []PowerCurvePoint
+	for _, d := range durations {
+		if d <= 0 || d > len(watts) {
+			continue
+		}
+
+		best := PowerCurvePoint{Duration: d, Watts: -1}
+		for i := 0; i+d <= len(watts); i++ {
+			avg := (sums[i+d] - sums[i]) / float64(d)
+			if avg > best.Watts {
+				best.Watts = avg
+				best.Start = i
+			}
+		}
+		curve = append(curve, best)
+	}
+
+	return curve
+}
+
+// BestPowerCurve merges power curves, keeping the best power for each duration
+func BestPowerCurve(curves ...[]PowerCurvePoint) []PowerCurvePoint {
+	best := make(map[int]PowerCurvePoint)
+	var order []int
+	for _, curve := range curves {
+		for _, p := range curve {
+			current, ok := best[p.Duration]
+			if !ok {
+				order = append(order, p.Duration)
+			}
+			if !ok || p.Watts > current.Watts {
+				best[p.Duration] = p
+			}
+		}
+	}
+
+	sort.Ints(order)
+	merged := make([]PowerCurvePoint, len(order))
+	for i, d := range order {
+		merged[i] = best[d]
+	}
+	return merged
+}
+
+// FitCP2 fits the 2-parameter critical power model using the linear work-time
+// relationship, work = CP·t + W′
+func FitCP2(curve []PowerCurvePoint, opts *CPFitOptions) (*CPModel, error) {
+	points := filterCurve(curve, opts, 120)
+	if len(points) < 2 {
+		return nil, ErrInsufficientData
+	}
+
+	ts := make([]float64, len(points))
+	work := make([]float64, len(points))
+	for i, p := range points {
+		ts[i] = float64(p.Duration)
+		work[i] = p.Watts * float64(p.Duration)
+	}
+
+	cp, wPrime, ok := linearRegression(ts, work)
+	if !ok || cp <= 0 || wPrime <= 0 {
+		return nil, ErrInsufficientData
+	}
+
+	model := &CPModel{CP: cp, WPrime: wPrime}
+	model.R2, model.RMSE = goodnessOfFit(*model, points)
+	model.Points = len(points)
+	return model, nil
+}
+
+// FitCP3 fits Morton's 3-parameter critical power model,
+// P = CP + W′/(t − k) with k = W′/(CP − Pmax)
+func FitCP3(curve []PowerCurvePoint, opts *CPFitOptions) (*CPModel, error) {
+	points := filterCurve(curve, opts, 30)
+	if len(points) < 3 {
+		return nil, ErrInsufficientData
+	}
+
+	// For a fixed k the model is linear in CP and W′, so search k and solve
+	// the linear least squares problem at each step
+	fit := func(k float64) (*CPModel, float64) {
+		xs := make([]float64, len(points))
+		ys := make([]float64, len(points))
+		for i, p := range points {
+			xs[i] = 1 / (float64(p.Duration) - k)
+			ys[i] = p.Watts
+		}
+
+		wPrime, cp, ok := linearRegression(xs, ys)
+		if !ok || cp <= 0 || wPrime <= 0 {
+			return nil, math.Inf(1)
+		}
+
+		m := &CPModel{CP: cp, WPrime: wPrime, K: k}
+		var sse float64
+		for i := range xs {
+			r := ys[i] - m.Power(float64(points[i].Duration))
+			sse += r * r
+		}
+		return m, sse
+	}
+
+	// Golden-section search over k, which is negative when Pmax exceeds CP
+	lo, hi := -float64(points[0].Duration), 0.0
+	const phi = 0.6180339887498949
+	a, b := hi-phi*(hi-lo), lo+phi*(hi-lo)
+	_, fa := fit(a)
+	_, fb := fit(b)
+	for i := 0; i < 100 && hi-lo > 1e-6; i++ {
+		if fa < fb {
+			hi, b, fb = b, a, fa
+			a = hi - phi*(hi-lo)
+			_, fa = fit(a)
+		} else {
+			lo, a, fa = a, b, fb
+			b = lo + phi*(hi-lo)
+			_, fb = fit(b)
+		}
+	}
+
+	model, _ := fit((lo + hi) / 2)
+	if model == nil {
+		return nil, ErrInsufficientData
+	}
+
+	if model.K < 0 {
+		model.Pmax = model.CP - model.WPrime/model.K
+	}
+	model.R2, model.RMSE = goodnessOfFit(*model, points)
+	model.Points = len(points)
+	return model, nil
+}
+
+// WPrimeBalance is the second-by-second W′ balance of an activity
+type WPrimeBalance struct {
+	Balance   []float64 `json:"balance"`     // joules remaining at each sample
+	Min       float64   `json:"min"`         // lowest balance reached
+	MinIndex  int       `json:"min_index"`   // sample index of the lowest balance
+	TimeInRed int       `json:"time_in_red"` // seconds spent with a negative balance
+}
+
+// CalculateWPrimeBalance computes W′ balance from an activity's power stream
+// using Skiba's differential model. Each sample's power applies for one
+// second and the rest of any gap in the time stream is recovery at 0 W,
+// integrated exactly. Samples that go back in time, as in merged recordings,
+// keep the previous balance.
+func CalculateWPrimeBalance(streams *models.StreamSet, cp, wPrime float64) *WPrimeBalance {
+	if streams == nil || streams.Watts == nil || cp <= 0 || wPrime <= 0 {
+		return nil
+	}
+
+	watts := streams.Watts.Data
+	result := &WPrimeBalance{Balance: make([]float64, len(watts)), Min: wPrime}
+
+	var ts []int
+	if streams.Time != nil && len(streams.Time.Data) == len(watts) {
+		ts = streams.Time.Data
+	}
+
+	balance := wPrime
+	var red float64
+	last := 0 // index of the last sample used
+	for i, w := range watts {
+		if ts != nil && i > 0 {
+			dt := ts[i] - ts[last]
+			if dt <= 0 {
+				result.Balance[i] = balance
+				continue
+			}
+			last = i
+			if rest := float64(dt - 1); rest > 0 {
+				if balance < 0 {
+					// Seconds until recovery brings the balance back to zero
+					red += math.Min(rest, wPrime/cp*math.Log((wPrime-balance)/wPrime))
+				}
+				balance = wPrimeStep(balance, 0, rest, cp, wPrime)
+			}
+		}
+
+		balance = wPrimeStep(balance, float64(w), 1, cp, wPrime)
+		if balance < 0 {
+			red++
+		}
+
+		result.Balance[i] = balance
+		if balance < result.Min {
+			result.Min = balance
+			result.MinIndex = i
+		}
+	}
+	result.TimeInRed = int(math.Round(red))
+
+	return result
+}
+
+// wPrimeStep returns the W′ balance after dt seconds at p watts
+func wPrimeStep(balance, p, dt, cp, wPrime float64) float64 {
+	if p > cp {
+		return balance - (p-cp)*dt
+	}
+	return wPrime - (wPrime-balance)*math.Exp(-(cp-p)*dt/wPrime)
+}
+
+// resampleWatts converts a power stream to 1 Hz samples, filling recording
+// gaps with zero power. Samples are used as they are when the time stream
+// does not match or goes backwards, as in merged recordings.
+func resampleWatts(power *models.PowerStream, timeStream *models.TimeStream) []float64 {
+	if timeStream == nil || len(timeStream.Data) != len(power.Data) || len(power.Data) == 0 || !ascending(timeStream.Data) {
+		watts := make([]float64, len(power.Data))
+		for i, w := range power.Data {
+			watts[i] = float64(w)
+		}
+		return watts
+	}
+
+	start := timeStream.Data[0]
+	watts := make([]float64, timeStream.Data[len(timeStream.Data)-1]-start+1)
+	for i, w := range power.Data {
+		watts[timeStream.Data[i]-start] = float64(w)
+	}
+	return watts
+}
+
+// ascending reports whether the timestamps never go backwards
+func ascending(ts []int) bool {
+	for i := 1; i < len(ts); i++ {
+		if ts[i] < ts[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// filterCurve returns the curve points within the fit options' duration range
+func filterCurve(curve []PowerCurvePoint, opts *CPFitOptions, defaultMin int) []PowerCurvePoint {
+	minDuration, maxDuration := defaultMin, 1200
+	if opts != nil {
+		if opts.MinDuration > 0 {
+			minDuration = opts.MinDuration
+		}
+		if opts.MaxDuration > 0 {
+			maxDuration = opts.MaxDuration
+		}
+	}
+
+	var points []PowerCurvePoint
+	for _, p := range curve {
+		if p.Duration >= minDuration && p.Duration <= maxDuration && p.Watts > 0 {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// linearRegression fits y = slope·x + intercept by ordinary least squares
+func linearRegression(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	var sx, sy, sxx, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		sxy += xs[i] * ys[i]
+	}
+
+	denom := n*sxx - sx*sx
+	if denom == 0 {
+		return 0, 0, false
+	}
+
+	slope = (n*sxy - sx*sy) / denom
+	intercept = (sy - slope*sx) / n
+	return slope, intercept, true
+}
+
+// goodnessOfFit returns R² and RMSE of the model's predicted powers
+func goodnessOfFit(m CPModel, points []PowerCurvePoint) (r2, rmse float64) {
+	var mean float64
+	for _, p := range points {
+		mean += p.Watts
+	}
+	mean /= float64(len(points))
+
+	var ssRes, ssTot float64
+	for _, p := range points {
+		r := p.Watts - m.Power(float64(p.Duration))
+		ssRes += r * r
+		ssTot += (p.Watts - mean) * (p.Watts - mean)
+	}
+
+	if ssTot > 0 {
+		r2 = 1 - ssRes/ssTot
+	}
+	rmse = math.Sqrt(ssRes / float64(len(points)))
+	return r2, rmse
+}
@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+func TestPowerCurveTimestamps(t *testing.T) {
+	tests := []struct {
+		name string
+		time []int
+		want map[int]float64
+	}{
+		{"steady", []int{0, 1, 2, 3}, map[int]float64{1: 400, 2: 300}},
+		{"gap filled with zero", []int{0, 1, 3, 4}, map[int]float64{1: 400, 2: 250}},
+		{"backwards", []int{10, 11, 5, 6}, map[int]float64{1: 400, 2: 300}},
+		{"ends before it starts", []int{10, 11, 12, 2}, map[int]float64{1: 400, 2: 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := &models.StreamSet{
+				Time:  &models.TimeStream{Data: tt.time},
+				Watts: &models.PowerStream{Data: []int{100, 200, 400, 100}},
+			}
+			for _, p := range PowerCurve(streams, []int{1, 2}) {
+				if p.Watts != tt.want[p.Duration] {
+					t.Errorf("%d s: %v W, want %v W", p.Duration, p.Watts, tt.want[p.Duration])
+				}
+			}
+		})
+	}
+}
+
+func TestWPrimeBalanceTimestamps(t *testing.T) {
+	const cp, wPrime = 200.0, 1000.0
+	tests := []struct {
+		name      string
+		time      []int
+		watts     []int
+		want      float64
+		timeInRed int
+	}{
+		{"steady", []int{0, 1, 2}, []int{450, 450, 450}, 250, 0},
+		{
+			// Only the last second of the gap is ridden at 450 W; the rest
+			// is recovery from 500 J down at 0 W
+			"gap", []int{0, 1, 61}, []int{450, 450, 450},
+			wPrime - 500*math.Exp(-cp*59/wPrime) - 250, 0,
+		},
+		{
+			// 2 s in the red before the gap and about 3 s into it
+			"gap in the red", []int{0, 1, 2, 3, 13}, []int{700, 700, 700, 600, 0},
+			wPrime - 1900*math.Exp(-cp*10/wPrime), 5,
+		},
+		{"backwards", []int{10, 11, 5, 12}, []int{450, 450, 1000, 450}, 250, 0},
+		{"repeated", []int{0, 1, 1, 2}, []int{450, 450, 1000, 450}, 250, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := &models.StreamSet{
+				Time:  &models.TimeStream{Data: tt.time},
+				Watts: &models.PowerStream{Data: tt.watts},
+			}
+			result := CalculateWPrimeBalance(streams, cp, wPrime)
+			if got := result.Balance[len(tt.watts)-1]; math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("balance = %v, want %v", got, tt.want)
+			}
+			if result.TimeInRed != tt.timeInRed {
+				t.Errorf("time in red = %d s, want %d s", result.TimeInRed, tt.timeInRed)
+			}
+		})
+	}
+}
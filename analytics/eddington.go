@@ -0,0 +1,92 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Units of distance for Eddington numbers, in meters
+const (
+	EddingtonKilometers = 1000.0
+	EddingtonMiles      = 1609.344
+)
+
+// Eddington is an Eddington number: the largest E such that E days each
+// had at least E units of distance
+type Eddington struct {
+	Number int `json:"number"`
+
+	// Needed is the number of further days of at least Number+1 units
+	// needed to reach Number+1
+	Needed int `json:"needed"`
+}
+
+// SportEddington is the Eddington numbers of one sport
+type SportEddington struct {
+	Sport      string    `json:"sport"` // sport type, or activity type when there is none
+	Kilometers Eddington `json:"kilometers"`
+	Miles      Eddington `json:"miles"`
+}
+
+// EddingtonNumber returns the Eddington number of the activities in a unit
+// of distance given in meters, such as EddingtonKilometers. Distances on the
+// same local day are added up, and only whole units count.
+func EddingtonNumber(activities []*models.Activity, unit float64) Eddington {
+	daily := make(map[time.Time]float64)
+	for _, a := range activities {
+		daily[Day(LocalStart(a))] += a.Distance
+	}
+
+	units := make([]int, 0, len(daily))
+	for _, meters := range daily {
+		units = append(units, int(math.Floor(meters/unit)))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(units)))
+
+	var e Eddington
+	for i, u := range units {
+		if u < i+1 {
+			break
+		}
+		e.Number = i + 1
+	}
+
+	e.Needed = e.Number + 1
+	for _, u := range units {
+		if u < e.Number+1 {
+			break
+		}
+		e.Needed--
+	}
+	return e
+}
+
+// EddingtonBySport returns the Eddington numbers in kilometers and miles of
+// each sport with any distance, highest first
+func EddingtonBySport(activities []*models.Activity) []SportEddington {
+	bySport := make(map[string][]*models.Activity)
+	for _, a := range activities {
+		if a.Distance > 0 {
+			bySport[Sport(a)] = append(bySport[Sport(a)], a)
+		}
+	}
+
+	result := make([]SportEddington, 0, len(bySport))
+	for sport, list := range bySport {
+		result = append(result, SportEddington{
+			Sport:      sport,
+			Kilometers: EddingtonNumber(list, EddingtonKilometers),
+			Miles:      EddingtonNumber(list, EddingtonMiles),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kilometers.Number != result[j].Kilometers.Number {
+			return result[i].Kilometers.Number > result[j].Kilometers.Number
+		}
+		return result[i].Sport < result[j].Sport
+	})
+	return result
+}
@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// days returns an activity per distance in km, one day apart
+func days(km ...float64) []*models.Activity {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	activities := make([]*models.Activity, len(km))
+	for i, d := range km {
+		activities[i] = &models.Activity{Distance: d * 1000, StartDateLocal: start.AddDate(0, 0, i)}
+	}
+	return activities
+}
+
+func TestEddingtonNumber(t *testing.T) {
+	sameDay := days(0.6, 0.6)
+	sameDay[1].StartDateLocal = sameDay[0].StartDateLocal.Add(3 * time.Hour)
+
+	noLocal := days(3, 3, 3)
+	for _, a := range noLocal {
+		a.StartDate, a.StartDateLocal = a.StartDateLocal, time.Time{}
+	}
+
+	tests := []struct {
+		name       string
+		activities []*models.Activity
+		unit       float64
+		want       Eddington
+	}{
+		{"none", nil, EddingtonKilometers, Eddington{Number: 0, Needed: 1}},
+		{"under a unit", days(0.9), EddingtonKilometers, Eddington{Number: 0, Needed: 1}},
+		{"one day", days(1), EddingtonKilometers, Eddington{Number: 1, Needed: 2}},
+		{"same day added up", sameDay, EddingtonKilometers, Eddington{Number: 1, Needed: 2}},
+		{"equal days", days(10, 10, 10), EddingtonKilometers, Eddington{Number: 3, Needed: 1}},
+		{"staircase", days(5, 4, 3, 2, 1), EddingtonKilometers, Eddington{Number: 3, Needed: 2}},
+		{"partial units floored", days(2.99, 2.99, 2.99), EddingtonKilometers, Eddington{Number: 2, Needed: 3}},
+		{"miles", days(3.3, 3.3, 1.6), EddingtonMiles, Eddington{Number: 2, Needed: 3}},
+		{"start date without local date", noLocal, EddingtonKilometers, Eddington{Number: 3, Needed: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EddingtonNumber(tt.activities, tt.unit); got != tt.want {
+				t.Errorf("EddingtonNumber = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEddingtonBySport(t *testing.T) {
+	activities := days(5, 5, 5, 2, 2)
+	for i, a := range activities {
+		a.Type = models.ActivityTypeRide
+		if i < 3 {
+			a.SportType = "GravelRide"
+		}
+	}
+
+	got := EddingtonBySport(activities)
+	if len(got) != 2 || got[0].Sport != "GravelRide" || got[0].Kilometers.Number != 3 || got[1].Sport != "Ride" || got[1].Kilometers.Number != 2 {
+		t.Errorf("EddingtonBySport = %+v, want GravelRide E=3 then Ride E=2", got)
+	}
+}
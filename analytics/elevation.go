@@ -0,0 +1,307 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// SmoothingMethod selects how an altitude stream is smoothed
+type SmoothingMethod string
+
+const (
+	SmoothingNone          SmoothingMethod = "none"
+	SmoothingHysteresis    SmoothingMethod = "hysteresis"
+	SmoothingMovingAverage SmoothingMethod = "moving_average"
+	SmoothingSavitzkyGolay SmoothingMethod = "savitzky_golay"
+)
+
+// ElevationOptions contains options for elevation smoothing
+type ElevationOptions struct {
+	// Method is the smoothing method (default: moving average)
+	Method SmoothingMethod
+
+	// Window is the moving average window in meters (default: 100)
+	Window float64
+
+	// SGWindow is the Savitzky-Golay window length in samples, rounded up to
+	// an odd number (default: 11)
+	SGWindow int
+
+	// SGOrder is the Savitzky-Golay polynomial order (default: 2). A negative
+	// order fits a constant, which is a plain moving average over SGWindow.
+	SGOrder int
+
+	// Threshold is the hysteresis threshold in meters. A change in elevation
+	// only counts once it exceeds the threshold (default: 5 for the hysteresis
+	// method, otherwise 0).
+	Threshold float64
+
+	// GradeWindow is the distance in meters over which grade is recomputed (default: 50)
+	GradeWindow float64
+}
+
+// ElevationProfile is a smoothed altitude stream and the totals computed from it
+type ElevationProfile struct {
+	Altitude     []float64 `json:"altitude"` // smoothed, meters
+	Grade        []float64 `json:"grade"`    // recomputed, percent
+	Ascent       float64   `json:"ascent"`   // meters
+	Descent      float64   `json:"descent"`  // meters
+	MaxElevation float64   `json:"max_elevation"`
+	MinElevation float64   `json:"min_elevation"`
+}
+
+// ElevationComparison compares a computed ascent with the one Strava reports
+type ElevationComparison struct {
+	Computed          float64 `json:"computed"`
+	Reported          float64 `json:"reported"`
+	Difference        float64 `json:"difference"`         // computed minus reported, in meters
+	DifferencePercent float64 `json:"difference_percent"` // relative to the reported value
+}
+
+// CompareAscent compares the profile's ascent with a reported elevation gain,
+// such as Activity.TotalElevationGain, Route.ElevationGain or
+// Segment.TotalElevationGain
+func (p *ElevationProfile) CompareAscent(reported float64) ElevationComparison {
+	c := ElevationComparison{
+		Computed:   p.Ascent,
+		Reported:   reported,
+		Difference: p.Ascent - reported,
+	}
+	if reported != 0 {
+		c.DifferencePercent = c.Difference / reported * 100
+	}
+	return c
+}
+
+// SmoothElevation smooths the altitude stream and computes ascent, descent,
+// elevation range and a grade stream. It works on activity, route and segment
+// streams alike; grade is only computed when a distance stream is present.
+func SmoothElevation(streams *models.StreamSet, opts *ElevationOptions) *ElevationProfile {
+	if streams == nil || streams.Altitude == nil || len(streams.Altitude.Data) == 0 {
+		return nil
+	}
+
+	o := ElevationOptions{Method: SmoothingMovingAverage, Window: 100, SGWindow: 11, SGOrder: 2, GradeWindow: 50}
+	if opts != nil {
+		if opts.Method != "" {
+			o.Method = opts.Method
+		}
+		if opts.Window > 0 {
+			o.Window = opts.Window
+		}
+		if opts.SGWindow > 0 {
+			o.SGWindow = opts.SGWindow
+		}
+		if opts.SGOrder > 0 {
+			o.SGOrder = opts.SGOrder
+		} else if opts.SGOrder < 0 {
+			o.SGOrder = 0
+		}
+		if opts.GradeWindow > 0 {
+			o.GradeWindow = opts.GradeWindow
+		}
+		o.Threshold = opts.Threshold
+	}
+	if o.Method == SmoothingHysteresis && o.Threshold <= 0 {
+		o.Threshold = 5
+	}
+
+	raw := streams.Altitude.Data
+	var dist []float64
+	if streams.Distance != nil && len(streams.Distance.Data) == len(raw) {
+		dist = streams.Distance.Data
+	}
+
+	profile := &ElevationProfile{}
+	switch o.Method {
+	case SmoothingMovingAverage:
+		if dist != nil {
+			profile.Altitude = smoothByDistance(raw, dist, o.Window)
+		} else {
+			profile.Altitude = append([]float64(nil), raw...)
+		}
+	case SmoothingSavitzkyGolay:
+		profile.Altitude = savitzkyGolay(raw, o.SGWindow, o.SGOrder)
+	case SmoothingHysteresis:
+		profile.Altitude = hysteresis(raw, o.Threshold)
+	default:
+		profile.Altitude = append([]float64(nil), raw...)
+	}
+
+	profile.Ascent, profile.Descent = ascentDescent(profile.Altitude, o.Threshold)
+	profile.MinElevation, profile.MaxElevation = profile.Altitude[0], profile.Altitude[0]
+	for _, a := range profile.Altitude {
+		profile.MinElevation = math.Min(profile.MinElevation, a)
+		profile.MaxElevation = math.Max(profile.MaxElevation, a)
+	}
+
+	if dist != nil {
+		profile.Grade = gradeStream(profile.Altitude, dist, o.GradeWindow)
+	}
+
+	return profile
+}
+
+// ascentDescent sums elevation changes, counting a change only once it
+// exceeds the threshold from the last counted elevation
+func ascentDescent(alt []float64, threshold float64) (ascent, descent float64) {
+	ref := alt[0]
+	for _, a := range alt[1:] {
+		switch d := a - ref; {
+		case d > threshold:
+			ascent += d
+			ref = a
+		case -d > threshold:
+			descent -= d
+			ref = a
+		case threshold == 0:
+			ref = a
+		}
+	}
+	return ascent, descent
+}
+
+// hysteresis holds the altitude until it moves more than the threshold
+func hysteresis(values []float64, threshold float64) []float64 {
+	out := make([]float64, len(values))
+	ref := values[0]
+	for i, v := range values {
+		if math.Abs(v-ref) > threshold {
+			ref = v
+		}
+		out[i] = ref
+	}
+	return out
+}
+
+// gradeStream recomputes grade in percent over a centred distance window
+func gradeStream(alt, dist []float64, window float64) []float64 {
+	grade := make([]float64, len(alt))
+	lo, hi := 0, 0
+	for i := range alt {
+		for hi < len(alt)-1 && dist[hi]-dist[i] < window/2 {
+			hi++
+		}
+		for lo < i && dist[i]-dist[lo+1] >= window/2 {
+			lo++
+		}
+		grade[i] = utils.CalculateGrade(alt[hi]-alt[lo], dist[hi]-dist[lo])
+	}
+	return grade
+}
+
+// smoothByDistance applies a centred moving average over a distance window
+func smoothByDistance(values, dist []float64, window float64) []float64 {
+	smoothed := make([]float64, len(values))
+	if window <= 0 {
+		copy(smoothed, values)
+		return smoothed
+	}
+
+	lo, hi := 0, 0
+	var sum float64
+	for i := range values {
+		for hi < len(values) && dist[hi]-dist[i] <= window/2 {
+			sum += values[hi]
+			hi++
+		}
+		for dist[i]-dist[lo] > window/2 {
+			sum -= values[lo]
+			lo++
+		}
+		smoothed[i] = sum / float64(hi-lo)
+	}
+	return smoothed
+}
+
+// savitzkyGolay smooths evenly spaced samples with a least squares polynomial
+// fit over a sliding window, reflecting the series at its ends
+func savitzkyGolay(values []float64, window, order int) []float64 {
+	if window%2 == 0 {
+		window++
+	}
+	half := window / 2
+	if order >= window {
+		order = window - 1
+	}
+
+	coeffs := savitzkyGolayCoefficients(half, order)
+	n := len(values)
+	out := make([]float64, n)
+	for i := range values {
+		var sum float64
+		for k := -half; k <= half; k++ {
+			sum += coeffs[k+half] * values[reflectIndex(i+k, n)]
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+// reflectIndex mirrors an out of range index back into [0, n)
+func reflectIndex(i, n int) int {
+	if n == 1 {
+		return 0
+	}
+	for i < 0 || i >= n {
+		if i < 0 {
+			i = -i
+		}
+		if i >= n {
+			i = 2*(n-1) - i
+		}
+	}
+	return i
+}
+
+// savitzkyGolayCoefficients returns the smoothing weights for a window of
+// 2·half+1 samples, the first row of (JᵀJ)⁻¹Jᵀ for the Vandermonde matrix J
+func savitzkyGolayCoefficients(half, order int) []float64 {
+	size := order + 1
+
+	// Normal matrix JᵀJ augmented with the identity for Gauss-Jordan inversion
+	m := make([][]float64, size)
+	for r := range m {
+		m[r] = make([]float64, 2*size)
+		for c := 0; c < size; c++ {
+			for k := -half; k <= half; k++ {
+				m[r][c] += math.Pow(float64(k), float64(r+c))
+			}
+		}
+		m[r][size+r] = 1
+	}
+
+	for col := 0; col < size; col++ {
+		pivot := col
+		for r := col + 1; r < size; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+
+		p := m[col][col]
+		for c := range m[col] {
+			m[col][c] /= p
+		}
+		for r := 0; r < size; r++ {
+			if r == col {
+				continue
+			}
+			f := m[r][col]
+			for c := range m[r] {
+				m[r][c] -= f * m[col][c]
+			}
+		}
+	}
+
+	coeffs := make([]float64, 2*half+1)
+	for k := -half; k <= half; k++ {
+		for c := 0; c < size; c++ {
+			coeffs[k+half] += m[0][size+c] * math.Pow(float64(k), float64(c))
+		}
+	}
+	return coeffs
+}
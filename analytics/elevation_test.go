@@ -0,0 +1,47 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+func TestSmoothElevationSGOrder(t *testing.T) {
+	altitude := []float64{100, 104, 101, 110, 108, 115, 112, 120, 118, 125}
+	streams := &models.StreamSet{Altitude: &models.AltitudeStream{Data: altitude}}
+
+	tests := []struct {
+		name  string
+		order int
+		want  func(i int) float64
+	}{
+		{"default is quadratic", 0, func(i int) float64 {
+			// The quadratic 5-point Savitzky-Golay weights
+			w := []float64{-3, 12, 17, 12, -3}
+			var sum float64
+			for k := -2; k <= 2; k++ {
+				sum += w[k+2] * altitude[i+k]
+			}
+			return sum / 35
+		}},
+		{"negative is a moving average", -1, func(i int) float64 {
+			return (altitude[i-2] + altitude[i-1] + altitude[i] + altitude[i+1] + altitude[i+2]) / 5
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := SmoothElevation(streams, &ElevationOptions{
+				Method:   SmoothingSavitzkyGolay,
+				SGWindow: 5,
+				SGOrder:  tt.order,
+			})
+			for i := 2; i < len(altitude)-2; i++ {
+				if want := tt.want(i); math.Abs(profile.Altitude[i]-want) > 1e-9 {
+					t.Errorf("altitude[%d] = %v, want %v", i, profile.Altitude[i], want)
+				}
+			}
+		})
+	}
+}
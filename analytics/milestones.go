@@ -0,0 +1,137 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// MilestoneKind is what a milestone measures
+type MilestoneKind string
+
+const (
+	// MilestoneDistance is reached by a single activity of at least Value meters
+	MilestoneDistance MilestoneKind = "distance"
+
+	// MilestoneElevation is reached by a single activity climbing at least
+	// Value meters
+	MilestoneElevation MilestoneKind = "elevation"
+
+	// MilestoneTotalDistance is reached when the lifetime distance reaches
+	// Value meters
+	MilestoneTotalDistance MilestoneKind = "total_distance"
+
+	// MilestoneTotalElevation is reached when the lifetime elevation gain
+	// reaches Value meters
+	MilestoneTotalElevation MilestoneKind = "total_elevation"
+
+	// MilestoneCount is reached by the Value-th activity
+	MilestoneCount MilestoneKind = "count"
+)
+
+// Milestone is a lifetime achievement
+type Milestone struct {
+	Name  string              `json:"name"`
+	Kind  MilestoneKind       `json:"kind"`
+	Value float64             `json:"value"`          // meters, or activities for MilestoneCount
+	Type  models.ActivityType `json:"type,omitempty"` // only activities of this type count, or all when empty
+}
+
+// DefaultMilestones are common lifetime milestones
+var DefaultMilestones = []Milestone{
+	{Name: "First 100 km ride", Kind: MilestoneDistance, Value: 100000, Type: models.ActivityTypeRide},
+	{Name: "First 200 km ride", Kind: MilestoneDistance, Value: 200000, Type: models.ActivityTypeRide},
+	{Name: "First 1,000 m climb on a ride", Kind: MilestoneElevation, Value: 1000, Type: models.ActivityTypeRide},
+	{Name: "First 10 km run", Kind: MilestoneDistance, Value: 10000, Type: models.ActivityTypeRun},
+	{Name: "First half marathon", Kind: MilestoneDistance, Value: 21097.5, Type: models.ActivityTypeRun},
+	{Name: "First marathon", Kind: MilestoneDistance, Value: 42195, Type: models.ActivityTypeRun},
+	{Name: "1,000 km total", Kind: MilestoneTotalDistance, Value: 1000000},
+	{Name: "10,000 km total", Kind: MilestoneTotalDistance, Value: 10000000},
+	{Name: "Everest climbed in total", Kind: MilestoneTotalElevation, Value: 8848},
+	{Name: "100 activities", Kind: MilestoneCount, Value: 100},
+	{Name: "1,000 activities", Kind: MilestoneCount, Value: 1000},
+}
+
+// MilestoneStatus is the progress towards a milestone
+type MilestoneStatus struct {
+	Milestone
+
+	// Progress is the best single activity or the lifetime total so far, in
+	// the milestone's unit
+	Progress float64 `json:"progress"`
+
+	// Reached reports whether the milestone was reached, on the local start
+	// date of the activity that reached it
+	Reached    bool      `json:"reached"`
+	Date       time.Time `json:"date,omitzero"`
+	ActivityID int64     `json:"activity_id,omitempty"`
+}
+
+// Milestones returns the status of each milestone, in the order given,
+// going through the activities by local start date
+func Milestones(activities []*models.Activity, milestones []Milestone) []MilestoneStatus {
+	sorted := make([]*models.Activity, len(activities))
+	copy(sorted, activities)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return LocalStart(sorted[i]).Before(LocalStart(sorted[j]))
+	})
+
+	statuses := make([]MilestoneStatus, len(milestones))
+	for i, m := range milestones {
+		s := &statuses[i]
+		s.Milestone = m
+		for _, a := range sorted {
+			if m.Type != "" && a.Type != m.Type {
+				continue
+			}
+
+			switch m.Kind {
+			case MilestoneDistance:
+				s.Progress = max(s.Progress, a.Distance)
+			case MilestoneElevation:
+				s.Progress = max(s.Progress, a.TotalElevationGain)
+			case MilestoneTotalDistance:
+				s.Progress += a.Distance
+			case MilestoneTotalElevation:
+				s.Progress += a.TotalElevationGain
+			case MilestoneCount:
+				s.Progress++
+			}
+
+			if !s.Reached && s.Progress >= m.Value {
+				s.Reached, s.Date, s.ActivityID = true, Day(LocalStart(a)), a.ID
+			}
+		}
+	}
+	return statuses
+}
+
+// Achievements are the engagement metrics of an activity history
+type Achievements struct {
+	Eddington []SportEddington `json:"eddington"`
+
+	DailyStreak         Streak `json:"daily_streak"`
+	LongestDailyStreak  Streak `json:"longest_daily_streak"`
+	WeeklyStreak        Streak `json:"weekly_streak"`
+	LongestWeeklyStreak Streak `json:"longest_weekly_streak"`
+
+	Milestones []MilestoneStatus `json:"milestones"`
+}
+
+// ComputeAchievements computes the Eddington numbers, the streaks running at
+// now, a local time, and the longest streaks, and the status of milestones,
+// DefaultMilestones when nil
+func ComputeAchievements(activities []*models.Activity, now time.Time, milestones []Milestone) *Achievements {
+	if milestones == nil {
+		milestones = DefaultMilestones
+	}
+	return &Achievements{
+		Eddington:           EddingtonBySport(activities),
+		DailyStreak:         CurrentStreak(activities, PeriodDay, now),
+		LongestDailyStreak:  LongestStreak(activities, PeriodDay),
+		WeeklyStreak:        CurrentStreak(activities, PeriodWeek, now),
+		LongestWeeklyStreak: LongestStreak(activities, PeriodWeek),
+		Milestones:          Milestones(activities, milestones),
+	}
+}
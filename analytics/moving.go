@@ -0,0 +1,241 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// MovingThreshold returns the speed in m/s below which an athlete is treated
+// as stopped for an activity type
+func MovingThreshold(activityType models.ActivityType) float64 {
+	switch {
+	case utils.IsRideActivity(activityType):
+		return 1.0
+	case utils.IsSwimActivity(activityType):
+		return 0.1
+	case activityType == models.ActivityTypeWalk || activityType == models.ActivityTypeHike:
+		return 0.3
+	case utils.IsRunActivity(activityType), utils.IsWinterActivity(activityType):
+		return 0.5
+	case utils.IsWaterActivity(activityType):
+		return 0.3
+	default:
+		return minRunningSpeed
+	}
+}
+
+// MovingOptions contains options for moving time detection
+type MovingOptions struct {
+	// Threshold is the minimum moving speed in m/s (default: MovingThreshold
+	// for the activity type)
+	Threshold float64
+
+	// MinStopDuration is the shortest stop in seconds; shorter stops are
+	// counted as moving (default: 10)
+	MinStopDuration int
+
+	// GapThreshold is the time in seconds between samples above which the
+	// gap is treated as a recording pause (default: 10)
+	GapThreshold int
+}
+
+// Stop is a period in which the athlete was not moving
+type Stop struct {
+	StartIndex  int       `json:"start_index"`
+	EndIndex    int       `json:"end_index"`
+	StartOffset int       `json:"start_offset"` // seconds from the start of the activity
+	Duration    int       `json:"duration"`     // seconds
+	Latlng      []float64 `json:"latlng,omitempty"`
+}
+
+// MovingAnalysis is the result of moving time detection
+type MovingAnalysis struct {
+	// Mask reports whether the athlete moved during the interval ending at each sample
+	Mask        []bool  `json:"mask"`
+	MovingTime  int     `json:"moving_time"`  // seconds
+	ElapsedTime int     `json:"elapsed_time"` // seconds
+	Threshold   float64 `json:"threshold"`    // m/s
+	Stops       []Stop  `json:"stops"`
+
+	// ShortStopTime is the time in stops shorter than the minimum stop
+	// duration, counted as moving
+	ShortStopTime int `json:"short_stop_time"`
+
+	// GapTime is the time in recording pauses, counted as stopped unless
+	// distance was covered during the pause
+	GapTime int `json:"gap_time"`
+}
+
+// DetectMoving computes a moving mask, moving time and stops from the time
+// stream and the velocity, distance or latlng stream, whichever is present.
+// It does not use the moving stream, so it works on uploaded files without one.
+func DetectMoving(streams *models.StreamSet, activityType models.ActivityType, opts *MovingOptions) *MovingAnalysis {
+	if streams == nil || streams.Time == nil || len(streams.Time.Data) == 0 {
+		return nil
+	}
+
+	o := MovingOptions{Threshold: MovingThreshold(activityType), MinStopDuration: 10, GapThreshold: 10}
+	if opts != nil {
+		if opts.Threshold > 0 {
+			o.Threshold = opts.Threshold
+		}
+		if opts.MinStopDuration > 0 {
+			o.MinStopDuration = opts.MinStopDuration
+		}
+		if opts.GapThreshold > 0 {
+			o.GapThreshold = opts.GapThreshold
+		}
+	}
+
+	ts := streams.Time.Data
+	n := len(ts)
+	dist := cumulativeDistance(streams, n)
+
+	analysis := &MovingAnalysis{
+		Mask:        make([]bool, n),
+		ElapsedTime: ts[n-1] - ts[0],
+		Threshold:   o.Threshold,
+	}
+
+	// Classify each interval from its speed. Recording gaps always use the
+	// distance covered during the gap, since smoothed velocity lags behind.
+	for i := 1; i < n; i++ {
+		dt := ts[i] - ts[i-1]
+		var speed float64
+		switch {
+		case dt <= 0:
+			speed = 0
+		case dt > o.GapThreshold || streams.VelocitySmooth == nil || len(streams.VelocitySmooth.Data) != n:
+			if dist != nil {
+				speed = (dist[i] - dist[i-1]) / float64(dt)
+			}
+		default:
+			speed = streams.VelocitySmooth.Data[i]
+		}
+		analysis.Mask[i] = speed >= o.Threshold
+
+		if dt > o.GapThreshold && !analysis.Mask[i] {
+			analysis.GapTime += dt
+		}
+	}
+	if n > 1 {
+		analysis.Mask[0] = analysis.Mask[1]
+	}
+
+	// Collect stops, folding short ones back into moving time
+	for i := 1; i < n; i++ {
+		if analysis.Mask[i] {
+			continue
+		}
+		start := i
+		for i < n && !analysis.Mask[i] {
+			i++
+		}
+		end := i - 1
+
+		duration := ts[end] - ts[start-1]
+		if duration < o.MinStopDuration {
+			for k := start; k <= end; k++ {
+				analysis.Mask[k] = true
+			}
+			analysis.ShortStopTime += duration
+			continue
+		}
+
+		stop := Stop{
+			StartIndex:  start,
+			EndIndex:    end,
+			StartOffset: ts[start-1] - ts[0],
+			Duration:    duration,
+		}
+		if streams.LatLng != nil && len(streams.LatLng.Data) == n {
+			stop.Latlng = streams.LatLng.Data[start-1]
+		}
+		analysis.Stops = append(analysis.Stops, stop)
+	}
+
+	for i := 1; i < n; i++ {
+		if analysis.Mask[i] {
+			analysis.MovingTime += ts[i] - ts[i-1]
+		}
+	}
+
+	return analysis
+}
+
+// cumulativeDistance returns the distance stream, or one integrated from the
+// latlng stream, or nil when neither is present
+func cumulativeDistance(streams *models.StreamSet, n int) []float64 {
+	if streams.Distance != nil && len(streams.Distance.Data) == n {
+		return streams.Distance.Data
+	}
+	if streams.LatLng == nil || len(streams.LatLng.Data) != n {
+		return nil
+	}
+
+	dist := make([]float64, n)
+	for i := 1; i < n; i++ {
+		prev, cur := streams.LatLng.Data[i-1], streams.LatLng.Data[i]
+		dist[i] = dist[i-1]
+		if len(prev) == 2 && len(cur) == 2 {
+			dist[i] += utils.CalculateDistance(prev[0], prev[1], cur[0], cur[1])
+		}
+	}
+	return dist
+}
+
+// MovingTimeComparison explains the difference between a computed moving time
+// and the one Strava reports
+type MovingTimeComparison struct {
+	Computed   int      `json:"computed"`   // seconds
+	Strava     int      `json:"strava"`     // seconds
+	Difference int      `json:"difference"` // computed minus Strava, in seconds
+	Reasons    []string `json:"reasons"`
+}
+
+// CompareMovingTime compares the analysis with the activity's moving time and
+// lists the likely causes of any mismatch. When streams include Strava's own
+// moving stream, disagreements with it are counted too.
+func CompareMovingTime(analysis *MovingAnalysis, activity *models.Activity, streams *models.StreamSet) MovingTimeComparison {
+	c := MovingTimeComparison{
+		Computed: analysis.MovingTime,
+		Strava:   int(math.Round(activity.MovingTime)),
+	}
+	c.Difference = c.Computed - c.Strava
+	if c.Difference == 0 {
+		return c
+	}
+
+	c.Reasons = append(c.Reasons, fmt.Sprintf("speeds below %.1f m/s count as stopped for %s activities", analysis.Threshold, activity.Type))
+	if analysis.ShortStopTime > 0 {
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%d s of stops too short to count were treated as moving", analysis.ShortStopTime))
+	}
+	if analysis.GapTime > 0 {
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%d s of recording pauses were treated as stopped", analysis.GapTime))
+	}
+
+	if streams != nil && streams.Moving != nil && streams.Time != nil &&
+		len(streams.Moving.Data) == len(analysis.Mask) && len(streams.Time.Data) == len(analysis.Mask) {
+		var onlyUs, onlyStrava int
+		for i := 1; i < len(analysis.Mask); i++ {
+			dt := streams.Time.Data[i] - streams.Time.Data[i-1]
+			switch {
+			case analysis.Mask[i] && !streams.Moving.Data[i]:
+				onlyUs += dt
+			case !analysis.Mask[i] && streams.Moving.Data[i]:
+				onlyStrava += dt
+			}
+		}
+		if onlyUs > 0 {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("%d s counted as moving that Strava's moving stream marks as stopped", onlyUs))
+		}
+		if onlyStrava > 0 {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("%d s counted as stopped that Strava's moving stream marks as moving", onlyStrava))
+		}
+	}
+
+	return c
+}
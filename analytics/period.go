@@ -0,0 +1,43 @@
+package analytics
+
+import "time"
+
+// Period is a calendar period used to group activities
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+)
+
+// PeriodStart returns the first day of the period containing t. Weeks start on Monday.
+func PeriodStart(t time.Time, period Period) time.Time {
+	day := Day(t)
+	switch period {
+	case PeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case PeriodMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case PeriodYear:
+		return time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// NextPeriod returns the start of the period following the one that starts at start
+func NextPeriod(start time.Time, period Period) time.Time {
+	switch period {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	case PeriodYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
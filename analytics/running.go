@@ -0,0 +1,204 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+const (
+	// minRunningSpeed is the speed in m/s below which a runner is treated as stopped
+	minRunningSpeed = 0.5
+
+	// maxMinettiGrade bounds the grades over which Minetti's model was measured
+	maxMinettiGrade = 0.45
+)
+
+// RunningMetrics contains pace and efficiency metrics for a run
+type RunningMetrics struct {
+	MovingTime int     `json:"moving_time"` // seconds
+	Distance   float64 `json:"distance"`    // meters
+
+	AverageSpeed              float64 `json:"average_speed"`                // m/s
+	AverageGradeAdjustedSpeed float64 `json:"average_grade_adjusted_speed"` // m/s
+	PacePerKilometer          int     `json:"pace_per_kilometer"`           // seconds
+	PacePerMile               int     `json:"pace_per_mile"`                // seconds
+	GAPPerKilometer           int     `json:"gap_per_kilometer"`            // seconds
+	GAPPerMile                int     `json:"gap_per_mile"`                 // seconds
+
+	// GradeAdjustedSpeed is the grade-adjusted speed of every sample in m/s
+	GradeAdjustedSpeed []float64 `json:"grade_adjusted_speed"`
+
+	Cadence *CadenceStats `json:"cadence,omitempty"`
+
+	// Decoupling is the percentage drop in grade-adjusted speed per heartbeat
+	// from the first to the second half of the run (Pa:HR)
+	Decoupling float64 `json:"decoupling"`
+
+	// EfficiencyIndex is the grade-adjusted speed in meters per minute per heartbeat
+	EfficiencyIndex float64 `json:"efficiency_index"`
+}
+
+// FormatPace returns the pace per kilometer formatted as MM:SS
+func (m *RunningMetrics) FormatPace() string {
+	return utils.FormatPace(m.PacePerKilometer)
+}
+
+// FormatGAP returns the grade-adjusted pace per kilometer formatted as MM:SS
+func (m *RunningMetrics) FormatGAP() string {
+	return utils.FormatPace(m.GAPPerKilometer)
+}
+
+// CadenceStats contains running cadence statistics in steps per minute
+type CadenceStats struct {
+	Average float64 `json:"average"`
+	Max     int     `json:"max"`
+	StdDev  float64 `json:"std_dev"`
+}
+
+// MinettiCost returns the energy cost of running in J/kg/m at a grade given as
+// a fraction, from Minetti et al. (2002)
+func MinettiCost(grade float64) float64 {
+	i := math.Max(-maxMinettiGrade, math.Min(maxMinettiGrade, grade))
+	return 155.4*math.Pow(i, 5) - 30.4*math.Pow(i, 4) - 43.3*math.Pow(i, 3) + 46.3*i*i + 19.5*i + 3.6
+}
+
+// GradeAdjustedSpeed converts speeds in m/s to the equivalent flat-ground speed
+// using grades in percent, as found in the grade_smooth stream
+func GradeAdjustedSpeed(velocity []float64, grade []float64) []float64 {
+	flat := MinettiCost(0)
+	adjusted := make([]float64, len(velocity))
+	for i, v := range velocity {
+		if i < len(grade) {
+			adjusted[i] = v * MinettiCost(grade[i]/100) / flat
+		} else {
+			adjusted[i] = v
+		}
+	}
+	return adjusted
+}
+
+// CalculateRunningMetrics computes grade-adjusted pace, cadence and heart rate
+// efficiency from a run's streams. Without a moving stream, stops are found
+// with the moving threshold of the activity's sport; a nil activity is treated
+// as a run. It returns nil without a velocity stream.
+func CalculateRunningMetrics(activity *models.Activity, streams *models.StreamSet) *RunningMetrics {
+	if streams == nil || streams.VelocitySmooth == nil || len(streams.VelocitySmooth.Data) == 0 {
+		return nil
+	}
+
+	velocity := streams.VelocitySmooth.Data
+	var grade []float64
+	if streams.GradeSmooth != nil {
+		grade = streams.GradeSmooth.Data
+	}
+
+	metrics := &RunningMetrics{GradeAdjustedSpeed: GradeAdjustedSpeed(velocity, grade)}
+	intervals := sampleIntervals(streams.Time, len(velocity))
+
+	activityType := models.ActivityTypeRun
+	if activity != nil {
+		activityType = models.ActivityType(Sport(activity))
+	}
+	moving := movingMask(streams, activityType, len(velocity))
+
+	var movingTime, distance, gapDistance float64
+	for i, v := range velocity {
+		if !moving[i] {
+			continue
+		}
+
+		movingTime += intervals[i]
+		distance += v * intervals[i]
+		gapDistance += metrics.GradeAdjustedSpeed[i] * intervals[i]
+	}
+
+	if movingTime == 0 {
+		return metrics
+	}
+
+	metrics.MovingTime = int(math.Round(movingTime))
+	metrics.Distance = distance
+	metrics.AverageSpeed = distance / movingTime
+	metrics.AverageGradeAdjustedSpeed = gapDistance / movingTime
+	metrics.PacePerKilometer = utils.CalculatePacePerKilometer(distance, metrics.MovingTime)
+	metrics.PacePerMile = utils.CalculatePacePerMile(distance, metrics.MovingTime)
+	metrics.GAPPerKilometer = utils.CalculatePacePerKilometer(gapDistance, metrics.MovingTime)
+	metrics.GAPPerMile = utils.CalculatePacePerMile(gapDistance, metrics.MovingTime)
+
+	if streams.Cadence != nil {
+		metrics.Cadence = cadenceStats(streams.Cadence.Data, moving, intervals)
+	}
+
+	if streams.Heartrate != nil && len(streams.Heartrate.Data) == len(velocity) {
+		hr := streams.Heartrate.Data
+
+		// Split the run into halves of equal moving time, counting samples
+		// without heart rate towards the split but not the efficiency
+		var elapsed float64
+		var halves [2]struct{ distance, beats float64 }
+		for i := range velocity {
+			if !moving[i] {
+				continue
+			}
+			half := 0
+			if elapsed >= movingTime/2 {
+				half = 1
+			}
+			elapsed += intervals[i]
+			if hr[i] <= 0 {
+				continue
+			}
+
+			halves[half].distance += metrics.GradeAdjustedSpeed[i] * intervals[i]
+			halves[half].beats += float64(hr[i]) * intervals[i]
+		}
+
+		first := efficiency(halves[0].distance, halves[0].beats)
+		second := efficiency(halves[1].distance, halves[1].beats)
+		if first > 0 {
+			metrics.Decoupling = (first - second) / first * 100
+		}
+		metrics.EfficiencyIndex = efficiency(halves[0].distance+halves[1].distance, halves[0].beats+halves[1].beats)
+	}
+
+	return metrics
+}
+
+// efficiency returns speed in meters per minute divided by average heart rate,
+// given the meters covered and the time-weighted heart rate sum
+func efficiency(meters, beatSeconds float64) float64 {
+	if beatSeconds == 0 {
+		return 0
+	}
+	return meters * 60 / beatSeconds
+}
+
+// cadenceStats returns cadence statistics over moving samples. Strava reports
+// running cadence per leg, so values are doubled to steps per minute.
+func cadenceStats(cadence []int, moving []bool, intervals []float64) *CadenceStats {
+	var sum, sumSquares, total float64
+	stats := &CadenceStats{}
+	for i, c := range cadence {
+		if i >= len(moving) || !moving[i] || c <= 0 {
+			continue
+		}
+
+		spm := float64(c * 2)
+		sum += spm * intervals[i]
+		sumSquares += spm * spm * intervals[i]
+		total += intervals[i]
+		if c*2 > stats.Max {
+			stats.Max = c * 2
+		}
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	stats.Average = sum / total
+	stats.StdDev = math.Sqrt(math.Max(0, sumSquares/total-stats.Average*stats.Average))
+	return stats
+}
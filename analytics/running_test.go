@@ -0,0 +1,71 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// runStreams returns n samples at 1 s intervals with the given speed and
+// heart rate at each sample
+func runStreams(n int, speed func(i int) float64, hr func(i int) int) *models.StreamSet {
+	s := &models.StreamSet{
+		Time:           &models.TimeStream{},
+		VelocitySmooth: &models.VelocityStream{},
+		Heartrate:      &models.HeartrateStream{},
+	}
+	for i := 0; i < n; i++ {
+		s.Time.Data = append(s.Time.Data, i)
+		s.VelocitySmooth.Data = append(s.VelocitySmooth.Data, speed(i))
+		s.Heartrate.Data = append(s.Heartrate.Data, hr(i))
+	}
+	return s
+}
+
+func TestRunningDecouplingHalves(t *testing.T) {
+	// 3 m/s for the first half and 2.7 m/s for the second at 150 bpm, with
+	// no heart rate for the first 50 s. The halves split at 100 s of moving
+	// time whether or not a sample has heart rate.
+	streams := runStreams(200,
+		func(i int) float64 { return 3 - 0.3*float64(i/100) },
+		func(i int) int { return 150 * min(1, i/50) },
+	)
+
+	metrics := CalculateRunningMetrics(nil, streams)
+	if metrics.MovingTime != 200 {
+		t.Fatalf("moving time = %d s, want 200 s", metrics.MovingTime)
+	}
+	if math.Abs(metrics.Decoupling-10) > 1e-9 {
+		t.Errorf("decoupling = %v%%, want 10%%", metrics.Decoupling)
+	}
+}
+
+func TestRunningMovingThreshold(t *testing.T) {
+	// 3 m/s for 100 s and then 0.8 m/s, which is moving on foot but
+	// stopped on a bike
+	streams := runStreams(200,
+		func(i int) float64 { return 3 - 2.2*float64(i/100) },
+		func(i int) int { return 150 },
+	)
+
+	tests := []struct {
+		name       string
+		activity   *models.Activity
+		movingTime int
+	}{
+		{"no activity", nil, 200},
+		{"run", &models.Activity{Type: models.ActivityTypeRun}, 200},
+		{"ride", &models.Activity{Type: models.ActivityTypeRide}, 100},
+		{"ride sport type", &models.Activity{Type: models.ActivityTypeRun, SportType: "Ride"}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := CalculateRunningMetrics(tt.activity, streams)
+			if math.Abs(float64(metrics.MovingTime-tt.movingTime)) > 1 {
+				t.Errorf("moving time = %d s, want %d s", metrics.MovingTime, tt.movingTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// StreamSplit is a split computed from streams. It embeds models.Split so it
+// can be used wherever Strava's own splits are.
+type StreamSplit struct {
+	models.Split
+	AverageWatts float64 `json:"average_watts"`
+	StartIndex   int     `json:"start_index"`
+	EndIndex     int     `json:"end_index"`
+}
+
+// SplitsByDistance splits an activity every meters, with a final partial split
+func SplitsByDistance(streams *models.StreamSet, meters float64) []StreamSplit {
+	if meters <= 0 || !hasDistance(streams) {
+		return nil
+	}
+
+	dist := streams.Distance.Data
+	var markers []float64
+	for d := dist[0] + meters; d < dist[len(dist)-1]; d += meters {
+		markers = append(markers, d)
+	}
+	return splitAt(streams, boundariesAt(dist, markers))
+}
+
+// SplitsByTime splits an activity every seconds of elapsed time, with a final partial split
+func SplitsByTime(streams *models.StreamSet, seconds int) []StreamSplit {
+	if seconds <= 0 || !hasDistance(streams) || streams.Time == nil || len(streams.Time.Data) != len(streams.Distance.Data) {
+		return nil
+	}
+
+	ts := streams.Time.Data
+	elapsed := make([]float64, len(ts))
+	for i, t := range ts {
+		elapsed[i] = float64(t)
+	}
+
+	var markers []float64
+	for t := elapsed[0] + float64(seconds); t < elapsed[len(elapsed)-1]; t += float64(seconds) {
+		markers = append(markers, t)
+	}
+	return splitAt(streams, boundariesAt(elapsed, markers))
+}
+
+// SplitsAtDistances splits an activity at custom distance markers in meters
+func SplitsAtDistances(streams *models.StreamSet, markers []float64) []StreamSplit {
+	if !hasDistance(streams) {
+		return nil
+	}
+
+	sorted := append([]float64(nil), markers...)
+	sort.Float64s(sorted)
+	return splitAt(streams, boundariesAt(streams.Distance.Data, sorted))
+}
+
+// hasDistance reports whether the stream set has a usable distance stream
+func hasDistance(streams *models.StreamSet) bool {
+	return streams != nil && streams.Distance != nil && len(streams.Distance.Data) > 1
+}
+
+// boundariesAt returns the first sample index at or beyond each marker of a
+// non-decreasing series, bracketed by the first and last sample
+func boundariesAt(series []float64, markers []float64) []int {
+	bounds := []int{0}
+	i := 0
+	for _, m := range markers {
+		for i < len(series)-1 && series[i] < m {
+			i++
+		}
+		if i > bounds[len(bounds)-1] && i < len(series)-1 {
+			bounds = append(bounds, i)
+		}
+	}
+	return append(bounds, len(series)-1)
+}
+
+// splitAt builds splits between consecutive boundary indices
+func splitAt(streams *models.StreamSet, bounds []int) []StreamSplit {
+	n := len(streams.Distance.Data)
+	moving := movingMask(streams, "", n)
+	intervals := sampleIntervals(streams.Time, n)
+
+	var splits []StreamSplit
+	for k := 1; k < len(bounds); k++ {
+		s := summarizeRange(streams, moving, intervals, bounds[k-1], bounds[k])
+		splits = append(splits, StreamSplit{
+			Split: models.Split{
+				Distance:            s.distance,
+				ElapsedTime:         s.elapsedTime,
+				MovingTime:          s.movingTime,
+				ElevationDifference: s.elevationDifference,
+				SplitNumber:         k,
+				AverageSpeed:        s.averageSpeed,
+				AverageHeartrate:    s.averageHeartrate,
+			},
+			AverageWatts: s.averageWatts,
+			StartIndex:   bounds[k-1],
+			EndIndex:     bounds[k],
+		})
+	}
+	return splits
+}
+
+// IntervalMetric selects the signal used to detect intervals
+type IntervalMetric string
+
+const (
+	IntervalMetricPower IntervalMetric = "power"
+	IntervalMetricSpeed IntervalMetric = "speed"
+)
+
+// IntervalOptions contains options for interval detection
+type IntervalOptions struct {
+	// Metric is the signal to detect step changes in (default: power when
+	// there is a power stream, otherwise speed)
+	Metric IntervalMetric
+
+	// Smoothing is the rolling average window in samples applied before
+	// detection (default: 10)
+	Smoothing int
+
+	// MinDuration is the shortest interval in seconds; shorter ones are merged
+	// into the preceding interval (default: 30)
+	MinDuration int
+}
+
+// DetectIntervals splits an activity into alternating work and recovery laps
+// from step changes in power or pace. Samples are classified as work or
+// recovery around a threshold found by two-cluster k-means.
+func DetectIntervals(streams *models.StreamSet, opts *IntervalOptions) []models.Lap {
+	if streams == nil {
+		return nil
+	}
+
+	o := IntervalOptions{Smoothing: 10, MinDuration: 30}
+	if opts != nil {
+		o.Metric = opts.Metric
+		if opts.Smoothing > 0 {
+			o.Smoothing = opts.Smoothing
+		}
+		if opts.MinDuration > 0 {
+			o.MinDuration = opts.MinDuration
+		}
+	}
+
+	var signal []float64
+	switch {
+	case o.Metric != IntervalMetricSpeed && streams.Watts != nil && len(streams.Watts.Data) > 0:
+		signal = make([]float64, len(streams.Watts.Data))
+		for i, w := range streams.Watts.Data {
+			signal[i] = float64(w)
+		}
+	case o.Metric != IntervalMetricPower && streams.VelocitySmooth != nil && len(streams.VelocitySmooth.Data) > 0:
+		signal = streams.VelocitySmooth.Data
+	default:
+		return nil
+	}
+	if len(signal) < 2 {
+		return nil
+	}
+
+	smoothed := rollingMean(signal, o.Smoothing)
+	threshold := kMeansThreshold(smoothed)
+
+	// Contiguous runs of work or recovery samples
+	type run struct {
+		start, end int
+		work       bool
+	}
+	var runs []run
+	for i, v := range smoothed {
+		work := v >= threshold
+		if len(runs) == 0 || runs[len(runs)-1].work != work {
+			if len(runs) > 0 {
+				runs[len(runs)-1].end = i
+			}
+			runs = append(runs, run{start: i, work: work})
+		}
+	}
+	runs[len(runs)-1].end = len(smoothed) - 1
+
+	offset := func(i int) int {
+		if streams.Time != nil && len(streams.Time.Data) == len(signal) {
+			return streams.Time.Data[i]
+		}
+		return i
+	}
+
+	// Merge runs that are too short into the one before, then merge
+	// neighbours that ended up with the same classification
+	var merged []run
+	for _, r := range runs {
+		if len(merged) > 0 && (offset(r.end)-offset(r.start) < o.MinDuration || merged[len(merged)-1].work == r.work) {
+			merged[len(merged)-1].end = r.end
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	moving := movingMask(streams, "", len(signal))
+	intervals := sampleIntervals(streams.Time, len(signal))
+
+	laps := make([]models.Lap, len(merged))
+	var work, recovery int
+	for i, r := range merged {
+		s := summarizeRange(streams, moving, intervals, r.start, r.end)
+		name := ""
+		if r.work {
+			work++
+			name = fmt.Sprintf("Work %d", work)
+		} else {
+			recovery++
+			name = fmt.Sprintf("Recovery %d", recovery)
+		}
+
+		laps[i] = models.Lap{
+			Name:               name,
+			ElapsedTime:        s.elapsedTime,
+			MovingTime:         s.movingTime,
+			Distance:           s.distance,
+			StartIndex:         r.start,
+			EndIndex:           r.end,
+			TotalElevationGain: s.elevationGain,
+			AverageSpeed:       s.averageSpeed,
+			MaxSpeed:           s.maxSpeed,
+			AverageCadence:     s.averageCadence,
+			DeviceWatts:        streams.Watts != nil,
+			AverageWatts:       s.averageWatts,
+			AverageHeartrate:   s.averageHeartrate,
+			MaxHeartrate:       s.maxHeartrate,
+			LapIndex:           i + 1,
+		}
+	}
+	return laps
+}
+
+// rangeSummary contains totals and averages over a range of samples
+type rangeSummary struct {
+	elapsedTime         float64
+	movingTime          float64
+	distance            float64
+	elevationDifference float64
+	elevationGain       float64
+	averageSpeed        float64
+	maxSpeed            float64
+	averageHeartrate    float64
+	maxHeartrate        int
+	averageWatts        float64
+	averageCadence      float64
+}
+
+// summarizeRange summarizes samples start through end. Each sample after the
+// first contributes the interval since the previous one, so adjacent ranges
+// sharing a boundary add up to the whole activity.
+func summarizeRange(streams *models.StreamSet, moving []bool, intervals []float64, start, end int) rangeSummary {
+	var s rangeSummary
+	n := len(intervals)
+
+	if streams.Time != nil && len(streams.Time.Data) == n {
+		s.elapsedTime = float64(streams.Time.Data[end] - streams.Time.Data[start])
+	} else {
+		s.elapsedTime = float64(end - start)
+	}
+	if streams.Distance != nil && len(streams.Distance.Data) == n {
+		s.distance = streams.Distance.Data[end] - streams.Distance.Data[start]
+	}
+	if streams.Altitude != nil && len(streams.Altitude.Data) == n {
+		alt := streams.Altitude.Data
+		s.elevationDifference = alt[end] - alt[start]
+		for i := start + 1; i <= end; i++ {
+			if d := alt[i] - alt[i-1]; d > 0 {
+				s.elevationGain += d
+			}
+		}
+	}
+
+	var hrTime, hrSum, wattsTime, wattsSum, cadenceTime, cadenceSum float64
+	for i := start + 1; i <= end; i++ {
+		if !moving[i] {
+			continue
+		}
+		dt := intervals[i]
+		s.movingTime += dt
+
+		if streams.VelocitySmooth != nil && len(streams.VelocitySmooth.Data) == n {
+			s.maxSpeed = math.Max(s.maxSpeed, streams.VelocitySmooth.Data[i])
+		}
+		if streams.Heartrate != nil && len(streams.Heartrate.Data) == n && streams.Heartrate.Data[i] > 0 {
+			hr := streams.Heartrate.Data[i]
+			hrSum += float64(hr) * dt
+			hrTime += dt
+			if hr > s.maxHeartrate {
+				s.maxHeartrate = hr
+			}
+		}
+		if streams.Watts != nil && len(streams.Watts.Data) == n {
+			wattsSum += float64(streams.Watts.Data[i]) * dt
+			wattsTime += dt
+		}
+		if streams.Cadence != nil && len(streams.Cadence.Data) == n {
+			cadenceSum += float64(streams.Cadence.Data[i]) * dt
+			cadenceTime += dt
+		}
+	}
+
+	if s.movingTime > 0 {
+		s.averageSpeed = s.distance / s.movingTime
+	}
+	if hrTime > 0 {
+		s.averageHeartrate = hrSum / hrTime
+	}
+	if wattsTime > 0 {
+		s.averageWatts = wattsSum / wattsTime
+	}
+	if cadenceTime > 0 {
+		s.averageCadence = cadenceSum / cadenceTime
+	}
+	return s
+}
+
+// movingMask returns whether each of n samples was moving, from the moving
+// stream when present and otherwise from moving time detection with the
+// activity type's threshold. When neither has n samples, such as when the
+// time stream is shorter than the distance stream, it falls back to the
+// velocity of each sample, or to all moving.
+func movingMask(streams *models.StreamSet, activityType models.ActivityType, n int) []bool {
+	if streams.Moving != nil && len(streams.Moving.Data) == n {
+		return streams.Moving.Data
+	}
+	if analysis := DetectMoving(streams, activityType, nil); analysis != nil && len(analysis.Mask) == n {
+		return analysis.Mask
+	}
+
+	mask := make([]bool, n)
+	for i := range mask {
+		mask[i] = streams.VelocitySmooth == nil || len(streams.VelocitySmooth.Data) != n ||
+			streams.VelocitySmooth.Data[i] >= MovingThreshold(activityType)
+	}
+	return mask
+}
+
+// rollingMean returns the centred rolling average over window samples
+func rollingMean(values []float64, window int) []float64 {
+	sums := make([]float64, len(values)+1)
+	for i, v := range values {
+		sums[i+1] = sums[i] + v
+	}
+
+	out := make([]float64, len(values))
+	for i := range values {
+		lo := max(0, i-window/2)
+		hi := min(len(values), lo+window)
+		out[i] = (sums[hi] - sums[lo]) / float64(hi-lo)
+	}
+	return out
+}
+
+// kMeansThreshold splits values into two clusters and returns the midpoint
+// between the cluster means
+func kMeansThreshold(values []float64) float64 {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	threshold := (lo + hi) / 2
+	for iter := 0; iter < 50; iter++ {
+		var lowSum, highSum float64
+		var lowCount, highCount int
+		for _, v := range values {
+			if v >= threshold {
+				highSum += v
+				highCount++
+			} else {
+				lowSum += v
+				lowCount++
+			}
+		}
+		if lowCount == 0 || highCount == 0 {
+			break
+		}
+
+		next := (lowSum/float64(lowCount) + highSum/float64(highCount)) / 2
+		if math.Abs(next-threshold) < 1e-9 {
+			break
+		}
+		threshold = next
+	}
+	return threshold
+}
@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// rideStreams returns n samples of a steady 5 m/s ride with 1 s intervals,
+// alternating 60 s at 300 W and 60 s at 100 W
+func rideStreams(n int) *models.StreamSet {
+	s := &models.StreamSet{
+		Time:           &models.TimeStream{},
+		Distance:       &models.DistanceStream{},
+		VelocitySmooth: &models.VelocityStream{},
+		Watts:          &models.PowerStream{},
+		Moving:         &models.MovingStream{},
+	}
+	for i := 0; i < n; i++ {
+		s.Time.Data = append(s.Time.Data, i)
+		s.Distance.Data = append(s.Distance.Data, float64(i)*5)
+		s.VelocitySmooth.Data = append(s.VelocitySmooth.Data, 5)
+		s.Watts.Data = append(s.Watts.Data, 100+200*((i/60+1)%2))
+		s.Moving.Data = append(s.Moving.Data, true)
+	}
+	return s
+}
+
+func TestSplitsLengthMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *models.StreamSet)
+	}{
+		{"matching", func(s *models.StreamSet) {}},
+		{"short time", func(s *models.StreamSet) { s.Time.Data = s.Time.Data[:100] }},
+		{"long time", func(s *models.StreamSet) { s.Time.Data = append(s.Time.Data, 600, 601) }},
+		{"no time", func(s *models.StreamSet) { s.Time = nil }},
+		{"short moving", func(s *models.StreamSet) { s.Moving.Data = s.Moving.Data[:10] }},
+		{"short time and no moving", func(s *models.StreamSet) {
+			s.Time.Data = s.Time.Data[:100]
+			s.Moving = nil
+		}},
+		{"short velocity", func(s *models.StreamSet) { s.VelocitySmooth.Data = s.VelocitySmooth.Data[:50] }},
+		{"short watts", func(s *models.StreamSet) { s.Watts.Data = s.Watts.Data[:200] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := rideStreams(480)
+			tt.modify(s)
+			total := s.Distance.Data[len(s.Distance.Data)-1]
+
+			for name, splits := range map[string][]StreamSplit{
+				"SplitsByDistance":  SplitsByDistance(s, 1000),
+				"SplitsAtDistances": SplitsAtDistances(s, []float64{500, 1500}),
+			} {
+				var distance float64
+				for _, split := range splits {
+					distance += split.Distance
+				}
+				if math.Abs(distance-total) > 1e-9 {
+					t.Errorf("%s: distance = %v, want %v", name, distance, total)
+				}
+			}
+
+			splits := SplitsByTime(s, 60)
+			if s.Time != nil && len(s.Time.Data) == len(s.Distance.Data) {
+				if len(splits) != 8 {
+					t.Errorf("SplitsByTime: %d splits, want 8", len(splits))
+				}
+			} else if splits != nil {
+				t.Errorf("SplitsByTime: %d splits, want nil on a length mismatch", len(splits))
+			}
+
+			laps := DetectIntervals(s, &IntervalOptions{Smoothing: 1})
+			if len(laps) == 0 {
+				t.Fatal("DetectIntervals: no laps")
+			}
+			if last := laps[len(laps)-1]; last.EndIndex != len(s.Watts.Data)-1 {
+				t.Errorf("DetectIntervals: last lap ends at %d, want %d", last.EndIndex, len(s.Watts.Data)-1)
+			}
+		})
+	}
+}
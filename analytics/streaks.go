@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Streak is a run of consecutive calendar periods with at least one activity
+type Streak struct {
+	Start  time.Time `json:"start"`  // start of the first period
+	End    time.Time `json:"end"`    // start of the last period
+	Length int       `json:"length"` // number of periods
+}
+
+// Streaks returns every streak of consecutive periods with activities, by
+// local start date, in chronological order
+func Streaks(activities []*models.Activity, period Period) []Streak {
+	active := make(map[time.Time]bool)
+	for _, a := range activities {
+		active[PeriodStart(LocalStart(a), period)] = true
+	}
+	starts := make([]time.Time, 0, len(active))
+	for start := range active {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	var streaks []Streak
+	for _, start := range starts {
+		if n := len(streaks); n > 0 && NextPeriod(streaks[n-1].End, period).Equal(start) {
+			streaks[n-1].End = start
+			streaks[n-1].Length++
+			continue
+		}
+		streaks = append(streaks, Streak{Start: start, End: start, Length: 1})
+	}
+	return streaks
+}
+
+// CurrentStreak returns the streak still running at t, a local time: the
+// streak that reaches the period containing t, or the period before it as
+// the current period may not have had an activity yet. Activities after t
+// are ignored. It returns a zero streak when there is none.
+func CurrentStreak(activities []*models.Activity, period Period, t time.Time) Streak {
+	current := PeriodStart(t, period)
+	var before []*models.Activity
+	for _, a := range activities {
+		if !LocalStart(a).After(t) {
+			before = append(before, a)
+		}
+	}
+
+	streaks := Streaks(before, period)
+	if len(streaks) == 0 {
+		return Streak{}
+	}
+	last := streaks[len(streaks)-1]
+	if last.End.Equal(current) || NextPeriod(last.End, period).Equal(current) {
+		return last
+	}
+	return Streak{}
+}
+
+// LongestStreak returns the longest streak, the earliest one when several
+// are as long, or a zero streak when there are no activities
+func LongestStreak(activities []*models.Activity, period Period) Streak {
+	var longest Streak
+	for _, s := range Streaks(activities, period) {
+		if s.Length > longest.Length {
+			longest = s
+		}
+	}
+	return longest
+}
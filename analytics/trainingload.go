@@ -0,0 +1,294 @@
+package analytics
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/services"
+)
+
+const (
+	// DefaultFitnessDays is the default time constant for chronic training load (CTL)
+	DefaultFitnessDays = 42
+
+	// DefaultFatigueDays is the default time constant for acute training load (ATL)
+	DefaultFatigueDays = 7
+
+	// maxSampleGap caps the weight of a single stream sample so paused recordings
+	// don't count the pause as effort
+	maxSampleGap = 30
+)
+
+// StressSource identifies how a stress score was computed
+type StressSource string
+
+const (
+	StressSourcePower       StressSource = "power"
+	StressSourceHeartrate   StressSource = "heartrate"
+	StressSourceSufferScore StressSource = "suffer_score"
+	StressSourceNone        StressSource = "none"
+)
+
+// Thresholds contains the athlete's physiological thresholds used for stress scoring
+type Thresholds struct {
+	FTP              int    // functional threshold power in watts
+	MaxHeartrate     int    // maximum heart rate in bpm
+	RestingHeartrate int    // resting heart rate in bpm
+	Sex              string // M or F, selects the TRIMP weighting (default: M)
+}
+
+// StressScore represents the training stress of a single activity
+type StressScore struct {
+	ActivityID int64        `json:"activity_id"`
+	Date       time.Time    `json:"date"`
+	Score      float64      `json:"score"`
+	Source     StressSource `json:"source"`
+}
+
+// TrainingLoadOptions contains options for the training load model
+type TrainingLoadOptions struct {
+	// FitnessDays is the CTL time constant in days (default: 42)
+	FitnessDays float64
+
+	// FatigueDays is the ATL time constant in days (default: 7)
+	FatigueDays float64
+
+	// InitialFitness and InitialFatigue seed the model on its first day
+	InitialFitness float64
+	InitialFatigue float64
+}
+
+// TrainingLoadDay represents a single day of the performance management chart
+type TrainingLoadDay struct {
+	Date    time.Time `json:"date"`
+	Stress  float64   `json:"stress"`
+	Fitness float64   `json:"fitness"` // CTL
+	Fatigue float64   `json:"fatigue"` // ATL
+	Form    float64   `json:"form"`    // TSB, yesterday's fitness minus fatigue
+}
+
+// ActivityStress scores an activity from power TSS, falling back to heart rate
+// TRIMP and then to Strava's suffer score. Streams may be nil.
+func ActivityStress(activity *models.Activity, streams *models.StreamSet, t Thresholds) StressScore {
+	score := StressScore{
+		ActivityID: activity.ID,
+		Date:       Day(LocalStart(activity)),
+		Source:     StressSourceNone,
+	}
+
+	if tss := PowerTSS(activity, streams, t.FTP); tss > 0 {
+		score.Score = tss
+		score.Source = StressSourcePower
+		return score
+	}
+
+	if trimp := HeartrateTRIMP(activity, streams, t); trimp > 0 {
+		score.Score = trimp
+		score.Source = StressSourceHeartrate
+		return score
+	}
+
+	if activity.SufferScore > 0 {
+		score.Score = float64(activity.SufferScore)
+		score.Source = StressSourceSufferScore
+	}
+
+	return score
+}
+
+// PowerTSS calculates Training Stress Score from the power stream, or from
+// Strava's weighted average watts when no stream is available
+func PowerTSS(activity *models.Activity, streams *models.StreamSet, ftp int) float64 {
+	if ftp <= 0 {
+		return 0
+	}
+
+	if streams != nil && streams.Watts != nil && len(streams.Watts.Data) > 0 {
+		np := utils.CalculateNormalizedPower(streams.Watts.Data)
+		duration := int(activity.MovingTime)
+		if streams.Time != nil && len(streams.Time.Data) > 1 {
+			duration = streams.Time.Data[len(streams.Time.Data)-1] - streams.Time.Data[0]
+		}
+		return utils.CalculateTSS(np, utils.CalculateIntensityFactor(np, ftp), duration, ftp)
+	}
+
+	if activity.DeviceWatts && activity.WeightedAverageWatts > 0 {
+		np := float64(activity.WeightedAverageWatts)
+		return utils.CalculateTSS(np, utils.CalculateIntensityFactor(np, ftp), int(activity.MovingTime), ftp)
+	}
+
+	return 0
+}
+
+// HeartrateTRIMP calculates Banister's TRIMP from the heart rate stream, or from
+// the activity's average heart rate when no stream is available
+func HeartrateTRIMP(activity *models.Activity, streams *models.StreamSet, t Thresholds) float64 {
+	if t.MaxHeartrate <= t.RestingHeartrate || t.RestingHeartrate < 0 {
+		return 0
+	}
+
+	if streams != nil && streams.Heartrate != nil && len(streams.Heartrate.Data) > 0 {
+		intervals := sampleIntervals(streams.Time, len(streams.Heartrate.Data))
+
+		var trimp float64
+		for i, hr := range streams.Heartrate.Data {
+			trimp += intervals[i] / 60 * trimpWeight(float64(hr), t)
+		}
+		return trimp
+	}
+
+	if activity.HasHeartrate && activity.AverageHeartrate > 0 {
+		return activity.MovingTime / 60 * trimpWeight(activity.AverageHeartrate, t)
+	}
+
+	return 0
+}
+
+// trimpWeight returns the TRIMP weighting for a heart rate
+func trimpWeight(hr float64, t Thresholds) float64 {
+	reserve := (hr - float64(t.RestingHeartrate)) / float64(t.MaxHeartrate-t.RestingHeartrate)
+	reserve = math.Max(0, math.Min(1, reserve))
+
+	if t.Sex == "F" {
+		return reserve * 0.86 * math.Exp(1.67*reserve)
+	}
+	return reserve * 0.64 * math.Exp(1.92*reserve)
+}
+
+// sampleIntervals returns the seconds each sample represents, assuming 1 Hz
+// samples when there is no time stream
+func sampleIntervals(timeStream *models.TimeStream, n int) []float64 {
+	intervals := make([]float64, n)
+	for i := range intervals {
+		intervals[i] = 1
+	}
+
+	if timeStream == nil || len(timeStream.Data) != n {
+		return intervals
+	}
+
+	for i := 1; i < n; i++ {
+		dt := float64(timeStream.Data[i] - timeStream.Data[i-1])
+		intervals[i] = math.Max(0, math.Min(dt, maxSampleGap))
+	}
+	return intervals
+}
+
+// TrainingLoad builds the fitness/fatigue/form series for every day between from
+// and to inclusive. Scores before from warm up the model, and days without
+// activities are filled in as rest days.
+func TrainingLoad(scores []StressScore, from, to time.Time, opts *TrainingLoadOptions) []TrainingLoadDay {
+	from, to = Day(from), Day(to)
+	if to.Before(from) {
+		return nil
+	}
+
+	fitnessDays := float64(DefaultFitnessDays)
+	fatigueDays := float64(DefaultFatigueDays)
+	var fitness, fatigue float64
+	if opts != nil {
+		if opts.FitnessDays > 0 {
+			fitnessDays = opts.FitnessDays
+		}
+		if opts.FatigueDays > 0 {
+			fatigueDays = opts.FatigueDays
+		}
+		fitness = opts.InitialFitness
+		fatigue = opts.InitialFatigue
+	}
+
+	daily := make(map[time.Time]float64)
+	start := from
+	for _, s := range scores {
+		day := Day(s.Date)
+		if day.After(to) {
+			continue
+		}
+		daily[day] += s.Score
+		if day.Before(start) {
+			start = day
+		}
+	}
+
+	fitnessDecay := 1 - math.Exp(-1/fitnessDays)
+	fatigueDecay := 1 - math.Exp(-1/fatigueDays)
+
+	var series []TrainingLoadDay
+	for day := start; !day.After(to); day = day.AddDate(0, 0, 1) {
+		stress := daily[day]
+		form := fitness - fatigue
+
+		fitness += (stress - fitness) * fitnessDecay
+		fatigue += (stress - fatigue) * fatigueDecay
+
+		if day.Before(from) {
+			continue
+		}
+		series = append(series, TrainingLoadDay{
+			Date:    day,
+			Stress:  stress,
+			Fitness: fitness,
+			Fatigue: fatigue,
+			Form:    form,
+		})
+	}
+
+	return series
+}
+
+// FetchStressScores lists the athlete's activities from from through the end
+// of to's date and scores each one, so that to is inclusive as in
+// TrainingLoad. When streams is non-nil, power and heart rate streams are
+// fetched for activities that recorded them.
+func FetchStressScores(ctx context.Context, activities *services.ActivitiesService, streams *services.StreamsService, from, to time.Time, t Thresholds) ([]StressScore, error) {
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, to.Location())
+	opts := &models.ListOptions{
+		After:   utils.TimeToUnix(from),
+		Before:  utils.TimeToUnix(end),
+		PerPage: 200,
+	}
+
+	var scores []StressScore
+	for page := 1; ; page++ {
+		opts.Page = page
+		batch, err := activities.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, activity := range batch {
+			var set *models.StreamSet
+			if streams != nil && (activity.DeviceWatts || activity.HasHeartrate) {
+				set, err = streams.GetActivityStreams(ctx, activity.ID, []models.StreamType{
+					models.StreamTypeTime,
+					models.StreamTypeHeartrate,
+					models.StreamTypePower,
+				}, "")
+				if err != nil {
+					return nil, err
+				}
+			}
+			scores = append(scores, ActivityStress(activity, set, t))
+		}
+
+		if len(batch) < opts.PerPage {
+			break
+		}
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Date.Before(scores[j].Date)
+	})
+
+	return scores, nil
+}
+
+// Day truncates a time to its calendar date. Strava's local start dates are
+// encoded as UTC, so the date is taken from the time's own fields.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,126 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// CogganPowerZones returns Coggan's 7 power zones derived from FTP
+func CogganPowerZones(ftp int) []models.ZoneRange {
+	bounds := []float64{0.55, 0.75, 0.90, 1.05, 1.20, 1.50}
+	return zonesFromPercentages(float64(ftp), bounds)
+}
+
+// HeartrateZones returns 5 heart rate zones at 60/70/80/90% of max heart rate
+func HeartrateZones(maxHeartrate int) []models.ZoneRange {
+	bounds := []float64{0.60, 0.70, 0.80, 0.90}
+	return zonesFromPercentages(float64(maxHeartrate), bounds)
+}
+
+// zonesFromPercentages builds contiguous zones split at the given fractions of
+// a reference value. The last zone is open-ended, as in Strava's zones.
+func zonesFromPercentages(reference float64, bounds []float64) []models.ZoneRange {
+	zones := make([]models.ZoneRange, len(bounds)+1)
+	min := 0
+	for i, b := range bounds {
+		max := int(math.Round(reference * b))
+		zones[i] = models.ZoneRange{Min: min, Max: max}
+		min = max
+	}
+	zones[len(bounds)] = models.ZoneRange{Min: min, Max: -1}
+	return zones
+}
+
+// zoneIndex returns the index of the zone containing value
+func zoneIndex(value int, zones []models.ZoneRange) int {
+	for i := len(zones) - 1; i > 0; i-- {
+		if value >= zones[i].Min {
+			return i
+		}
+	}
+	return 0
+}
+
+// TimeInZones returns the seconds spent in each zone. Samples are weighted by
+// the time stream when present; recording gaps are not counted.
+func TimeInZones(values []int, timeStream *models.TimeStream, zones []models.ZoneRange) []models.ZoneBucket {
+	if len(zones) == 0 {
+		return nil
+	}
+
+	seconds := make([]float64, len(zones))
+	intervals := sampleIntervals(timeStream, len(values))
+	for i, v := range values {
+		seconds[zoneIndex(v, zones)] += intervals[i]
+	}
+
+	buckets := make([]models.ZoneBucket, len(zones))
+	for i, z := range zones {
+		buckets[i] = models.ZoneBucket{Min: z.Min, Max: z.Max, Time: int(math.Round(seconds[i]))}
+	}
+	return buckets
+}
+
+// HeartrateTimeInZones returns the time in each heart rate zone from an activity's streams
+func HeartrateTimeInZones(streams *models.StreamSet, zones []models.ZoneRange) []models.ZoneBucket {
+	if streams == nil || streams.Heartrate == nil {
+		return nil
+	}
+	return TimeInZones(streams.Heartrate.Data, streams.Time, zones)
+}
+
+// PowerTimeInZones returns the time in each power zone from an activity's streams
+func PowerTimeInZones(streams *models.StreamSet, zones []models.ZoneRange) []models.ZoneBucket {
+	if streams == nil || streams.Watts == nil {
+		return nil
+	}
+	return TimeInZones(streams.Watts.Data, streams.Time, zones)
+}
+
+// ZoneDistribution is the time in zones of a single activity
+type ZoneDistribution struct {
+	ActivityID int64               `json:"activity_id"`
+	Date       time.Time           `json:"date"`
+	Buckets    []models.ZoneBucket `json:"buckets"`
+}
+
+// ZonePeriodTotal is the time in zones summed over a calendar period
+type ZonePeriodTotal struct {
+	Start      time.Time           `json:"start"`
+	Activities int                 `json:"activities"`
+	Buckets    []models.ZoneBucket `json:"buckets"`
+}
+
+// RollupZones sums activity zone distributions by period. Distributions must
+// share the same zone boundaries; the first one sets the boundaries.
+func RollupZones(distributions []ZoneDistribution, period Period) []ZonePeriodTotal {
+	totals := make(map[time.Time]*ZonePeriodTotal)
+	for _, d := range distributions {
+		start := PeriodStart(d.Date, period)
+		total, ok := totals[start]
+		if !ok {
+			total = &ZonePeriodTotal{Start: start}
+			totals[start] = total
+		}
+
+		total.Activities++
+		for i, b := range d.Buckets {
+			if i >= len(total.Buckets) {
+				total.Buckets = append(total.Buckets, models.ZoneBucket{Min: b.Min, Max: b.Max})
+			}
+			total.Buckets[i].Time += b.Time
+		}
+	}
+
+	result := make([]ZonePeriodTotal, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/services"
+)
+
+// layoutVersion is the version of the backup directory layout:
+//
+//	backup.json                          manifest
+//	athlete/{profile,stats,zones}.json
+//	gear/<id>.json
+//	clubs.json
+//	segments/starred.json
+//	routes/routes.json, routes/<id>-<name>.gpx
+//	activities/<year>/<id>/{activity,laps,comments,kudos,streams}.json
+//
+// An activity's activity.json is written last, so its presence marks the
+// activity as complete.
+const layoutVersion = 1
+
+const (
+	// perPage is the page size for listings
+	perPage = 200
+
+	// maxAttempts is how often a request is tried when rate limited
+	maxAttempts = 5
+)
+
+// streamKeys are the streams saved for each activity
+var streamKeys = []models.StreamType{
+	models.StreamTypeTime, models.StreamTypeDistance, models.StreamTypeLatLng,
+	models.StreamTypeAltitude, models.StreamTypeVelocity, models.StreamTypeHeartrate,
+	models.StreamTypeCadence, models.StreamTypePower, models.StreamTypeTemperature,
+	models.StreamTypeMoving, models.StreamTypeGrade,
+}
+
+// manifest records the backup's layout, athlete and progress
+type manifest struct {
+	Layout    int       `json:"layout"`
+	AthleteID int64     `json:"athlete_id"`
+	After     int64     `json:"after"` // start time of the newest saved activity, unix
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// backup archives an account into dir
+type backup struct {
+	client *strava.Client
+	tokens *tokenSource
+	dir    string
+	full   bool
+}
+
+// run makes or updates the backup
+func (b *backup) run(ctx context.Context) error {
+	var athlete models.Athlete
+	if err := b.save(ctx, "athlete/profile.json", "/athlete", nil, &athlete); err != nil {
+		return err
+	}
+
+	m, err := b.readManifest(athlete.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("backing up athlete %d to %s", athlete.ID, b.dir)
+	if err := b.save(ctx, "athlete/stats.json", fmt.Sprintf("/athletes/%d/stats", athlete.ID), nil, nil); err != nil {
+		return err
+	}
+	if err := b.save(ctx, "athlete/zones.json", "/athlete/zones", nil, nil); err != nil {
+		// Zones need the profile:read_all scope
+		if !strava.IsAuthError(err) {
+			return err
+		}
+		log.Printf("skipping zones: %v", err)
+	}
+
+	for _, gear := range append(athlete.Bikes, athlete.Shoes...) {
+		if err := b.save(ctx, filepath.Join("gear", gear.ID+".json"), "/gear/"+gear.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	if err := b.saveAll(ctx, "clubs.json", "/athlete/clubs", nil); err != nil {
+		return err
+	}
+	if err := b.saveAll(ctx, "segments/starred.json", "/segments/starred", nil); err != nil {
+		return err
+	}
+	if err := b.routes(ctx, athlete.ID); err != nil {
+		return err
+	}
+
+	after := m.After
+	if b.full {
+		after = 0
+	}
+	newest, err := b.activities(ctx, after)
+	if err != nil {
+		return err
+	}
+
+	if newest > m.After {
+		m.After = newest
+	}
+	m.UpdatedAt = time.Now().UTC()
+	return b.writeJSON("backup.json", m)
+}
+
+// readManifest reads the backup's manifest, or starts a new one, checking
+// that an existing backup has this layout and athlete
+func (b *backup) readManifest(athleteID int64) (*manifest, error) {
+	m := &manifest{Layout: layoutVersion, AthleteID: athleteID}
+	data, err := os.ReadFile(filepath.Join(b.dir, "backup.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("backup.json: %w", err)
+	}
+	if m.Layout != layoutVersion {
+		return nil, fmt.Errorf("%s has backup layout %d, this version writes layout %d", b.dir, m.Layout, layoutVersion)
+	}
+	if m.AthleteID != athleteID {
+		return nil, fmt.Errorf("%s is a backup of athlete %d, not %d", b.dir, m.AthleteID, athleteID)
+	}
+	return m, nil
+}
+
+// routes saves the route list and each route's GPX file not yet saved
+func (b *backup) routes(ctx context.Context, athleteID int64) error {
+	pages, err := b.fetchAll(ctx, fmt.Sprintf("/athletes/%d/routes", athleteID), nil)
+	if err != nil {
+		return err
+	}
+	if err := b.writeJSON("routes/routes.json", pages); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(b.dir, "routes")
+	for _, raw := range pages {
+		var route models.Route
+		if err := json.Unmarshal(raw, &route); err != nil {
+			return err
+		}
+		name := services.RouteFileName(&route, services.RouteFormatGPX)
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			continue
+		}
+		err := b.retry(ctx, func() error {
+			_, err := b.client.Routes.Save(ctx, &route, dir, services.RouteFormatGPX)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("route %d: %w", route.ID, err)
+		}
+	}
+	return nil
+}
+
+// activities saves every activity started after the unix time after that is
+// not already complete, returning the newest start time seen
+func (b *backup) activities(ctx context.Context, after int64) (int64, error) {
+	var newest int64
+	var saved, skipped int
+	for page := 1; ; page++ {
+		var list []*models.Activity
+		err := b.retry(ctx, func() error {
+			var err error
+			list, err = b.client.Activities.List(ctx, &models.ListOptions{After: int(after), Page: page, PerPage: perPage})
+			return err
+		})
+		if err != nil {
+			return newest, err
+		}
+
+		for _, a := range list {
+			done, err := b.activity(ctx, a)
+			if err != nil {
+				return newest, fmt.Errorf("activity %d: %w", a.ID, err)
+			}
+			if done {
+				skipped++
+			} else {
+				saved++
+			}
+			if start := a.StartDate.Unix(); start > newest {
+				newest = start
+			}
+		}
+		log.Printf("activities: %d saved, %d already saved", saved, skipped)
+
+		if len(list) < perPage {
+			return newest, nil
+		}
+	}
+}
+
+// activity saves an activity's laps, comments, kudos, streams and detail,
+// reporting whether it was already complete
+func (b *backup) activity(ctx context.Context, a *models.Activity) (bool, error) {
+	dir := filepath.Join("activities", strconv.Itoa(a.StartDate.Year()), strconv.FormatInt(a.ID, 10))
+	if _, err := os.Stat(filepath.Join(b.dir, dir, "activity.json")); err == nil {
+		return true, nil
+	}
+
+	base := fmt.Sprintf("/activities/%d", a.ID)
+	if err := b.save(ctx, filepath.Join(dir, "laps.json"), base+"/laps", nil, nil); err != nil {
+		return false, err
+	}
+	if err := b.saveAll(ctx, filepath.Join(dir, "comments.json"), base+"/comments", nil); err != nil {
+		return false, err
+	}
+	if err := b.saveAll(ctx, filepath.Join(dir, "kudos.json"), base+"/kudos", nil); err != nil {
+		return false, err
+	}
+
+	keys := make([]string, len(streamKeys))
+	for i, k := range streamKeys {
+		keys[i] = string(k)
+	}
+	query := url.Values{"keys": {strings.Join(keys, ",")}, "key_by_type": {"true"}}
+	if err := b.save(ctx, filepath.Join(dir, "streams.json"), base+"/streams", query, nil); err != nil {
+		// Manual activities have no streams
+		if !strava.IsNotFoundError(err) {
+			return false, err
+		}
+	}
+
+	query = url.Values{"include_all_efforts": {"true"}}
+	return false, b.save(ctx, filepath.Join(dir, "activity.json"), base, query, nil)
+}
+
+// save fetches a JSON document and writes it to name as returned, decoding
+// it into v when v is not nil
+func (b *backup) save(ctx context.Context, name, path string, query url.Values, v interface{}) error {
+	data, err := b.fetch(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return b.writeFile(name, data)
+}
+
+// saveAll fetches every page of a listing and writes the items to name as
+// one array
+func (b *backup) saveAll(ctx context.Context, name, path string, query url.Values) error {
+	items, err := b.fetchAll(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return b.writeJSON(name, items)
+}
+
+// fetchAll fetches every page of a listing
+func (b *backup) fetchAll(ctx context.Context, path string, query url.Values) ([]json.RawMessage, error) {
+	items := []json.RawMessage{}
+	for page := 1; ; page++ {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", strconv.Itoa(perPage))
+
+		data, err := b.fetch(ctx, path, q)
+		if err != nil {
+			return nil, err
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		items = append(items, list...)
+		if len(list) < perPage {
+			return items, nil
+		}
+	}
+}
+
+// fetch returns a response body as is
+func (b *backup) fetch(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	var buf bytes.Buffer
+	err := b.retry(ctx, func() error {
+		buf.Reset()
+		_, err := b.client.GetRaw(ctx, path, query, &buf)
+		return err
+	})
+	return buf.Bytes(), err
+}
+
+// retry calls fn with a fresh access token, trying again when rate limited.
+// The client's rate limiter waits for the exhausted window to reset before
+// the next attempt.
+func (b *backup) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = b.tokens.refresh(ctx); err != nil {
+			return err
+		}
+		if err = fn(); !strava.IsRateLimitError(err) {
+			return err
+		}
+		log.Printf("rate limited, waiting for the limit to reset (attempt %d of %d)", attempt, maxAttempts)
+	}
+	return err
+}
+
+// writeJSON writes v to name as indented JSON
+func (b *backup) writeJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return b.writeFile(name, data)
+}
+
+// writeFile writes data to name in the backup directory, through a
+// temporary file so an interrupted backup never leaves a partial file
+func (b *backup) writeFile(name string, data []byte) error {
+	path := filepath.Join(b.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
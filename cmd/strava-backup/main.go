@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/kpi-studio/go-strava-api"
+)
+
+func main() {
+	out := flag.String("out", "strava-backup", "backup directory")
+	tokenFile := flag.String("token-file", "", "JSON OAuth token to use and refresh")
+	full := flag.Bool("full", false, "list all activities, not only those since the last backup")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), `Usage: strava-backup [-out dir] [-token-file token.json] [-full]
+
+Archives the authenticated athlete's profile, stats, zones, gear, clubs,
+routes, starred segments and activities. The access token is read from
+STRAVA_ACCESS_TOKEN, or from a token file refreshed with STRAVA_CLIENT_ID
+and STRAVA_CLIENT_SECRET. Interrupted backups resume where they stopped.
+
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := strava.NewClient("")
+	tokens, err := newTokenSource(client, *tokenFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b := &backup{client: client, tokens: tokens, dir: *out, full: *full}
+	if err := b.run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// tokenSource keeps the client's access token current
+type tokenSource struct {
+	client  *strava.Client
+	manager *strava.TokenManager
+}
+
+// newTokenSource sets up the client's token from the token file, or from
+// STRAVA_ACCESS_TOKEN when there is none
+func newTokenSource(client *strava.Client, path string) (*tokenSource, error) {
+	ts := &tokenSource{client: client}
+	if path == "" {
+		token := os.Getenv("STRAVA_ACCESS_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("set STRAVA_ACCESS_TOKEN or use -token-file")
+		}
+		client.SetAccessToken(token)
+		return ts, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var token strava.TokenResponse
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	ts.manager = strava.NewTokenManager(&strava.OAuth2Config{
+		ClientID:     os.Getenv("STRAVA_CLIENT_ID"),
+		ClientSecret: os.Getenv("STRAVA_CLIENT_SECRET"),
+	}, &token)
+	ts.manager.SetTokenUpdateCallback(func(token *strava.TokenResponse) {
+		data, err := json.MarshalIndent(token, "", "  ")
+		if err == nil {
+			err = os.WriteFile(path, data, 0o600)
+		}
+		if err != nil {
+			log.Printf("saving refreshed token: %v", err)
+		}
+	})
+	return ts, nil
+}
+
+// refresh refreshes the access token when it is about to expire
+func (ts *tokenSource) refresh(ctx context.Context) error {
+	if ts.manager == nil {
+		return nil
+	}
+	token, err := ts.manager.GetAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	ts.client.SetAccessToken(token)
+	return nil
+}
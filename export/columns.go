@@ -0,0 +1,242 @@
+package export
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// row is the activity and stream sample a row is written for
+type row struct {
+	activity *models.Activity
+	streams  *models.StreamSet
+	sample   int
+	units    Units
+}
+
+// column computes a column's value for a row, or nil when there is none
+type column struct {
+	sample bool
+	value  func(r *row) interface{}
+}
+
+// columns are the available columns. Sample columns hold one stream sample
+// per row and are empty for records without streams.
+var columns = map[string]column{
+	"id":               activityColumn(func(r *row) interface{} { return r.activity.ID }),
+	"name":             activityColumn(func(r *row) interface{} { return r.activity.Name }),
+	"type":             activityColumn(func(r *row) interface{} { return string(r.activity.Type) }),
+	"sport_type":       activityColumn(func(r *row) interface{} { return string(r.activity.SportType) }),
+	"start_date":       activityColumn(func(r *row) interface{} { return timestamp(r.activity.StartDate) }),
+	"start_date_local": activityColumn(func(r *row) interface{} { return localTime(r.activity.StartDateLocal) }),
+	"distance":         activityColumn(func(r *row) interface{} { return r.distance(r.activity.Distance) }),
+	"moving_time":      activityColumn(func(r *row) interface{} { return int(r.activity.MovingTime) }),
+	"elapsed_time":     activityColumn(func(r *row) interface{} { return int(r.activity.ElapsedTime) }),
+	"total_elevation_gain": activityColumn(func(r *row) interface{} {
+		return r.elevation(r.activity.TotalElevationGain)
+	}),
+	"average_speed": activityColumn(func(r *row) interface{} { return r.speed(r.activity.AverageSpeed) }),
+	"max_speed":     activityColumn(func(r *row) interface{} { return r.speed(r.activity.MaxSpeed) }),
+	"pace":          activityColumn(func(r *row) interface{} { return r.pace() }),
+	"average_heartrate": activityColumn(func(r *row) interface{} {
+		if !r.activity.HasHeartrate {
+			return nil
+		}
+		return round(r.activity.AverageHeartrate, 1)
+	}),
+	"max_heartrate": activityColumn(func(r *row) interface{} {
+		if !r.activity.HasHeartrate {
+			return nil
+		}
+		return r.activity.MaxHeartrate
+	}),
+	"average_cadence": activityColumn(func(r *row) interface{} { return optional(round(r.activity.AverageCadence, 1)) }),
+	"average_watts":   activityColumn(func(r *row) interface{} { return optional(round(r.activity.AverageWatts, 1)) }),
+	"weighted_average_watts": activityColumn(func(r *row) interface{} {
+		return optional(float64(r.activity.WeightedAverageWatts))
+	}),
+	"kilojoules":   activityColumn(func(r *row) interface{} { return optional(round(r.activity.Kilojoules, 1)) }),
+	"calories":     activityColumn(func(r *row) interface{} { return optional(round(r.activity.Calories, 1)) }),
+	"suffer_score": activityColumn(func(r *row) interface{} { return optional(float64(r.activity.SufferScore)) }),
+	"kudos_count":  activityColumn(func(r *row) interface{} { return r.activity.KudosCount }),
+	"gear_id":      activityColumn(func(r *row) interface{} { return r.activity.GearID }),
+	"commute":      activityColumn(func(r *row) interface{} { return r.activity.Commute }),
+	"trainer":      activityColumn(func(r *row) interface{} { return r.activity.Trainer }),
+	"private":      activityColumn(func(r *row) interface{} { return r.activity.Private }),
+
+	"sample_time": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Time; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+	"sample_timestamp": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Time; s != nil && r.sample < len(s.Data) && !r.activity.StartDate.IsZero() {
+			return timestamp(r.activity.StartDate.Add(time.Duration(s.Data[r.sample]) * time.Second))
+		}
+		return nil
+	}),
+	"sample_distance": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Distance; s != nil && r.sample < len(s.Data) {
+			return r.distance(s.Data[r.sample])
+		}
+		return nil
+	}),
+	"sample_lat": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.LatLng; s != nil && r.sample < len(s.Data) && len(s.Data[r.sample]) == 2 {
+			return s.Data[r.sample][0]
+		}
+		return nil
+	}),
+	"sample_lng": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.LatLng; s != nil && r.sample < len(s.Data) && len(s.Data[r.sample]) == 2 {
+			return s.Data[r.sample][1]
+		}
+		return nil
+	}),
+	"sample_altitude": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Altitude; s != nil && r.sample < len(s.Data) {
+			return r.elevation(s.Data[r.sample])
+		}
+		return nil
+	}),
+	"sample_speed": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.VelocitySmooth; s != nil && r.sample < len(s.Data) {
+			return r.speed(s.Data[r.sample])
+		}
+		return nil
+	}),
+	"sample_heartrate": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Heartrate; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+	"sample_cadence": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Cadence; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+	"sample_watts": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Watts; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+	"sample_temp": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Temperature; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+	"sample_moving": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.Moving; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+	"sample_grade": sampleColumn(func(r *row) interface{} {
+		if s := r.streams.GradeSmooth; s != nil && r.sample < len(s.Data) {
+			return s.Data[r.sample]
+		}
+		return nil
+	}),
+}
+
+// Columns returns the names of all available columns, sorted
+func Columns() []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// activityColumn returns a column of activity values
+func activityColumn(value func(r *row) interface{}) column {
+	return column{value: value}
+}
+
+// sampleColumn returns a column of stream samples, empty without streams
+func sampleColumn(value func(r *row) interface{}) column {
+	return column{sample: true, value: func(r *row) interface{} {
+		if r.streams == nil {
+			return nil
+		}
+		return value(r)
+	}}
+}
+
+// distance converts meters to kilometers or miles
+func (r *row) distance(meters float64) float64 {
+	if r.units == UnitsImperial {
+		return round(utils.MetersToMiles(meters), 3)
+	}
+	return round(utils.MetersToKilometers(meters), 3)
+}
+
+// speed converts m/s to km/h or mph
+func (r *row) speed(mps float64) float64 {
+	if r.units == UnitsImperial {
+		return round(utils.MetersPerSecondToMilesPerHour(mps), 2)
+	}
+	return round(utils.MetersPerSecondToKilometersPerHour(mps), 2)
+}
+
+// elevation converts meters to meters or feet
+func (r *row) elevation(meters float64) float64 {
+	if r.units == UnitsImperial {
+		return round(utils.MetersToFeet(meters), 1)
+	}
+	return round(meters, 1)
+}
+
+// pace returns the activity's moving pace per kilometer or mile as M:SS
+func (r *row) pace() interface{} {
+	var seconds int
+	if r.units == UnitsImperial {
+		seconds = utils.CalculatePacePerMile(r.activity.Distance, int(r.activity.MovingTime))
+	} else {
+		seconds = utils.CalculatePacePerKilometer(r.activity.Distance, int(r.activity.MovingTime))
+	}
+	if seconds <= 0 {
+		return nil
+	}
+	return utils.FormatPace(seconds)
+}
+
+// timestamp formats a time in UTC, or nil when it is zero
+func timestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// localTime formats a local time without a zone, as Strava reports local
+// times in UTC
+func localTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// optional returns nil for zero values of sensors the activity may not have
+func optional(v float64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// round rounds v to the given number of decimal places
+func round(v float64, places int) float64 {
+	p := math.Pow10(places)
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,262 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV       Format = "csv"
+	FormatJSONLines Format = "jsonl"
+)
+
+// Units is a system of units for distances, speeds, paces and elevations
+type Units string
+
+const (
+	// UnitsMetric exports kilometers, km/h, min/km and meters
+	UnitsMetric Units = "metric"
+
+	// UnitsImperial exports miles, mph, min/mi and feet
+	UnitsImperial Units = "imperial"
+)
+
+// DefaultColumns are the columns exported when none are chosen
+var DefaultColumns = []string{
+	"id", "name", "type", "start_date", "distance", "moving_time", "elapsed_time",
+	"total_elevation_gain", "average_speed", "pace", "average_heartrate",
+}
+
+var (
+	// ErrUnknownColumn is returned for column names that are not in Columns
+	ErrUnknownColumn = errors.New("export: unknown column")
+
+	// ErrUnknownFormat is returned for formats other than CSV and JSON Lines
+	ErrUnknownFormat = errors.New("export: unknown format")
+
+	// ErrUnknownUnits is returned for units other than metric and imperial
+	ErrUnknownUnits = errors.New("export: unknown units")
+)
+
+// Options contains options for exporting activities
+type Options struct {
+	// Format is the output format (default: csv)
+	Format Format
+
+	// Columns are the exported columns, in order, from Columns (default:
+	// DefaultColumns)
+	Columns []string
+
+	// Units converts distances, speeds, paces and elevations (default: metric)
+	Units Units
+}
+
+// Record is an activity to export, with optional streams
+type Record struct {
+	Activity *models.Activity
+	Streams  *models.StreamSet
+}
+
+// Writer writes records as rows of CSV or JSON Lines. Each record is written
+// as one row, or, when a sample column is chosen and the record has streams,
+// as one row per stream sample with the activity columns repeated. Rows are
+// written as they are produced, so memory use does not grow with the number
+// of records.
+type Writer struct {
+	units   Units
+	names   []string
+	columns []column
+	samples bool
+
+	csv    *csv.Writer
+	buf    *bufio.Writer
+	header bool
+}
+
+// NewWriter creates a writer with the given options, checking the column
+// names, format and units
+func NewWriter(w io.Writer, opts *Options) (*Writer, error) {
+	o := Options{Format: FormatCSV, Columns: DefaultColumns, Units: UnitsMetric}
+	if opts != nil {
+		if opts.Format != "" {
+			o.Format = opts.Format
+		}
+		if len(opts.Columns) > 0 {
+			o.Columns = opts.Columns
+		}
+		if opts.Units != "" {
+			o.Units = opts.Units
+		}
+	}
+
+	ew := &Writer{units: o.Units, names: o.Columns}
+	switch o.Format {
+	case FormatCSV:
+		ew.csv = csv.NewWriter(w)
+	case FormatJSONLines:
+		ew.buf = bufio.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, o.Format)
+	}
+	if o.Units != UnitsMetric && o.Units != UnitsImperial {
+		return nil, fmt.Errorf("%w %q", ErrUnknownUnits, o.Units)
+	}
+
+	for _, name := range o.Columns {
+		c, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		ew.columns = append(ew.columns, c)
+		ew.samples = ew.samples || c.sample
+	}
+	return ew, nil
+}
+
+// Write writes the rows of a record
+func (w *Writer) Write(r Record) error {
+	if w.csv != nil && !w.header {
+		w.header = true
+		if err := w.csv.Write(w.names); err != nil {
+			return err
+		}
+	}
+
+	n := 1
+	if w.samples {
+		if m := streamLength(r.Streams); m > 0 {
+			n = m
+		}
+	}
+	for i := 0; i < n; i++ {
+		if err := w.writeRow(&row{activity: r.Activity, streams: r.Streams, sample: i, units: w.units}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered rows to the underlying writer
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return w.buf.Flush()
+}
+
+// WriteAll writes every record from the iterator and flushes, returning the
+// number of records written. It stops at the first error, from the iterator
+// or from writing, after flushing the records written so far.
+func WriteAll(w io.Writer, records iter.Seq2[Record, error], opts *Options) (int, error) {
+	ew, err := NewWriter(w, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for r, err := range records {
+		if err == nil {
+			err = ew.Write(r)
+		}
+		if err != nil {
+			ew.Flush()
+			return count, err
+		}
+		count++
+	}
+	return count, ew.Flush()
+}
+
+// Records pairs each activity from the iterator with its streams, fetched
+// one activity at a time by streams, which may be nil to export activities
+// only. An error from either stops the iteration.
+func Records(activities iter.Seq2[*models.Activity, error], streams func(*models.Activity) (*models.StreamSet, error)) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for activity, err := range activities {
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+			r := Record{Activity: activity}
+			if streams != nil {
+				if r.Streams, err = streams(activity); err != nil {
+					yield(Record{}, err)
+					return
+				}
+			}
+			if !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+// writeRow writes one row
+func (w *Writer) writeRow(r *row) error {
+	if w.csv != nil {
+		fields := make([]string, len(w.columns))
+		for i, c := range w.columns {
+			fields[i] = format(c.value(r))
+		}
+		return w.csv.Write(fields)
+	}
+
+	w.buf.WriteByte('{')
+	for i, c := range w.columns {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(w.names[i])
+		value, err := json.Marshal(c.value(r))
+		if err != nil {
+			return err
+		}
+		w.buf.Write(key)
+		w.buf.WriteByte(':')
+		w.buf.Write(value)
+	}
+	w.buf.WriteString("}\n")
+	return nil
+}
+
+// format formats a value for CSV, with nil as an empty field
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// streamLength returns the number of samples in the streams
+func streamLength(streams *models.StreamSet) int {
+	switch {
+	case streams == nil:
+		return 0
+	case streams.Time != nil:
+		return len(streams.Time.Data)
+	case streams.LatLng != nil:
+		return len(streams.LatLng.Data)
+	case streams.Distance != nil:
+		return len(streams.Distance.Data)
+	default:
+		return 0
+	}
+}
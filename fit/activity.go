@@ -0,0 +1,360 @@
+package fit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/laps"
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// FIT sports
+const (
+	SportGeneric            = 0
+	SportRunning            = 1
+	SportCycling            = 2
+	SportSwimming           = 5
+	SportTraining           = 10
+	SportWalking            = 11
+	SportCrossCountrySkiing = 12
+	SportAlpineSkiing       = 13
+	SportSnowboarding       = 14
+	SportRowing             = 15
+	SportHiking             = 17
+	SportPaddling           = 19
+	SportEBiking            = 21
+)
+
+// File types
+const (
+	fileActivity = 4
+	fileCourse   = 6
+)
+
+// Manufacturer development, used for files that don't come from a device
+const manufacturerDevelopment = 255
+
+// Events and event types
+const (
+	eventTimer    = 0
+	eventSession  = 8
+	eventLap      = 9
+	eventActivity = 26
+
+	eventTypeStart   = 0
+	eventTypeStop    = 1
+	eventTypeStopAll = 4
+)
+
+var (
+	// ErrNoTime is returned when the streams have no time data
+	ErrNoTime = errors.New("fit: no time stream")
+
+	// ErrNoTrack is returned when the streams have no latlng data
+	ErrNoTrack = errors.New("fit: no latlng stream")
+
+	// ErrMissingStartDate is returned when the activity has no start date
+	ErrMissingStartDate = errors.New("fit: activity has no start date")
+
+	// ErrLengthMismatch is returned when streams are not aligned with each other
+	ErrLengthMismatch = track.ErrLengthMismatch
+
+	// ErrInvalidCoordinate is returned for malformed or out of range coordinates
+	ErrInvalidCoordinate = track.ErrInvalidCoordinate
+
+	// ErrTimeOrder is returned when the time stream goes backwards
+	ErrTimeOrder = track.ErrTimeOrder
+
+	// ErrLapIndex is returned when lap start indices are out of range or out of order
+	ErrLapIndex = errors.New("fit: invalid lap start index")
+)
+
+var (
+	fileIDDefinition = &definition{global: mesgFileID, fields: []field{
+		{0, 1, baseEnum},    // type
+		{1, 2, baseUint16},  // manufacturer
+		{2, 2, baseUint16},  // product
+		{3, 4, baseUint32z}, // serial_number
+		{4, 4, baseUint32},  // time_created
+	}}
+
+	eventDefinition = &definition{global: mesgEvent, fields: []field{
+		{253, 4, baseUint32}, // timestamp
+		{0, 1, baseEnum},     // event
+		{1, 1, baseEnum},     // event_type
+	}}
+
+	recordDefinition = &definition{global: mesgRecord, fields: []field{
+		{253, 4, baseUint32}, // timestamp
+		{0, 4, baseSint32},   // position_lat
+		{1, 4, baseSint32},   // position_long
+		{2, 2, baseUint16},   // altitude, scale 5, offset 500
+		{3, 1, baseUint8},    // heart_rate
+		{4, 1, baseUint8},    // cadence
+		{5, 4, baseUint32},   // distance, scale 100
+		{6, 2, baseUint16},   // speed, scale 1000
+		{7, 2, baseUint16},   // power
+		{13, 1, baseSint8},   // temperature
+	}}
+
+	lapDefinition = &definition{global: mesgLap, fields: []field{
+		{254, 2, baseUint16}, // message_index
+		{253, 4, baseUint32}, // timestamp
+		{0, 1, baseEnum},     // event
+		{1, 1, baseEnum},     // event_type
+		{2, 4, baseUint32},   // start_time
+		{3, 4, baseSint32},   // start_position_lat
+		{4, 4, baseSint32},   // start_position_long
+		{5, 4, baseSint32},   // end_position_lat
+		{6, 4, baseSint32},   // end_position_long
+		{7, 4, baseUint32},   // total_elapsed_time, scale 1000
+		{8, 4, baseUint32},   // total_timer_time, scale 1000
+		{9, 4, baseUint32},   // total_distance, scale 100
+		{11, 2, baseUint16},  // total_calories
+		{13, 2, baseUint16},  // avg_speed, scale 1000
+		{14, 2, baseUint16},  // max_speed, scale 1000
+		{15, 1, baseUint8},   // avg_heart_rate
+		{16, 1, baseUint8},   // max_heart_rate
+		{17, 1, baseUint8},   // avg_cadence
+		{19, 2, baseUint16},  // avg_power
+		{20, 2, baseUint16},  // max_power
+		{21, 2, baseUint16},  // total_ascent
+		{25, 1, baseEnum},    // sport
+	}}
+
+	sessionDefinition = &definition{global: mesgSession, fields: []field{
+		{254, 2, baseUint16}, // message_index
+		{253, 4, baseUint32}, // timestamp
+		{0, 1, baseEnum},     // event
+		{1, 1, baseEnum},     // event_type
+		{2, 4, baseUint32},   // start_time
+		{3, 4, baseSint32},   // start_position_lat
+		{4, 4, baseSint32},   // start_position_long
+		{5, 1, baseEnum},     // sport
+		{7, 4, baseUint32},   // total_elapsed_time, scale 1000
+		{8, 4, baseUint32},   // total_timer_time, scale 1000
+		{9, 4, baseUint32},   // total_distance, scale 100
+		{11, 2, baseUint16},  // total_calories
+		{14, 2, baseUint16},  // avg_speed, scale 1000
+		{15, 2, baseUint16},  // max_speed, scale 1000
+		{16, 1, baseUint8},   // avg_heart_rate
+		{17, 1, baseUint8},   // max_heart_rate
+		{18, 1, baseUint8},   // avg_cadence
+		{20, 2, baseUint16},  // avg_power
+		{21, 2, baseUint16},  // max_power
+		{22, 2, baseUint16},  // total_ascent
+		{25, 2, baseUint16},  // first_lap_index
+		{26, 2, baseUint16},  // num_laps
+	}}
+
+	activityDefinition = &definition{global: mesgActivity, fields: []field{
+		{253, 4, baseUint32}, // timestamp
+		{0, 4, baseUint32},   // total_timer_time, scale 1000
+		{1, 2, baseUint16},   // num_sessions
+		{2, 1, baseEnum},     // type
+		{3, 1, baseEnum},     // event
+		{4, 1, baseEnum},     // event_type
+		{5, 4, baseUint32},   // local_timestamp
+	}}
+)
+
+// Sport maps an activity type to a FIT sport
+func Sport(activityType models.ActivityType) uint8 {
+	switch activityType {
+	case models.ActivityTypeEBikeRide:
+		return SportEBiking
+	case models.ActivityTypeWalk:
+		return SportWalking
+	case models.ActivityTypeHike:
+		return SportHiking
+	case models.ActivityTypeSwim:
+		return SportSwimming
+	case models.ActivityTypeRowing:
+		return SportRowing
+	case models.ActivityTypeAlpineSki, models.ActivityTypeBackcountrySki:
+		return SportAlpineSkiing
+	case models.ActivityTypeNordicSki:
+		return SportCrossCountrySkiing
+	case models.ActivityTypeSnowboard:
+		return SportSnowboarding
+	case models.ActivityTypeCanoeing, models.ActivityTypeKayaking, models.ActivityTypeStandUpPaddling:
+		return SportPaddling
+	case models.ActivityTypeWeightTraining, models.ActivityTypeWorkout, models.ActivityTypeCrossfit, models.ActivityTypeYoga:
+		return SportTraining
+	}
+
+	switch {
+	case utils.IsRideActivity(activityType):
+		return SportCycling
+	case utils.IsRunActivity(activityType):
+		return SportRunning
+	default:
+		return SportGeneric
+	}
+}
+
+// ValidateActivity checks that the activity and streams can be written as a
+// FIT activity: a start date, a non-decreasing time stream with every other
+// stream aligned to it, valid coordinates and lap start indices within the
+// streams
+func ValidateActivity(activity *models.Activity, streams *models.StreamSet) error {
+	if activity == nil || activity.StartDate.IsZero() {
+		return ErrMissingStartDate
+	}
+	if streams == nil || streams.Time == nil || len(streams.Time.Data) == 0 {
+		return ErrNoTime
+	}
+
+	n := len(streams.Time.Data)
+	if err := track.CheckTimes(streams.Time.Data); err != nil {
+		return err
+	}
+	if err := track.CheckLengths(streams, n); err != nil {
+		return err
+	}
+	if streams.LatLng != nil {
+		if err := track.CheckCoordinates(streams.LatLng.Data); err != nil {
+			return err
+		}
+	}
+
+	prev := -1
+	for i, lap := range activity.Laps {
+		if lap.StartIndex < 0 || lap.StartIndex >= n || lap.StartIndex <= prev {
+			return fmt.Errorf("%w %d for lap %d", ErrLapIndex, lap.StartIndex, i+1)
+		}
+		prev = lap.StartIndex
+	}
+
+	return nil
+}
+
+// WriteActivity validates the activity and writes it to w as a FIT activity
+// file: a file_id message, a record per stream point, a lap message after
+// the records of each of the activity's laps, and the session and activity
+// messages. Lap and session totals are Strava's where reported and are
+// otherwise computed from the streams.
+func WriteActivity(w io.Writer, activity *models.Activity, streams *models.StreamSet) error {
+	if err := ValidateActivity(activity, streams); err != nil {
+		return err
+	}
+
+	start := activity.StartDate.UTC()
+	ts := streams.Time.Data
+	pointTime := func(i int) int64 {
+		return timestamp(start.Add(time.Duration(ts[i]) * time.Second))
+	}
+	first, last := pointTime(0), pointTime(len(ts)-1)
+	sport := int64(Sport(activity.Type))
+
+	e := newEncoder()
+	var serial interface{}
+	if activity.ID != 0 {
+		serial = int64(uint32(activity.ID))
+	}
+	e.write(fileIDDefinition, int64(fileActivity), int64(manufacturerDevelopment), int64(0), serial, first)
+	e.write(eventDefinition, first, int64(eventTimer), int64(eventTypeStart))
+
+	lapList := laps.Split(activity, streams, false)
+	for k, lap := range lapList {
+		for i := lap.First; i <= lap.Last; i++ {
+			writeRecord(e, streams, i, pointTime(i))
+		}
+
+		startLat, startLng := position(streams, lap.First)
+		endLat, endLng := position(streams, lap.Last)
+		e.write(lapDefinition,
+			int64(k), pointTime(lap.Last), int64(eventLap), int64(eventTypeStop), pointTime(lap.First),
+			startLat, startLng, endLat, endLng,
+			scaled(lap.ElapsedTime, 1000, 0, 4), scaled(lap.TimerTime, 1000, 0, 4), optional(lap.Distance, 100, 4),
+			optional(lap.Calories, 1, 2), optional(lap.AvgSpeed, 1000, 2), optional(lap.MaxSpeed, 1000, 2),
+			optional(lap.AvgHeartrate, 1, 1), optional(float64(lap.MaxHeartrate), 1, 1), optional(lap.AvgCadence, 1, 1),
+			optional(lap.AvgWatts, 1, 2), optional(float64(lap.MaxWatts), 1, 2), optional(lap.Ascent, 1, 2),
+			sport,
+		)
+	}
+
+	e.write(eventDefinition, last, int64(eventTimer), int64(eventTypeStopAll))
+
+	s := laps.Split(activity, streams, true)[0]
+	startLat, startLng := position(streams, 0)
+	e.write(sessionDefinition,
+		int64(0), last, int64(eventSession), int64(eventTypeStop), first,
+		startLat, startLng, sport,
+		scaled(s.ElapsedTime, 1000, 0, 4), scaled(s.TimerTime, 1000, 0, 4), optional(s.Distance, 100, 4),
+		optional(s.Calories, 1, 2), optional(s.AvgSpeed, 1000, 2), optional(s.MaxSpeed, 1000, 2),
+		optional(s.AvgHeartrate, 1, 1), optional(float64(s.MaxHeartrate), 1, 1), optional(s.AvgCadence, 1, 1),
+		optional(s.AvgWatts, 1, 2), optional(float64(s.MaxWatts), 1, 2), optional(s.Ascent, 1, 2),
+		int64(0), int64(len(lapList)),
+	)
+
+	local := last + int64(math.Round(activity.UTCOffset))
+	e.write(activityDefinition,
+		last, scaled(s.TimerTime, 1000, 0, 4), int64(1), int64(0), int64(eventActivity), int64(eventTypeStop), local,
+	)
+
+	return e.writeTo(w)
+}
+
+// writeRecord writes the record message for stream point i
+func writeRecord(e *encoder, streams *models.StreamSet, i int, ts int64) {
+	lat, lng := position(streams, i)
+	values := []interface{}{ts, lat, lng, nil, nil, nil, nil, nil, nil, nil}
+	if streams.Altitude != nil {
+		values[3] = scaled(streams.Altitude.Data[i], 5, 500, 2)
+	}
+	if streams.Heartrate != nil {
+		values[4] = clamp(int64(streams.Heartrate.Data[i]), 0xFE)
+	}
+	if streams.Cadence != nil {
+		values[5] = clamp(int64(streams.Cadence.Data[i]), 0xFE)
+	}
+	if streams.Distance != nil {
+		values[6] = scaled(streams.Distance.Data[i], 100, 0, 4)
+	}
+	if streams.VelocitySmooth != nil {
+		values[7] = scaled(streams.VelocitySmooth.Data[i], 1000, 0, 2)
+	}
+	if streams.Watts != nil {
+		values[8] = clamp(int64(streams.Watts.Data[i]), 0xFFFE)
+	}
+	if streams.Temperature != nil {
+		values[9] = int64(math.Max(-127, math.Min(126, float64(streams.Temperature.Data[i]))))
+	}
+	e.write(recordDefinition, values...)
+}
+
+// position returns the semicircle position of stream point i, or nil values
+// when there is no latlng stream
+func position(streams *models.StreamSet, i int) (lat, lng interface{}) {
+	if streams.LatLng == nil {
+		return nil, nil
+	}
+	c := streams.LatLng.Data[i]
+	return semicircles(c[0]), semicircles(c[1])
+}
+
+// optional returns a scaled value, or nil for zero so the field is written
+// as invalid
+func optional(v, scale float64, size int) interface{} {
+	if v <= 0 {
+		return nil
+	}
+	return scaled(v, scale, 0, size)
+}
+
+// clamp limits an unsigned value to [0, max]
+func clamp(v, max int64) int64 {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
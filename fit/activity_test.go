@@ -0,0 +1,184 @@
+package fit
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+func TestWriteActivityRoundTrip(t *testing.T) {
+	activity := &models.Activity{
+		ID:        42,
+		Type:      models.ActivityTypeRide,
+		StartDate: time.Date(2024, 5, 4, 7, 30, 0, 0, time.UTC),
+		Laps:      []models.Lap{{StartIndex: 0}, {StartIndex: 2}},
+	}
+	streams := &models.StreamSet{
+		Time:           &models.TimeStream{Data: []int{0, 1, 2, 4}},
+		LatLng:         &models.LatLngStream{Data: [][]float64{{51.5, -0.1}, {51.5001, -0.1001}, {51.5002, -0.1002}, {51.5004, -0.1004}}},
+		Distance:       &models.DistanceStream{Data: []float64{0, 13.2, 26.4, 52.8}},
+		Altitude:       &models.AltitudeStream{Data: []float64{12.2, 12.4, 13, 12.8}},
+		VelocitySmooth: &models.VelocityStream{Data: []float64{0, 13.2, 13.2, 13.2}},
+		Heartrate:      &models.HeartrateStream{Data: []int{110, 115, 121, 130}},
+		Cadence:        &models.CadenceStream{Data: []int{80, 85, 90, 88}},
+		Watts:          &models.PowerStream{Data: []int{150, 220, 310, 280}},
+		Temperature:    &models.TemperatureStream{Data: []int{18, 18, 19, 19}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteActivity(&buf, activity, streams); err != nil {
+		t.Fatalf("WriteActivity: %v", err)
+	}
+	if CRC(buf.Bytes()) != 0 {
+		t.Error("file CRC does not check out")
+	}
+
+	got, gotStreams, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !got.StartDate.Equal(activity.StartDate) {
+		t.Errorf("start date = %v, want %v", got.StartDate, activity.StartDate)
+	}
+	if got.Type != activity.Type {
+		t.Errorf("type = %q, want %q", got.Type, activity.Type)
+	}
+	if len(got.Laps) != 2 || got.Laps[1].StartIndex != 2 || got.Laps[1].EndIndex != 3 {
+		t.Errorf("laps = %+v, want two laps with the second covering 2 to 3", got.Laps)
+	}
+
+	for name, ints := range map[string][2][]int{
+		"time":      {streams.Time.Data, gotStreams.Time.Data},
+		"heartrate": {streams.Heartrate.Data, gotStreams.Heartrate.Data},
+		"cadence":   {streams.Cadence.Data, gotStreams.Cadence.Data},
+		"watts":     {streams.Watts.Data, gotStreams.Watts.Data},
+		"temp":      {streams.Temperature.Data, gotStreams.Temperature.Data},
+	} {
+		if !slices.Equal(ints[0], ints[1]) {
+			t.Errorf("%s = %v, want %v", name, ints[1], ints[0])
+		}
+	}
+	for name, floats := range map[string][2][]float64{
+		"distance":        {streams.Distance.Data, gotStreams.Distance.Data},
+		"altitude":        {streams.Altitude.Data, gotStreams.Altitude.Data},
+		"velocity_smooth": {streams.VelocitySmooth.Data, gotStreams.VelocitySmooth.Data},
+	} {
+		if !allNear(floats[0], floats[1], 0.2) {
+			t.Errorf("%s = %v, want %v", name, floats[1], floats[0])
+		}
+	}
+	for i, c := range streams.LatLng.Data {
+		if g := gotStreams.LatLng.Data[i]; !near(g[0], c[0], 1e-6) || !near(g[1], c[1], 1e-6) {
+			t.Errorf("latlng[%d] = %v, want %v", i, g, c)
+		}
+	}
+}
+
+// near reports whether a and b differ by at most tolerance
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// allNear reports whether the slices have the same length and near values
+func allNear(a, b []float64, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !near(a[i], b[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWriteCourseRoundTrip(t *testing.T) {
+	start := time.Date(2024, 5, 4, 7, 30, 0, 0, time.UTC)
+	route := &models.Route{
+		Name: "Hill loop",
+		Type: routeTypeRide,
+		Waypoints: []models.Waypoint{
+			{Title: "Cafe", Latlng: []float64{51.5002, -0.1002}, DistanceIntoRoute: 200},
+			{Title: "No position", DistanceIntoRoute: 250},
+			{Title: "Summit", Latlng: []float64{51.5003, -0.1003}, DistanceIntoRoute: 300},
+		},
+	}
+	streams := &models.StreamSet{
+		LatLng:   &models.LatLngStream{Data: [][]float64{{51.5, -0.1}, {51.5001, -0.1001}, {51.5002, -0.1002}, {51.5004, -0.1004}}},
+		Distance: &models.DistanceStream{Data: []float64{0, 100, 200, 400}},
+		Altitude: &models.AltitudeStream{Data: []float64{10, 15, 12, 20}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCourse(&buf, route, streams, &CourseOptions{StartTime: start, Speed: 4}); err != nil {
+		t.Fatalf("WriteCourse: %v", err)
+	}
+	if CRC(buf.Bytes()) != 0 {
+		t.Error("file CRC does not check out")
+	}
+
+	messages, err := decode(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var laps, points []message
+	for _, m := range messages {
+		switch m.global {
+		case mesgLap:
+			laps = append(laps, m)
+		case mesgCoursePoint:
+			points = append(points, m)
+		}
+	}
+
+	ts := float64(timestamp(start))
+	if len(laps) != 1 {
+		t.Fatalf("%d laps, want 1", len(laps))
+	}
+	for num, want := range map[uint8]float64{
+		253: ts + 100,                      // timestamp
+		2:   ts,                            // start_time
+		7:   100 * 1000,                    // total_elapsed_time
+		9:   400 * 100,                     // total_distance
+		21:  13,                            // total_ascent
+		3:   float64(semicircles(51.5)),    // start_position_lat
+		6:   float64(semicircles(-0.1004)), // end_position_long
+	} {
+		if got := laps[0].fields[num]; got != want {
+			t.Errorf("lap field %d = %v, want %v", num, got, want)
+		}
+	}
+
+	// The waypoint without a position is skipped
+	want := []struct {
+		lat, lng, distance float64
+	}{
+		{51.5002, -0.1002, 200},
+		{51.5003, -0.1003, 300},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("%d course points, want %d", len(points), len(want))
+	}
+	for i, w := range want {
+		p := points[i].fields
+		if p[254] != float64(i) || p[1] != ts+w.distance/4 || p[4] != w.distance*100 ||
+			p[2] != float64(semicircles(w.lat)) || p[3] != float64(semicircles(w.lng)) {
+			t.Errorf("course point %d = %v, want %+v", i, p, w)
+		}
+	}
+
+	activity, gotStreams, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !slices.Equal(gotStreams.Time.Data, []int{0, 25, 50, 100}) {
+		t.Errorf("time = %v, want points timed at 4 m/s", gotStreams.Time.Data)
+	}
+	if len(activity.Laps) != 1 || activity.Laps[0].EndIndex != 3 {
+		t.Errorf("laps = %+v, want one lap covering every point", activity.Laps)
+	}
+}
@@ -0,0 +1,181 @@
+package fit
+
+import (
+	"io"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/geo"
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/polyline"
+)
+
+// Strava route types
+const (
+	routeTypeRide = 1
+	routeTypeRun  = 2
+)
+
+// coursePointGeneric is the course point type for waypoints
+const coursePointGeneric = 0
+
+// defaultCourseSpeed is the pace of the virtual partner in m/s when the route
+// has no estimated moving time
+const defaultCourseSpeed = 5.0
+
+var (
+	courseDefinition = &definition{global: mesgCourse, fields: []field{
+		{5, 32, baseString}, // name
+		{4, 1, baseEnum},    // sport
+	}}
+
+	courseLapDefinition = &definition{global: mesgLap, fields: []field{
+		{253, 4, baseUint32}, // timestamp
+		{2, 4, baseUint32},   // start_time
+		{3, 4, baseSint32},   // start_position_lat
+		{4, 4, baseSint32},   // start_position_long
+		{5, 4, baseSint32},   // end_position_lat
+		{6, 4, baseSint32},   // end_position_long
+		{7, 4, baseUint32},   // total_elapsed_time, scale 1000
+		{8, 4, baseUint32},   // total_timer_time, scale 1000
+		{9, 4, baseUint32},   // total_distance, scale 100
+		{21, 2, baseUint16},  // total_ascent
+	}}
+
+	coursePointDefinition = &definition{global: mesgCoursePoint, fields: []field{
+		{254, 2, baseUint16}, // message_index
+		{1, 4, baseUint32},   // timestamp
+		{2, 4, baseSint32},   // position_lat
+		{3, 4, baseSint32},   // position_long
+		{4, 4, baseUint32},   // distance, scale 100
+		{5, 1, baseEnum},     // type
+		{6, 32, baseString},  // name
+	}}
+)
+
+// CourseOptions contains options for writing a course
+type CourseOptions struct {
+	// StartTime is the time of the first point (default: the route's creation time)
+	StartTime time.Time
+
+	// Speed is the virtual partner's speed in m/s (default: the route's
+	// distance over its estimated moving time, or 5)
+	Speed float64
+}
+
+// ValidateCourse checks that the route streams can be written as a FIT
+// course: a latlng stream with valid coordinates and every other stream
+// aligned to it
+func ValidateCourse(streams *models.StreamSet) error {
+	if streams == nil || streams.LatLng == nil || len(streams.LatLng.Data) == 0 {
+		return ErrNoTrack
+	}
+	if err := track.CheckLengths(streams, len(streams.LatLng.Data)); err != nil {
+		return err
+	}
+	return track.CheckCoordinates(streams.LatLng.Data)
+}
+
+// WriteCourse validates the route streams and writes them to w as a FIT
+// course file that head units can navigate. Route streams have no time, so
+// points are timed at a constant speed for the virtual partner. Waypoints
+// become course points, named after their title and placed at their distance
+// into the route.
+func WriteCourse(w io.Writer, route *models.Route, streams *models.StreamSet, opts *CourseOptions) error {
+	if err := ValidateCourse(streams); err != nil {
+		return err
+	}
+
+	o := CourseOptions{StartTime: route.CreatedAt, Speed: defaultCourseSpeed}
+	if route.EstimatedMovingTime > 0 && route.Distance > 0 {
+		o.Speed = route.Distance / float64(route.EstimatedMovingTime)
+	}
+	if opts != nil {
+		if !opts.StartTime.IsZero() {
+			o.StartTime = opts.StartTime
+		}
+		if opts.Speed > 0 {
+			o.Speed = opts.Speed
+		}
+	}
+	if o.StartTime.IsZero() {
+		o.StartTime = time.Now()
+	}
+
+	points := polyline.FromSlices(streams.LatLng.Data)
+	var dist []float64
+	if streams.Distance != nil {
+		dist = streams.Distance.Data
+	} else {
+		dist = geo.CumulativeDistances(points)
+	}
+	start := timestamp(o.StartTime.UTC())
+	timeAt := func(d float64) int64 {
+		return start + int64(d/o.Speed)
+	}
+
+	n := len(points)
+	total := dist[n-1] - dist[0]
+	end := timeAt(total)
+
+	sport := int64(SportGeneric)
+	switch route.Type {
+	case routeTypeRide:
+		sport = SportCycling
+	case routeTypeRun:
+		sport = SportRunning
+	}
+
+	e := newEncoder()
+	e.write(fileIDDefinition, int64(fileCourse), int64(manufacturerDevelopment), int64(0), nil, start)
+	e.write(courseDefinition, route.Name, sport)
+
+	var ascent float64
+	if streams.Altitude != nil {
+		for i := 1; i < n; i++ {
+			if d := streams.Altitude.Data[i] - streams.Altitude.Data[i-1]; d > 0 {
+				ascent += d
+			}
+		}
+	}
+	if route.ElevationGain > 0 {
+		ascent = route.ElevationGain
+	}
+	e.write(courseLapDefinition,
+		end, start,
+		semicircles(points[0].Lat), semicircles(points[0].Lng),
+		semicircles(points[n-1].Lat), semicircles(points[n-1].Lng),
+		scaled(float64(end-start), 1000, 0, 4), scaled(float64(end-start), 1000, 0, 4),
+		scaled(total, 100, 0, 4), optional(ascent, 1, 2),
+	)
+
+	e.write(eventDefinition, start, int64(eventTimer), int64(eventTypeStart))
+	for i, p := range points {
+		d := dist[i] - dist[0]
+		var alt interface{}
+		if streams.Altitude != nil {
+			alt = scaled(streams.Altitude.Data[i], 5, 500, 2)
+		}
+		e.write(recordDefinition,
+			timeAt(d), semicircles(p.Lat), semicircles(p.Lng), alt,
+			nil, nil, scaled(d, 100, 0, 4), scaled(o.Speed, 1000, 0, 2), nil, nil,
+		)
+	}
+
+	var index int64
+	for _, wp := range route.Waypoints {
+		p, ok := models.LatLngFromSlice(wp.Latlng)
+		if !ok {
+			continue
+		}
+		e.write(coursePointDefinition,
+			index, timeAt(wp.DistanceIntoRoute), semicircles(p.Lat), semicircles(p.Lng),
+			scaled(wp.DistanceIntoRoute, 100, 0, 4), int64(coursePointGeneric), wp.Title,
+		)
+		index++
+	}
+
+	e.write(eventDefinition, end, int64(eventTimer), int64(eventTypeStopAll))
+
+	return e.writeTo(w)
+}
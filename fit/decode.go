@@ -0,0 +1,478 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+var (
+	// ErrNotFIT is returned when the data does not start with a FIT header
+	ErrNotFIT = errors.New("fit: not a FIT file")
+
+	// ErrTruncated is returned when the data ends inside a header or message
+	ErrTruncated = errors.New("fit: truncated file")
+
+	// ErrCRC is returned when the header or file CRC does not match
+	ErrCRC = errors.New("fit: CRC mismatch")
+
+	// ErrUndefinedMessage is returned for data messages without a definition
+	ErrUndefinedMessage = errors.New("fit: data message without definition")
+)
+
+// Field numbers read from record, lap and session messages
+const (
+	fieldTimestamp        = 253
+	fieldEnhancedSpeed    = 73
+	fieldEnhancedAltitude = 78
+)
+
+// decodedField is a field definition read from a file
+type decodedField struct {
+	num      uint8
+	size     uint8
+	baseType uint8
+}
+
+// decodedDefinition is a definition message read from a file
+type decodedDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []decodedField
+	devSize   int
+}
+
+// message is a decoded data message with its valid numeric fields
+type message struct {
+	global uint16
+	fields map[uint8]float64
+}
+
+// Parse reads a FIT activity or course file into an activity summary, laps
+// and streams, the reverse of WriteActivity. Record, lap and session
+// messages are read; everything else is skipped. Lap start and end indices
+// refer to the streams. The time stream is only set when every record has a
+// timestamp, and positions missing from some records, such as before a GPS
+// fix, are filled from neighbouring ones.
+func Parse(r io.Reader) (*models.Activity, *models.StreamSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages, err := decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	activity := &models.Activity{}
+	var points []track.Point
+	var session *message
+	var lapStart int
+	for i := range messages {
+		m := &messages[i]
+		switch m.global {
+		case mesgRecord:
+			points = append(points, recordPoint(m))
+		case mesgLap:
+			// Laps usually follow their records, so without times a lap
+			// starts after the records of the previous one
+			activity.Laps = append(activity.Laps, lapFromMessage(m, len(activity.Laps), lapStart))
+			lapStart = len(points)
+		case mesgSession:
+			if session == nil {
+				session = m
+			}
+		}
+	}
+
+	streams, start := track.Streams(points)
+	if streams.Time != nil {
+		activity.StartDate = time.Unix(start, 0).UTC()
+	}
+	track.Summarize(activity, streams)
+
+	if session != nil {
+		if v, ok := session.fields[5]; ok {
+			activity.Type = ActivityType(uint8(v))
+		}
+		if v, ok := session.fields[2]; ok && activity.StartDate.IsZero() {
+			activity.StartDate = fromTimestamp(v)
+		}
+		if v, ok := session.fields[7]; ok {
+			activity.ElapsedTime = v / 1000
+		}
+		if v, ok := session.fields[8]; ok {
+			activity.MovingTime = v / 1000
+		}
+		if v, ok := session.fields[9]; ok {
+			activity.Distance = v / 100
+		}
+		if v, ok := session.fields[11]; ok {
+			activity.Calories = v
+		}
+		if v, ok := session.fields[22]; ok {
+			activity.TotalElevationGain = v
+		}
+	}
+
+	// Place laps by start time when the records have times. Laps end where
+	// the next one starts.
+	if streams.Time != nil {
+		for k := range activity.Laps {
+			if lapTime := activity.Laps[k].StartDate; !lapTime.IsZero() {
+				offset := int(lapTime.Sub(activity.StartDate) / time.Second)
+				activity.Laps[k].StartIndex = sort.SearchInts(streams.Time.Data, offset)
+			}
+		}
+	}
+	for k := range activity.Laps {
+		end := len(points) - 1
+		if k+1 < len(activity.Laps) {
+			end = activity.Laps[k+1].StartIndex - 1
+		}
+		activity.Laps[k].EndIndex = end
+	}
+
+	return activity, streams, nil
+}
+
+// recordPoint converts a record message
+func recordPoint(m *message) track.Point {
+	var p track.Point
+	if v, ok := m.fields[fieldTimestamp]; ok {
+		t := fromTimestamp(v).Unix()
+		p.Time = &t
+	}
+	lat, okLat := m.fields[0]
+	lng, okLng := m.fields[1]
+	if okLat && okLng {
+		lat, lng = lat*180/(1<<31), lng*180/(1<<31)
+		p.Lat, p.Lng = &lat, &lng
+	}
+	if v, ok := m.fields[fieldEnhancedAltitude]; ok {
+		alt := v/5 - 500
+		p.Altitude = &alt
+	} else if v, ok := m.fields[2]; ok {
+		alt := v/5 - 500
+		p.Altitude = &alt
+	}
+	if v, ok := m.fields[5]; ok {
+		d := v / 100
+		p.Distance = &d
+	}
+	if v, ok := m.fields[fieldEnhancedSpeed]; ok {
+		s := v / 1000
+		p.Speed = &s
+	} else if v, ok := m.fields[6]; ok {
+		s := v / 1000
+		p.Speed = &s
+	}
+	p.Heartrate = intField(m, 3)
+	p.Cadence = intField(m, 4)
+	p.Watts = intField(m, 7)
+	p.Temperature = intField(m, 13)
+	return p
+}
+
+// lapFromMessage converts a lap message that starts at record start
+func lapFromMessage(m *message, index, start int) models.Lap {
+	l := models.Lap{
+		Name:     fmt.Sprintf("Lap %d", index+1),
+		LapIndex: index + 1,
+		Split:    index + 1,
+	}
+	if v, ok := m.fields[2]; ok {
+		l.StartDate = fromTimestamp(v)
+		l.StartDateLocal = l.StartDate
+	}
+	if v, ok := m.fields[7]; ok {
+		l.ElapsedTime = v / 1000
+	}
+	if v, ok := m.fields[8]; ok {
+		l.MovingTime = v / 1000
+	}
+	if v, ok := m.fields[9]; ok {
+		l.Distance = v / 100
+	}
+	if v, ok := m.fields[13]; ok {
+		l.AverageSpeed = v / 1000
+	}
+	if v, ok := m.fields[14]; ok {
+		l.MaxSpeed = v / 1000
+	}
+	if v, ok := m.fields[15]; ok {
+		l.AverageHeartrate = v
+	}
+	if v, ok := m.fields[16]; ok {
+		l.MaxHeartrate = int(v)
+	}
+	if v, ok := m.fields[17]; ok {
+		l.AverageCadence = v
+	}
+	if v, ok := m.fields[19]; ok {
+		l.AverageWatts = v
+		l.DeviceWatts = true
+	}
+	if v, ok := m.fields[21]; ok {
+		l.TotalElevationGain = v
+	}
+	l.StartIndex = start
+	return l
+}
+
+// ActivityType maps a FIT sport to an activity type
+func ActivityType(sport uint8) models.ActivityType {
+	switch sport {
+	case SportRunning:
+		return models.ActivityTypeRun
+	case SportCycling:
+		return models.ActivityTypeRide
+	case SportEBiking:
+		return models.ActivityTypeEBikeRide
+	case SportSwimming:
+		return models.ActivityTypeSwim
+	case SportWalking:
+		return models.ActivityTypeWalk
+	case SportHiking:
+		return models.ActivityTypeHike
+	case SportRowing:
+		return models.ActivityTypeRowing
+	case SportAlpineSkiing:
+		return models.ActivityTypeAlpineSki
+	case SportCrossCountrySkiing:
+		return models.ActivityTypeNordicSki
+	case SportSnowboarding:
+		return models.ActivityTypeSnowboard
+	case SportPaddling:
+		return models.ActivityTypeKayaking
+	case SportTraining:
+		return models.ActivityTypeWeightTraining
+	default:
+		return models.ActivityTypeWorkout
+	}
+}
+
+// decode checks the header and CRCs and decodes every data message
+func decode(data []byte) ([]message, error) {
+	if len(data) < 12 || string(data[8:12]) != ".FIT" {
+		return nil, ErrNotFIT
+	}
+	size := int(data[0])
+	if size < 12 || len(data) < size {
+		return nil, ErrTruncated
+	}
+	if size >= 14 {
+		if crc := binary.LittleEndian.Uint16(data[12:]); crc != 0 && crc != CRC(data[:12]) {
+			return nil, fmt.Errorf("%w in header", ErrCRC)
+		}
+	}
+
+	end := size + int(binary.LittleEndian.Uint32(data[4:]))
+	if len(data) < end+2 {
+		return nil, ErrTruncated
+	}
+	if CRC(data[:end+2]) != 0 {
+		return nil, ErrCRC
+	}
+
+	defs := make(map[uint8]*decodedDefinition)
+	var messages []message
+	var lastTimestamp uint32
+	buf := bytes.NewReader(data[size:end])
+	for buf.Len() > 0 {
+		header, _ := buf.ReadByte()
+
+		if header&0x80 != 0 {
+			// Compressed timestamp header
+			local := (header >> 5) & 0x3
+			offset := uint32(header & 0x1F)
+			ts := lastTimestamp&^0x1F + offset
+			if offset < lastTimestamp&0x1F {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+
+			m, err := readMessage(buf, defs[local], local)
+			if err != nil {
+				return nil, err
+			}
+			m.fields[fieldTimestamp] = float64(ts)
+			messages = append(messages, m)
+			continue
+		}
+
+		local := header & 0xF
+		if header&0x40 != 0 {
+			def, err := readDefinition(buf, header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			defs[local] = def
+			continue
+		}
+
+		m, err := readMessage(buf, defs[local], local)
+		if err != nil {
+			return nil, err
+		}
+		if ts, ok := m.fields[fieldTimestamp]; ok {
+			lastTimestamp = uint32(ts)
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// readDefinition reads a definition message after its header byte
+func readDefinition(buf *bytes.Reader, developer bool) (*decodedDefinition, error) {
+	var fixed [5]byte
+	if _, err := io.ReadFull(buf, fixed[:]); err != nil {
+		return nil, ErrTruncated
+	}
+	def := &decodedDefinition{bigEndian: fixed[1] == 1}
+	if def.bigEndian {
+		def.global = binary.BigEndian.Uint16(fixed[2:])
+	} else {
+		def.global = binary.LittleEndian.Uint16(fixed[2:])
+	}
+
+	def.fields = make([]decodedField, fixed[4])
+	for i := range def.fields {
+		var f [3]byte
+		if _, err := io.ReadFull(buf, f[:]); err != nil {
+			return nil, ErrTruncated
+		}
+		def.fields[i] = decodedField{num: f[0], size: f[1], baseType: f[2]}
+	}
+
+	if developer {
+		n, err := buf.ReadByte()
+		if err != nil {
+			return nil, ErrTruncated
+		}
+		for i := 0; i < int(n); i++ {
+			var f [3]byte
+			if _, err := io.ReadFull(buf, f[:]); err != nil {
+				return nil, ErrTruncated
+			}
+			def.devSize += int(f[1])
+		}
+	}
+	return def, nil
+}
+
+// readMessage reads a data message's fields, keeping valid single numeric
+// values and skipping arrays, strings and developer fields
+func readMessage(buf *bytes.Reader, def *decodedDefinition, local uint8) (message, error) {
+	if def == nil {
+		return message{}, fmt.Errorf("%w for local type %d", ErrUndefinedMessage, local)
+	}
+
+	m := message{global: def.global, fields: make(map[uint8]float64, len(def.fields))}
+	for _, f := range def.fields {
+		b := make([]byte, f.size)
+		if _, err := io.ReadFull(buf, b); err != nil {
+			return message{}, ErrTruncated
+		}
+		if v, ok := fieldValue(b, f.baseType, def.bigEndian); ok {
+			m.fields[f.num] = v
+		}
+	}
+	if buf.Len() < def.devSize {
+		return message{}, ErrTruncated
+	}
+	buf.Seek(int64(def.devSize), io.SeekCurrent)
+	return m, nil
+}
+
+// fieldValue decodes a single numeric value, reporting false for invalid
+// values and for fields whose size does not match their base type
+func fieldValue(b []byte, baseType uint8, bigEndian bool) (float64, bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	switch baseType {
+	case baseEnum, baseUint8, baseUint8z:
+		if len(b) != 1 || b[0] == 0xFF || (baseType == baseUint8z && b[0] == 0) {
+			return 0, false
+		}
+		return float64(b[0]), true
+	case baseSint8:
+		if len(b) != 1 || b[0] == 0x7F {
+			return 0, false
+		}
+		return float64(int8(b[0])), true
+	case baseUint16, baseUint16z:
+		if len(b) != 2 {
+			return 0, false
+		}
+		v := order.Uint16(b)
+		if v == 0xFFFF || (baseType == baseUint16z && v == 0) {
+			return 0, false
+		}
+		return float64(v), true
+	case baseSint16:
+		if len(b) != 2 {
+			return 0, false
+		}
+		v := order.Uint16(b)
+		if v == 0x7FFF {
+			return 0, false
+		}
+		return float64(int16(v)), true
+	case baseUint32, baseUint32z:
+		if len(b) != 4 {
+			return 0, false
+		}
+		v := order.Uint32(b)
+		if v == 0xFFFFFFFF || (baseType == baseUint32z && v == 0) {
+			return 0, false
+		}
+		return float64(v), true
+	case baseSint32:
+		if len(b) != 4 {
+			return 0, false
+		}
+		v := order.Uint32(b)
+		if v == 0x7FFFFFFF {
+			return 0, false
+		}
+		return float64(int32(v)), true
+	case baseFloat32:
+		if len(b) != 4 {
+			return 0, false
+		}
+		v := math.Float32frombits(order.Uint32(b))
+		if math.IsNaN(float64(v)) {
+			return 0, false
+		}
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// intField returns a field as an int pointer, or nil when it is missing
+func intField(m *message, num uint8) *int {
+	v, ok := m.fields[num]
+	if !ok {
+		return nil
+	}
+	i := int(v)
+	return &i
+}
+
+// fromTimestamp converts seconds since the FIT epoch to a time
+func fromTimestamp(v float64) time.Time {
+	return fitEpoch.Add(time.Duration(v) * time.Second)
+}
@@ -0,0 +1,95 @@
+package fit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// records.fit is a FIT file built by hand: a 14 byte header with its CRC, a
+// record definition with timestamp, position and heart rate fields, three
+// records and the file CRC
+const knownFile = "testdata/records.fit"
+
+func TestCRC(t *testing.T) {
+	data, err := os.ReadFile(knownFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want uint16
+	}{
+		{"empty", nil, 0},
+		{"check value", []byte("123456789"), 0xBB3D},
+		{"header", data[:12], 0x90BA},
+		{"file", data[:len(data)-2], 0x65CB},
+		{"file with its CRC", data, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CRC(tt.data); got != tt.want {
+				t.Errorf("CRC = %#04x, want %#04x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKnownFile(t *testing.T) {
+	data, err := os.ReadFile(knownFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activity, streams, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := time.Date(2021, 9, 8, 1, 46, 40, 0, time.UTC); !activity.StartDate.Equal(want) {
+		t.Errorf("start date = %v, want %v", activity.StartDate, want)
+	}
+	if streams.Time == nil || !slices.Equal(streams.Time.Data, []int{0, 1, 3}) {
+		t.Errorf("time = %v, want [0 1 3]", streams.Time)
+	}
+	if streams.Heartrate == nil || !slices.Equal(streams.Heartrate.Data, []int{120, 125, 131}) {
+		t.Errorf("heartrate = %v, want [120 125 131]", streams.Heartrate)
+	}
+	if streams.LatLng == nil || len(streams.LatLng.Data) != 3 || !near(streams.LatLng.Data[2][0], 51.5003, 1e-6) || !near(streams.LatLng.Data[2][1], -0.1003, 1e-6) {
+		t.Errorf("latlng = %v, want 3 points ending at [51.5003 -0.1003]", streams.LatLng)
+	}
+}
+
+func TestParseInvalidFile(t *testing.T) {
+	data, err := os.ReadFile(knownFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(i int) []byte {
+		d := slices.Clone(data)
+		d[i] ^= 0xFF
+		return d
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not FIT", []byte("<gpx></gpx> and more"), ErrNotFIT},
+		{"truncated", data[:len(data)-5], ErrTruncated},
+		{"header CRC", corrupt(3), ErrCRC},
+		{"file CRC", corrupt(40), ErrCRC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Parse(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("Parse = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	headerSize      = 14
+	protocolVersion = 0x20 // 2.0
+	profileVersion  = 2132 // 21.32
+)
+
+// Base types
+const (
+	baseEnum    = 0x00
+	baseSint8   = 0x01
+	baseUint8   = 0x02
+	baseSint16  = 0x83
+	baseUint16  = 0x84
+	baseSint32  = 0x85
+	baseUint32  = 0x86
+	baseString  = 0x07
+	baseFloat32 = 0x88
+	baseUint8z  = 0x0A
+	baseUint16z = 0x8B
+	baseUint32z = 0x8C
+)
+
+// Global message numbers
+const (
+	mesgFileID      = 0
+	mesgSession     = 18
+	mesgLap         = 19
+	mesgRecord      = 20
+	mesgEvent       = 21
+	mesgCourse      = 31
+	mesgCoursePoint = 32
+	mesgActivity    = 34
+)
+
+// fitEpoch is the FIT timestamp origin, 1989-12-31T00:00:00Z
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// field is a field definition
+type field struct {
+	num      uint8
+	size     uint8
+	baseType uint8
+}
+
+// definition is a message definition
+type definition struct {
+	global uint16
+	fields []field
+}
+
+// encoder builds the data records of a FIT file in memory, since the header
+// holds their total size
+type encoder struct {
+	data  bytes.Buffer
+	local map[*definition]uint8
+}
+
+func newEncoder() *encoder {
+	return &encoder{local: make(map[*definition]uint8)}
+}
+
+// write writes a data message, preceded by its definition the first time the
+// definition is used. Values are int64 for numeric fields, string for string
+// fields, or nil for an invalid value.
+func (e *encoder) write(def *definition, values ...interface{}) {
+	local, ok := e.local[def]
+	if !ok {
+		local = uint8(len(e.local))
+		e.local[def] = local
+		e.writeDefinition(def, local)
+	}
+
+	e.data.WriteByte(local)
+	for i, f := range def.fields {
+		e.writeValue(f, values[i])
+	}
+}
+
+// writeDefinition writes a definition message for a local message type
+func (e *encoder) writeDefinition(def *definition, local uint8) {
+	e.data.WriteByte(0x40 | local)
+	e.data.WriteByte(0) // reserved
+	e.data.WriteByte(0) // little endian
+	binary.Write(&e.data, binary.LittleEndian, def.global)
+	e.data.WriteByte(uint8(len(def.fields)))
+	for _, f := range def.fields {
+		e.data.Write([]byte{f.num, f.size, f.baseType})
+	}
+}
+
+// writeValue writes a field value, or the base type's invalid value for nil
+func (e *encoder) writeValue(f field, value interface{}) {
+	if f.baseType == baseString {
+		b := make([]byte, f.size)
+		if s, ok := value.(string); ok {
+			copy(b[:f.size-1], truncateUTF8(s, int(f.size)-1))
+		}
+		e.data.Write(b)
+		return
+	}
+
+	v, ok := value.(int64)
+	if !ok {
+		v = invalidValue(f.baseType)
+	}
+
+	var b [4]byte
+	switch f.size {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(b[:], uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+	}
+	e.data.Write(b[:f.size])
+}
+
+// invalidValue returns the value that marks a field as unset
+func invalidValue(baseType uint8) int64 {
+	switch baseType {
+	case baseSint8:
+		return 0x7F
+	case baseSint16:
+		return 0x7FFF
+	case baseSint32:
+		return 0x7FFFFFFF
+	case baseUint16:
+		return 0xFFFF
+	case baseUint32:
+		return 0xFFFFFFFF
+	case baseUint32z:
+		return 0
+	default:
+		return 0xFF
+	}
+}
+
+// writeTo writes the file header, data records and CRC to w
+func (e *encoder) writeTo(w io.Writer) error {
+	header := make([]byte, headerSize)
+	header[0] = headerSize
+	header[1] = protocolVersion
+	binary.LittleEndian.PutUint16(header[2:], profileVersion)
+	binary.LittleEndian.PutUint32(header[4:], uint32(e.data.Len()))
+	copy(header[8:], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:], CRC(header[:12]))
+
+	crc := CRC(header)
+	crc = updateCRC(crc, e.data.Bytes())
+	var trailer [2]byte
+	binary.LittleEndian.PutUint16(trailer[:], crc)
+
+	for _, b := range [][]byte{header, e.data.Bytes(), trailer[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var crcTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// CRC computes the FIT CRC-16 of data
+func CRC(data []byte) uint16 {
+	return updateCRC(0, data)
+}
+
+// updateCRC continues a CRC over more data
+func updateCRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		tmp := crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[b&0xF]
+
+		tmp = crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// timestamp converts a time to seconds since the FIT epoch
+func timestamp(t time.Time) int64 {
+	return int64(t.Sub(fitEpoch) / time.Second)
+}
+
+// semicircles converts degrees to FIT semicircles
+func semicircles(degrees float64) int64 {
+	return int64(math.Round(degrees * (1 << 31) / 180))
+}
+
+// scaled applies a FIT scale and offset, clamping to the range of an unsigned
+// field of the given size so the invalid value is never written by accident
+func scaled(v, scale, offset float64, size int) int64 {
+	max := float64(uint64(1)<<(8*size) - 2)
+	return int64(math.Round(math.Max(0, math.Min(max, (v+offset)*scale))))
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
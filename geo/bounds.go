@@ -0,0 +1,95 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// BoundingBox is a rectangle of latitude and longitude
+type BoundingBox struct {
+	SouthWest models.LatLng `json:"south_west"`
+	NorthEast models.LatLng `json:"north_east"`
+}
+
+// Bounds returns the bounding box of the points
+func Bounds(points []models.LatLng) (BoundingBox, bool) {
+	if len(points) == 0 {
+		return BoundingBox{}, false
+	}
+
+	box := BoundingBox{SouthWest: points[0], NorthEast: points[0]}
+	for _, p := range points[1:] {
+		box = box.Extend(p)
+	}
+	return box, true
+}
+
+// Extend returns the box grown to include p
+func (b BoundingBox) Extend(p models.LatLng) BoundingBox {
+	b.SouthWest.Lat = math.Min(b.SouthWest.Lat, p.Lat)
+	b.SouthWest.Lng = math.Min(b.SouthWest.Lng, p.Lng)
+	b.NorthEast.Lat = math.Max(b.NorthEast.Lat, p.Lat)
+	b.NorthEast.Lng = math.Max(b.NorthEast.Lng, p.Lng)
+	return b
+}
+
+// Union returns the smallest box containing both boxes
+func (b BoundingBox) Union(other BoundingBox) BoundingBox {
+	return b.Extend(other.SouthWest).Extend(other.NorthEast)
+}
+
+// Contains reports whether p lies within the box
+func (b BoundingBox) Contains(p models.LatLng) bool {
+	return p.Lat >= b.SouthWest.Lat && p.Lat <= b.NorthEast.Lat &&
+		p.Lng >= b.SouthWest.Lng && p.Lng <= b.NorthEast.Lng
+}
+
+// Center returns the midpoint of the box
+func (b BoundingBox) Center() models.LatLng {
+	return models.LatLng{
+		Lat: (b.SouthWest.Lat + b.NorthEast.Lat) / 2,
+		Lng: (b.SouthWest.Lng + b.NorthEast.Lng) / 2,
+	}
+}
+
+// Pad returns the box grown by meters on every side
+func (b BoundingBox) Pad(meters float64) BoundingBox {
+	dLat := meters / earthRadius * 180 / math.Pi
+	dLng := dLat / math.Max(1e-6, math.Cos(b.Center().Lat*math.Pi/180))
+	b.SouthWest.Lat -= dLat
+	b.SouthWest.Lng -= dLng
+	b.NorthEast.Lat += dLat
+	b.NorthEast.Lng += dLng
+	return b
+}
+
+// Slice returns the box as [SW lat, SW lng, NE lat, NE lng], the format of
+// ExploreOptions.Bounds
+func (b BoundingBox) Slice() []float64 {
+	return []float64{b.SouthWest.Lat, b.SouthWest.Lng, b.NorthEast.Lat, b.NorthEast.Lng}
+}
+
+// Centroid returns the mean position of the points, averaged on the sphere so
+// paths crossing the antimeridian are handled
+func Centroid(points []models.LatLng) (models.LatLng, bool) {
+	if len(points) == 0 {
+		return models.LatLng{}, false
+	}
+
+	var x, y, z float64
+	for _, p := range points {
+		lat := p.Lat * math.Pi / 180
+		lng := p.Lng * math.Pi / 180
+		x += math.Cos(lat) * math.Cos(lng)
+		y += math.Cos(lat) * math.Sin(lng)
+		z += math.Sin(lat)
+	}
+
+	n := float64(len(points))
+	x, y, z = x/n, y/n, z/n
+	return models.LatLng{
+		Lat: math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi,
+		Lng: math.Atan2(y, x) * 180 / math.Pi,
+	}, true
+}
@@ -0,0 +1,130 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+const earthRadius = 6371000 // meters, as used by utils.CalculateDistance
+
+// Distance returns the great-circle distance between two points in meters
+func Distance(a, b models.LatLng) float64 {
+	return utils.CalculateDistance(a.Lat, a.Lng, b.Lat, b.Lng)
+}
+
+// PathLength returns the total length of a path in meters
+func PathLength(path []models.LatLng) float64 {
+	var total float64
+	for i := 1; i < len(path); i++ {
+		total += Distance(path[i-1], path[i])
+	}
+	return total
+}
+
+// CumulativeDistances returns the distance along the path at each point, in
+// the same form as a distance stream
+func CumulativeDistances(path []models.LatLng) []float64 {
+	dist := make([]float64, len(path))
+	for i := 1; i < len(path); i++ {
+		dist[i] = dist[i-1] + Distance(path[i-1], path[i])
+	}
+	return dist
+}
+
+// PathProjection is the closest point on a path to another point
+type PathProjection struct {
+	Point         models.LatLng `json:"point"`          // closest point on the path
+	Distance      float64       `json:"distance"`       // meters from the point to the path
+	AlongTrack    float64       `json:"along_track"`    // meters from the start of the path
+	SegmentIndex  int           `json:"segment_index"`  // index of the segment's first point
+	SegmentOffset float64       `json:"segment_offset"` // fraction along the segment, 0 to 1
+}
+
+// ProjectOntoPath finds the closest point on a path. Segments are treated as
+// straight lines in a local projection around the point, which is accurate
+// for the short segments found in activity and route geometry.
+func ProjectOntoPath(p models.LatLng, path []models.LatLng) (PathProjection, bool) {
+	if len(path) == 0 {
+		return PathProjection{}, false
+	}
+	if len(path) == 1 {
+		return PathProjection{Point: path[0], Distance: Distance(p, path[0])}, true
+	}
+
+	best := PathProjection{Distance: math.Inf(1)}
+	var along float64
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		t := segmentFraction(p, a, b)
+		q := interpolate(a, b, t)
+
+		if d := Distance(p, q); d < best.Distance {
+			best = PathProjection{
+				Point:         q,
+				Distance:      d,
+				AlongTrack:    along + Distance(a, q),
+				SegmentIndex:  i - 1,
+				SegmentOffset: t,
+			}
+		}
+		along += Distance(a, b)
+	}
+	return best, true
+}
+
+// DistanceToPath returns the shortest distance in meters from a point to a path
+func DistanceToPath(p models.LatLng, path []models.LatLng) float64 {
+	proj, ok := ProjectOntoPath(p, path)
+	if !ok {
+		return math.Inf(1)
+	}
+	return proj.Distance
+}
+
+// AlongTrackDistance returns how far along a path, in meters, the point
+// closest to p lies
+func AlongTrackDistance(p models.LatLng, path []models.LatLng) float64 {
+	proj, _ := ProjectOntoPath(p, path)
+	return proj.AlongTrack
+}
+
+// segmentFraction returns the fraction along segment ab of the point closest to p
+func segmentFraction(p, a, b models.LatLng) float64 {
+	ax, ay := project(a, p.Lat)
+	bx, by := project(b, p.Lat)
+	px, py := project(p, p.Lat)
+
+	dx, dy := bx-ax, by-ay
+	length := dx*dx + dy*dy
+	if length == 0 {
+		return 0
+	}
+	t := ((px-ax)*dx + (py-ay)*dy) / length
+	return math.Max(0, math.Min(1, t))
+}
+
+// perpendicularDistance returns the planar distance in meters from p to segment ab
+func perpendicularDistance(p, a, b models.LatLng) float64 {
+	q := interpolate(a, b, segmentFraction(p, a, b))
+	px, py := project(p, p.Lat)
+	qx, qy := project(q, p.Lat)
+	return math.Hypot(px-qx, py-qy)
+}
+
+// project converts a point to planar meters with an equirectangular
+// projection around a reference latitude
+func project(p models.LatLng, refLat float64) (x, y float64) {
+	x = p.Lng * math.Pi / 180 * earthRadius * math.Cos(refLat*math.Pi/180)
+	y = p.Lat * math.Pi / 180 * earthRadius
+	return x, y
+}
+
+// interpolate returns the point a fraction t of the way from a to b
+func interpolate(a, b models.LatLng, t float64) models.LatLng {
+	return models.LatLng{
+		Lat: a.Lat + (b.Lat-a.Lat)*t,
+		Lng: a.Lng + (b.Lng-a.Lng)*t,
+	}
+}
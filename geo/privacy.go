@@ -0,0 +1,207 @@
+package geo
+
+import (
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/polyline"
+)
+
+// PrivacyZone is a circle around a private location, such as a home or office
+type PrivacyZone struct {
+	Center models.LatLng `json:"center"`
+	Radius float64       `json:"radius"` // meters
+}
+
+// Contains reports whether p lies within the zone
+func (z PrivacyZone) Contains(p models.LatLng) bool {
+	return Distance(z.Center, p) <= z.Radius
+}
+
+// PrivacyOptions contains the rules for hiding activity geometry
+type PrivacyOptions struct {
+	// Zones are the private locations; points inside any zone are hidden
+	Zones []PrivacyZone
+
+	// HideStart is the distance in meters hidden from the start of the activity
+	HideStart float64
+
+	// HideEnd is the distance in meters hidden from the end of the activity
+	HideEnd float64
+}
+
+// HiddenMask reports for each point whether it must be hidden. distances are
+// the cumulative distances at each point, such as a distance stream; pass nil
+// to measure along the points themselves.
+func HiddenMask(points []models.LatLng, distances []float64, opts *PrivacyOptions) []bool {
+	hidden := make([]bool, len(points))
+	if opts == nil || len(points) == 0 {
+		return hidden
+	}
+	if len(distances) != len(points) {
+		distances = CumulativeDistances(points)
+	}
+
+	total := distances[len(distances)-1]
+	for i, p := range points {
+		d := distances[i]
+		if (opts.HideStart > 0 && d-distances[0] < opts.HideStart) || (opts.HideEnd > 0 && total-d < opts.HideEnd) {
+			hidden[i] = true
+			continue
+		}
+		for _, z := range opts.Zones {
+			if z.Contains(p) {
+				hidden[i] = true
+				break
+			}
+		}
+	}
+	return hidden
+}
+
+// MaskPoints returns the points that are not hidden
+func MaskPoints(points []models.LatLng, opts *PrivacyOptions) []models.LatLng {
+	return keepUnhidden(points, HiddenMask(points, nil, opts))
+}
+
+// MaskPolyline decodes a precision 5 polyline, removes the hidden points and
+// encodes the result. Start and end distances are measured along the polyline,
+// so on a summary polyline slightly more than requested may be hidden.
+func MaskPolyline(encoded string, opts *PrivacyOptions) (string, error) {
+	if encoded == "" {
+		return "", nil
+	}
+	points, err := polyline.Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	return polyline.Encode(MaskPoints(points, opts)), nil
+}
+
+// MaskStreams returns a copy of the streams with every hidden sample removed
+// from all of them, so the streams stay aligned. Hidden samples are found from
+// the latlng stream, using the distance stream for the start and end distances
+// when present. Samples without a valid location are hidden, and streams whose
+// length does not match the latlng stream are dropped. Streams without a
+// latlng stream are returned unchanged.
+func MaskStreams(streams *models.StreamSet, opts *PrivacyOptions) *models.StreamSet {
+	if streams == nil {
+		return nil
+	}
+	masked := *streams
+	if streams.LatLng == nil {
+		return &masked
+	}
+
+	n := len(streams.LatLng.Data)
+	points := make([]models.LatLng, n)
+	valid := make([]bool, n)
+	for i, c := range streams.LatLng.Data {
+		points[i], valid[i] = models.LatLngFromSlice(c)
+	}
+
+	var distances []float64
+	if streams.Distance != nil && len(streams.Distance.Data) == n {
+		distances = streams.Distance.Data
+	} else {
+		distances = make([]float64, n)
+		last := -1
+		for i := range points {
+			if i > 0 {
+				distances[i] = distances[i-1]
+			}
+			if !valid[i] {
+				continue
+			}
+			if last >= 0 {
+				distances[i] += Distance(points[last], points[i])
+			}
+			last = i
+		}
+	}
+
+	hidden := HiddenMask(points, distances, opts)
+	for i := range hidden {
+		hidden[i] = hidden[i] || !valid[i]
+	}
+
+	masked.LatLng = maskStream(streams.LatLng, hidden, func(s *models.LatLngStream) (*models.BaseStream, *[][]float64) { return &s.BaseStream, &s.Data })
+	masked.Time = maskStream(streams.Time, hidden, func(s *models.TimeStream) (*models.BaseStream, *[]int) { return &s.BaseStream, &s.Data })
+	masked.Distance = maskStream(streams.Distance, hidden, func(s *models.DistanceStream) (*models.BaseStream, *[]float64) { return &s.BaseStream, &s.Data })
+	masked.Altitude = maskStream(streams.Altitude, hidden, func(s *models.AltitudeStream) (*models.BaseStream, *[]float64) { return &s.BaseStream, &s.Data })
+	masked.VelocitySmooth = maskStream(streams.VelocitySmooth, hidden, func(s *models.VelocityStream) (*models.BaseStream, *[]float64) { return &s.BaseStream, &s.Data })
+	masked.Heartrate = maskStream(streams.Heartrate, hidden, func(s *models.HeartrateStream) (*models.BaseStream, *[]int) { return &s.BaseStream, &s.Data })
+	masked.Cadence = maskStream(streams.Cadence, hidden, func(s *models.CadenceStream) (*models.BaseStream, *[]int) { return &s.BaseStream, &s.Data })
+	masked.Watts = maskStream(streams.Watts, hidden, func(s *models.PowerStream) (*models.BaseStream, *[]int) { return &s.BaseStream, &s.Data })
+	masked.Temperature = maskStream(streams.Temperature, hidden, func(s *models.TemperatureStream) (*models.BaseStream, *[]int) { return &s.BaseStream, &s.Data })
+	masked.Moving = maskStream(streams.Moving, hidden, func(s *models.MovingStream) (*models.BaseStream, *[]bool) { return &s.BaseStream, &s.Data })
+	masked.GradeSmooth = maskStream(streams.GradeSmooth, hidden, func(s *models.GradeStream) (*models.BaseStream, *[]float64) { return &s.BaseStream, &s.Data })
+
+	return &masked
+}
+
+// MaskActivity returns copies of the activity and its streams with private
+// geometry removed: the full and summary polylines are masked and re-encoded,
+// the streams are masked with MaskStreams, and the start and end locations
+// become the first and last visible points. The inputs are not modified and
+// streams may be nil.
+func MaskActivity(activity *models.Activity, streams *models.StreamSet, opts *PrivacyOptions) (*models.Activity, *models.StreamSet, error) {
+	masked := *activity
+	maskedStreams := MaskStreams(streams, opts)
+
+	var visible []models.LatLng
+	if activity.Map != nil {
+		m := *activity.Map
+		var err error
+		if m.Polyline, err = MaskPolyline(m.Polyline, opts); err != nil {
+			return nil, nil, err
+		}
+		if m.SummaryPolyline, err = MaskPolyline(m.SummaryPolyline, opts); err != nil {
+			return nil, nil, err
+		}
+		masked.Map = &m
+
+		// Prefer the full polyline for the start and end locations
+		for _, encoded := range []string{m.SummaryPolyline, m.Polyline} {
+			if encoded != "" {
+				visible, _ = polyline.Decode(encoded)
+			}
+		}
+	}
+	if maskedStreams != nil && maskedStreams.LatLng != nil {
+		visible = polyline.FromSlices(maskedStreams.LatLng.Data)
+	}
+
+	masked.StartLatlng, masked.EndLatlng = nil, nil
+	if len(visible) > 0 {
+		masked.StartLatlng = visible[0].Slice()
+		masked.EndLatlng = visible[len(visible)-1].Slice()
+	}
+
+	return &masked, maskedStreams, nil
+}
+
+// maskStream returns a copy of a stream without its hidden samples, or nil
+// when the stream is missing or not aligned with the mask
+func maskStream[S, T any](s *S, hidden []bool, fields func(*S) (*models.BaseStream, *[]T)) *S {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	base, data := fields(&c)
+	if len(*data) != len(hidden) {
+		return nil
+	}
+	*data = keepUnhidden(*data, hidden)
+	base.OriginalSize = len(*data)
+	return &c
+}
+
+// keepUnhidden returns the values whose hidden flag is not set
+func keepUnhidden[T any](values []T, hidden []bool) []T {
+	out := make([]T, 0, len(values))
+	for i, v := range values {
+		if !hidden[i] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
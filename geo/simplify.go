@@ -0,0 +1,214 @@
+package geo
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/polyline"
+)
+
+// SimplifyMethod selects a line simplification algorithm
+type SimplifyMethod string
+
+const (
+	SimplifyDouglasPeucker SimplifyMethod = "douglas_peucker"
+	SimplifyVisvalingam    SimplifyMethod = "visvalingam"
+)
+
+// DouglasPeucker simplifies a path, keeping every point that lies more than
+// tolerance meters from the simplified line
+func DouglasPeucker(points []models.LatLng, tolerance float64) []models.LatLng {
+	if len(points) < 3 {
+		return append([]models.LatLng(nil), points...)
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		index, maxDist := -1, tolerance
+		for i := s.first + 1; i < s.last; i++ {
+			if d := perpendicularDistance(points[i], points[s.first], points[s.last]); d > maxDist {
+				index, maxDist = i, d
+			}
+		}
+		if index < 0 {
+			continue
+		}
+
+		keep[index] = true
+		stack = append(stack, span{s.first, index}, span{index, s.last})
+	}
+
+	return keptPoints(points, keep)
+}
+
+// Visvalingam simplifies a path by repeatedly removing the point that forms
+// the smallest triangle with its neighbours, until every remaining triangle is
+// at least minArea square meters or only count points remain. Pass zero to
+// disable either limit.
+func Visvalingam(points []models.LatLng, minArea float64, count int) []models.LatLng {
+	n := len(points)
+	if n < 3 || (minArea <= 0 && (count <= 0 || count >= n)) {
+		return append([]models.LatLng(nil), points...)
+	}
+	if count > 0 && count < 2 {
+		count = 2
+	}
+
+	prev := make([]int, n)
+	next := make([]int, n)
+	for i := range points {
+		prev[i], next[i] = i-1, i+1
+	}
+
+	items := make([]*vertex, n)
+	h := make(vertexHeap, 0, n-2)
+	for i := 1; i < n-1; i++ {
+		items[i] = &vertex{index: i, area: triangleArea(points[i-1], points[i], points[i+1]), heapIndex: len(h)}
+		h = append(h, items[i])
+	}
+	heap.Init(&h)
+
+	keep := make([]bool, n)
+	for i := range keep {
+		keep[i] = true
+	}
+
+	remaining := n
+	var lastArea float64
+	for h.Len() > 0 {
+		v := h[0]
+		if count > 0 && remaining <= count {
+			break
+		}
+		if minArea > 0 && v.area >= minArea {
+			break
+		}
+		heap.Pop(&h)
+
+		keep[v.index] = false
+		remaining--
+		// Areas never decrease, so points removed later always count as
+		// at least as significant as the ones before them
+		lastArea = math.Max(lastArea, v.area)
+
+		p, q := prev[v.index], next[v.index]
+		next[p], prev[q] = q, p
+		for _, j := range []int{p, q} {
+			if j <= 0 || j >= n-1 {
+				continue
+			}
+			items[j].area = math.Max(lastArea, triangleArea(points[prev[j]], points[j], points[next[j]]))
+			heap.Fix(&h, items[j].heapIndex)
+		}
+	}
+
+	return keptPoints(points, keep)
+}
+
+// SimplifyToCount simplifies a path down to at most count points
+func SimplifyToCount(points []models.LatLng, count int, method SimplifyMethod) []models.LatLng {
+	if count <= 0 || len(points) <= count {
+		return append([]models.LatLng(nil), points...)
+	}
+	if method == SimplifyVisvalingam {
+		return Visvalingam(points, 0, count)
+	}
+
+	// Binary search for the smallest tolerance that meets the count
+	lo, hi := 0.0, PathLength(points)
+	best := DouglasPeucker(points, hi)
+	for i := 0; i < 40 && hi-lo > 0.01; i++ {
+		mid := (lo + hi) / 2
+		if simplified := DouglasPeucker(points, mid); len(simplified) <= count {
+			best, hi = simplified, mid
+		} else {
+			lo = mid
+		}
+	}
+	return best
+}
+
+// SimplifyPolyline decodes a precision 5 polyline, simplifies it with
+// Douglas-Peucker and encodes the result
+func SimplifyPolyline(encoded string, tolerance float64) (string, error) {
+	points, err := polyline.Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	return polyline.Encode(DouglasPeucker(points, tolerance)), nil
+}
+
+// PolylineBounds returns the bounding box of a precision 5 polyline
+func PolylineBounds(encoded string) (BoundingBox, error) {
+	var box BoundingBox
+	d := polyline.NewDecoder(encoded, polyline.Precision5)
+	first := true
+	for p, ok := d.Next(); ok; p, ok = d.Next() {
+		if first {
+			box = BoundingBox{SouthWest: p, NorthEast: p}
+			first = false
+			continue
+		}
+		box = box.Extend(p)
+	}
+	return box, d.Err()
+}
+
+// triangleArea returns the planar area in square meters of the triangle abc
+func triangleArea(a, b, c models.LatLng) float64 {
+	ax, ay := project(a, b.Lat)
+	bx, by := project(b, b.Lat)
+	cx, cy := project(c, b.Lat)
+	return math.Abs((bx-ax)*(cy-ay)-(cx-ax)*(by-ay)) / 2
+}
+
+// keptPoints returns the points whose keep flag is set
+func keptPoints(points []models.LatLng, keep []bool) []models.LatLng {
+	var out []models.LatLng
+	for i, p := range points {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// vertex is a point in the Visvalingam heap
+type vertex struct {
+	index     int
+	area      float64
+	heapIndex int
+}
+
+// vertexHeap is a min-heap of vertices by area
+type vertexHeap []*vertex
+
+func (h vertexHeap) Len() int           { return len(h) }
+func (h vertexHeap) Less(i, j int) bool { return h[i].area < h[j].area }
+
+func (h vertexHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIndex = i
+	h[j].heapIndex = j
+}
+
+func (h *vertexHeap) Push(x interface{}) {
+	v := x.(*vertex)
+	v.heapIndex = len(*h)
+	*h = append(*h, v)
+}
+
+func (h *vertexHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
@@ -0,0 +1,265 @@
+package geojson
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/polyline"
+)
+
+// Geometry types
+const (
+	TypePoint      = "Point"
+	TypeLineString = "LineString"
+)
+
+// ErrNoGeometry is returned when an object has no streams or polyline with
+// at least two valid points
+var ErrNoGeometry = errors.New("geojson: no geometry")
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature. Properties hold the object's summary fields
+// under their Strava API names, plus a "kind" such as "activity" or "waypoint".
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         int64                  `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON point or line string. Coordinates are a position
+// for points and a slice of positions for line strings, with each position
+// ordered longitude, latitude and optionally elevation.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// NewFeatureCollection returns a collection of the features
+func NewFeatureCollection(features ...*Feature) *FeatureCollection {
+	if features == nil {
+		features = []*Feature{}
+	}
+	return &FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// NewPoint returns a point geometry
+func NewPoint(p models.LatLng) *Geometry {
+	return &Geometry{Type: TypePoint, Coordinates: []float64{p.Lng, p.Lat}}
+}
+
+// NewLineString returns a line string geometry through the points
+func NewLineString(points []models.LatLng) *Geometry {
+	coords := make([][]float64, len(points))
+	for i, p := range points {
+		coords[i] = []float64{p.Lng, p.Lat}
+	}
+	return &Geometry{Type: TypeLineString, Coordinates: coords}
+}
+
+// Write writes the features to w as a feature collection
+func Write(w io.Writer, features ...*Feature) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(NewFeatureCollection(features...))
+}
+
+// Activity returns a line string feature for an activity. The line follows
+// the latlng stream, with elevations from an aligned altitude stream, or the
+// map's polyline when streams is nil or has no latlng stream.
+func Activity(activity *models.Activity, streams *models.StreamSet) (*Feature, error) {
+	geometry, err := line(streams, activity.Map, "")
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]interface{}{
+		"kind":                 "activity",
+		"name":                 activity.Name,
+		"type":                 activity.Type,
+		"sport_type":           activity.SportType,
+		"distance":             activity.Distance,
+		"moving_time":          activity.MovingTime,
+		"elapsed_time":         activity.ElapsedTime,
+		"total_elevation_gain": activity.TotalElevationGain,
+		"average_speed":        activity.AverageSpeed,
+		"max_speed":            activity.MaxSpeed,
+	}
+	setTime(props, "start_date", activity.StartDate)
+	if activity.HasHeartrate {
+		props["average_heartrate"] = activity.AverageHeartrate
+		props["max_heartrate"] = activity.MaxHeartrate
+	}
+	if activity.AverageWatts > 0 {
+		props["average_watts"] = activity.AverageWatts
+	}
+
+	return &Feature{Type: "Feature", ID: activity.ID, Geometry: geometry, Properties: props}, nil
+}
+
+// Route returns a line string feature for a route followed by a point
+// feature for each of its waypoints. The line follows the route's streams
+// when given, or its map's polyline.
+func Route(route *models.Route, streams *models.StreamSet) ([]*Feature, error) {
+	geometry, err := line(streams, route.Map, "")
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]interface{}{
+		"kind":                  "route",
+		"name":                  route.Name,
+		"description":           route.Description,
+		"type":                  route.Type,
+		"sub_type":              route.SubType,
+		"distance":              route.Distance,
+		"elevation_gain":        route.ElevationGain,
+		"estimated_moving_time": route.EstimatedMovingTime,
+	}
+	setTime(props, "created_at", route.CreatedAt)
+	features := []*Feature{{Type: "Feature", ID: route.ID, Geometry: geometry, Properties: props}}
+
+	for _, wp := range route.Waypoints {
+		p, ok := models.LatLngFromSlice(wp.Latlng)
+		if !ok {
+			continue
+		}
+		features = append(features, &Feature{
+			Type:     "Feature",
+			Geometry: NewPoint(p),
+			Properties: map[string]interface{}{
+				"kind":                "waypoint",
+				"name":                wp.Title,
+				"description":         wp.Description,
+				"categories":          wp.Categories,
+				"distance_into_route": wp.DistanceIntoRoute,
+			},
+		})
+	}
+	return features, nil
+}
+
+// Segment returns a line string feature for a segment, following its map's
+// polyline or, for summary segments without one, its start and end points
+func Segment(segment *models.Segment) (*Feature, error) {
+	geometry, err := line(nil, segment.Map, "")
+	if err != nil {
+		geometry, err = straight(segment.StartLatlng, segment.EndLatlng)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]interface{}{
+		"kind":           "segment",
+		"name":           segment.Name,
+		"activity_type":  segment.ActivityType,
+		"distance":       segment.Distance,
+		"average_grade":  segment.AverageGrade,
+		"maximum_grade":  segment.MaximumGrade,
+		"elevation_high": segment.ElevationHigh,
+		"elevation_low":  segment.ElevationLow,
+		"climb_category": segment.ClimbCategory,
+		"city":           segment.City,
+		"state":          segment.State,
+		"country":        segment.Country,
+	}
+	if segment.TotalElevationGain > 0 {
+		props["total_elevation_gain"] = segment.TotalElevationGain
+	}
+
+	return &Feature{Type: "Feature", ID: segment.ID, Geometry: geometry, Properties: props}, nil
+}
+
+// ExploreSegment returns a line string feature for a segment found by
+// exploring, following its points or its start and end points
+func ExploreSegment(segment *models.ExploreSegment) (*Feature, error) {
+	geometry, err := line(nil, nil, segment.Points)
+	if err != nil {
+		geometry, err = straight(segment.StartLatlng, segment.EndLatlng)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]interface{}{
+		"kind":                "segment",
+		"name":                segment.Name,
+		"distance":            segment.Distance,
+		"avg_grade":           segment.AverageGrade,
+		"elev_difference":     segment.ElevationDifference,
+		"climb_category":      segment.ClimbCategory,
+		"climb_category_desc": segment.ClimbCategoryDesc,
+	}
+
+	return &Feature{Type: "Feature", ID: segment.ID, Geometry: geometry, Properties: props}, nil
+}
+
+// line returns a line string from the latlng stream, or else from the map's
+// full or summary polyline, or else from the encoded polyline
+func line(streams *models.StreamSet, m *models.Map, encoded string) (*Geometry, error) {
+	if streams != nil && streams.LatLng != nil {
+		var alt []float64
+		if streams.Altitude != nil && len(streams.Altitude.Data) == len(streams.LatLng.Data) {
+			alt = streams.Altitude.Data
+		}
+		var coords [][]float64
+		for i, c := range streams.LatLng.Data {
+			p, ok := models.LatLngFromSlice(c)
+			if !ok {
+				continue
+			}
+			if alt != nil {
+				coords = append(coords, []float64{p.Lng, p.Lat, alt[i]})
+			} else {
+				coords = append(coords, []float64{p.Lng, p.Lat})
+			}
+		}
+		if len(coords) >= 2 {
+			return &Geometry{Type: TypeLineString, Coordinates: coords}, nil
+		}
+	}
+
+	candidates := []string{encoded}
+	if m != nil {
+		candidates = []string{m.Polyline, m.SummaryPolyline}
+	}
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		points, err := polyline.Decode(c)
+		if err != nil {
+			return nil, err
+		}
+		if len(points) >= 2 {
+			return NewLineString(points), nil
+		}
+	}
+	return nil, ErrNoGeometry
+}
+
+// straight returns a line string from start to end
+func straight(start, end []float64) (*Geometry, error) {
+	a, okA := models.LatLngFromSlice(start)
+	b, okB := models.LatLngFromSlice(end)
+	if !okA || !okB {
+		return nil, ErrNoGeometry
+	}
+	return NewLineString([]models.LatLng{a, b}), nil
+}
+
+// setTime sets a property to a time unless it is zero
+func setTime(props map[string]interface{}, key string, t time.Time) {
+	if !t.IsZero() {
+		props[key] = t
+	}
+}
@@ -0,0 +1,135 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// ErrInvalidTime is returned for point times that are not RFC 3339
+var ErrInvalidTime = errors.New("gpx: invalid time")
+
+// document is the subset of a GPX file that Parse reads
+type document struct {
+	XMLName  xml.Name `xml:"gpx"`
+	Metadata struct {
+		Name string `xml:"name"`
+		Time string `xml:"time"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []point `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// point is a GPX track point with the extensions Write produces
+type point struct {
+	Lat        float64  `xml:"lat,attr"`
+	Lon        float64  `xml:"lon,attr"`
+	Ele        *float64 `xml:"ele"`
+	Time       string   `xml:"time"`
+	Extensions struct {
+		Power *int `xml:"power"`
+		TPX   struct {
+			ATemp *float64 `xml:"atemp"`
+			HR    *int     `xml:"hr"`
+			Cad   *int     `xml:"cad"`
+		} `xml:"TrackPointExtension"`
+	} `xml:"extensions"`
+}
+
+// Parse reads a GPX file into an activity summary and streams, the reverse
+// of Write. All tracks and segments are joined into one set of streams. The
+// time stream is only set when every point has a time; other missing values
+// are zero. Distance is computed from the positions.
+func Parse(r io.Reader) (*models.Activity, *models.StreamSet, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("gpx: %w", err)
+	}
+
+	activity := &models.Activity{Name: doc.Metadata.Name}
+	var points []track.Point
+	for _, trk := range doc.Tracks {
+		if activity.Name == "" {
+			activity.Name = trk.Name
+		}
+		if activity.Type == "" {
+			activity.Type = activityType(trk.Type)
+		}
+		for _, seg := range trk.Segments {
+			for i := range seg.Points {
+				p, err := seg.Points[i].trackPoint()
+				if err != nil {
+					return nil, nil, err
+				}
+				points = append(points, p)
+			}
+		}
+	}
+
+	streams, start := track.Streams(points)
+	if streams.Time != nil {
+		activity.StartDate = time.Unix(start, 0).UTC()
+	} else if t, err := time.Parse(time.RFC3339, strings.TrimSpace(doc.Metadata.Time)); err == nil {
+		activity.StartDate = t.UTC()
+	}
+	track.Summarize(activity, streams)
+
+	return activity, streams, nil
+}
+
+// trackPoint converts a GPX point
+func (p *point) trackPoint() (track.Point, error) {
+	tp := track.Point{
+		Lat:       &p.Lat,
+		Lng:       &p.Lon,
+		Altitude:  p.Ele,
+		Heartrate: p.Extensions.TPX.HR,
+		Cadence:   p.Extensions.TPX.Cad,
+		Watts:     p.Extensions.Power,
+	}
+	if p.Extensions.TPX.ATemp != nil {
+		temp := int(*p.Extensions.TPX.ATemp)
+		tp.Temperature = &temp
+	}
+	if s := strings.TrimSpace(p.Time); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return tp, fmt.Errorf("%w %q", ErrInvalidTime, s)
+		}
+		unix := t.Unix()
+		tp.Time = &unix
+	}
+	return tp, nil
+}
+
+// activityType maps a GPX track type to an activity type. Common lower case
+// sport names are mapped and anything else, such as the activity type names
+// Write produces, is used as is.
+func activityType(s string) models.ActivityType {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return ""
+	case "cycling", "biking":
+		return models.ActivityTypeRide
+	case "running":
+		return models.ActivityTypeRun
+	case "walking":
+		return models.ActivityTypeWalk
+	case "hiking":
+		return models.ActivityTypeHike
+	case "swimming":
+		return models.ActivityTypeSwim
+	}
+	return models.ActivityType(strings.TrimSpace(s))
+}
@@ -0,0 +1,176 @@
+package gpx
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/internal/xmlw"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+const (
+	// Namespace is the GPX 1.1 namespace
+	Namespace = "http://www.topografix.com/GPX/1/1"
+
+	// TrackPointExtensionNamespace is the Garmin TrackPointExtension v1 namespace
+	TrackPointExtensionNamespace = "http://www.garmin.com/xmlschemas/TrackPointExtension/v1"
+
+	schemaLocation = Namespace + " http://www.topografix.com/GPX/1/1/gpx.xsd " +
+		TrackPointExtensionNamespace + " http://www.garmin.com/xmlschemas/TrackPointExtensionv1.xsd"
+)
+
+var (
+	// ErrNoTrack is returned when the streams have no latlng data
+	ErrNoTrack = errors.New("gpx: no latlng stream")
+
+	// ErrLengthMismatch is returned when a stream is not aligned with the latlng stream
+	ErrLengthMismatch = track.ErrLengthMismatch
+
+	// ErrInvalidCoordinate is returned for malformed or out of range coordinates
+	ErrInvalidCoordinate = track.ErrInvalidCoordinate
+
+	// ErrTimeOrder is returned when the time stream goes backwards
+	ErrTimeOrder = track.ErrTimeOrder
+
+	// ErrMissingStartDate is returned when a time stream has no start date to offset from
+	ErrMissingStartDate = errors.New("gpx: activity has no start date")
+)
+
+// WriteOptions contains options for writing GPX
+type WriteOptions struct {
+	// Creator is written to the creator attribute (default: go-strava-api)
+	Creator string
+
+	// NoExtensions omits the heart rate, cadence, temperature and power extensions
+	NoExtensions bool
+}
+
+// Validate checks that the streams can be written as a GPX track: a latlng
+// stream with valid coordinates, every other stream aligned with it, and a
+// non-decreasing time stream with a start date to offset from
+func Validate(activity *models.Activity, streams *models.StreamSet) error {
+	if streams == nil || streams.LatLng == nil || len(streams.LatLng.Data) == 0 {
+		return ErrNoTrack
+	}
+
+	if err := track.CheckCoordinates(streams.LatLng.Data); err != nil {
+		return err
+	}
+	if err := track.CheckLengths(streams, len(streams.LatLng.Data)); err != nil {
+		return err
+	}
+
+	if streams.Time != nil {
+		if activity == nil || activity.StartDate.IsZero() {
+			return ErrMissingStartDate
+		}
+		if err := track.CheckTimes(streams.Time.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write validates the streams and writes them to w as a GPX 1.1 track, one
+// point at a time. Times are the activity start date plus the time stream;
+// heart rate, cadence and temperature use the Garmin TrackPointExtension and
+// power is written as a power extension element, as Strava's own exports do.
+// The activity may be nil when the streams have no time stream.
+func Write(w io.Writer, activity *models.Activity, streams *models.StreamSet, opts *WriteOptions) error {
+	if err := Validate(activity, streams); err != nil {
+		return err
+	}
+
+	o := WriteOptions{Creator: "go-strava-api"}
+	if opts != nil {
+		if opts.Creator != "" {
+			o.Creator = opts.Creator
+		}
+		o.NoExtensions = opts.NoExtensions
+	}
+
+	var start time.Time
+	var name string
+	var activityType models.ActivityType
+	if activity != nil {
+		start = activity.StartDate.UTC()
+		name = activity.Name
+		activityType = activity.Type
+	}
+
+	ew := xmlw.NewWriter(w)
+
+	ew.Printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	ew.Printf("<gpx version=\"1.1\" creator=\"%s\" xmlns=\"%s\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:schemaLocation=\"%s\" xmlns:gpxtpx=\"%s\">\n",
+		xmlw.Escape(o.Creator), Namespace, schemaLocation, TrackPointExtensionNamespace)
+
+	ew.Printf(" <metadata>\n")
+	if name != "" {
+		ew.Printf("  <name>%s</name>\n", xmlw.Escape(name))
+	}
+	if !start.IsZero() {
+		ew.Printf("  <time>%s</time>\n", start.Format(time.RFC3339))
+	}
+	ew.Printf(" </metadata>\n")
+
+	ew.Printf(" <trk>\n")
+	if name != "" {
+		ew.Printf("  <name>%s</name>\n", xmlw.Escape(name))
+	}
+	if activityType != "" {
+		ew.Printf("  <type>%s</type>\n", xmlw.Escape(string(activityType)))
+	}
+	ew.Printf("  <trkseg>\n")
+
+	for i, c := range streams.LatLng.Data {
+		ew.Printf("   <trkpt lat=\"%s\" lon=\"%s\">\n", xmlw.Float(c[0]), xmlw.Float(c[1]))
+		if streams.Altitude != nil {
+			ew.Printf("    <ele>%s</ele>\n", xmlw.Float(streams.Altitude.Data[i]))
+		}
+		if streams.Time != nil {
+			t := start.Add(time.Duration(streams.Time.Data[i]) * time.Second)
+			ew.Printf("    <time>%s</time>\n", t.Format(time.RFC3339))
+		}
+		if !o.NoExtensions {
+			writeExtensions(ew, streams, i)
+		}
+		ew.Printf("   </trkpt>\n")
+	}
+
+	ew.Printf("  </trkseg>\n")
+	ew.Printf(" </trk>\n")
+	ew.Printf("</gpx>\n")
+
+	return ew.Flush()
+}
+
+// writeExtensions writes the extension elements of a track point, if it has any
+func writeExtensions(ew *xmlw.Writer, streams *models.StreamSet, i int) {
+	hasTPX := streams.Heartrate != nil || streams.Cadence != nil || streams.Temperature != nil
+	if !hasTPX && streams.Watts == nil {
+		return
+	}
+
+	ew.Printf("    <extensions>\n")
+	if streams.Watts != nil {
+		ew.Printf("     <power>%d</power>\n", streams.Watts.Data[i])
+	}
+	if hasTPX {
+		ew.Printf("     <gpxtpx:TrackPointExtension>\n")
+		// The schema requires atemp, hr, cad in this order
+		if streams.Temperature != nil {
+			ew.Printf("      <gpxtpx:atemp>%d</gpxtpx:atemp>\n", streams.Temperature.Data[i])
+		}
+		if streams.Heartrate != nil {
+			ew.Printf("      <gpxtpx:hr>%d</gpxtpx:hr>\n", streams.Heartrate.Data[i])
+		}
+		if streams.Cadence != nil {
+			ew.Printf("      <gpxtpx:cad>%d</gpxtpx:cad>\n", streams.Cadence.Data[i])
+		}
+		ew.Printf("     </gpxtpx:TrackPointExtension>\n")
+	}
+	ew.Printf("    </extensions>\n")
+}
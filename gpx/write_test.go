@@ -0,0 +1,113 @@
+package gpx
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// sample returns a short ride with every stream GPX can hold
+func sample() (*models.Activity, *models.StreamSet) {
+	activity := &models.Activity{
+		Name:      "Morning <Ride> & coffee",
+		Type:      models.ActivityTypeRide,
+		StartDate: time.Date(2024, 5, 4, 7, 30, 0, 0, time.UTC),
+	}
+	streams := &models.StreamSet{
+		Time:        &models.TimeStream{Data: []int{0, 1, 2, 4}},
+		LatLng:      &models.LatLngStream{Data: [][]float64{{51.5, -0.1}, {51.5001, -0.1001}, {51.5002, -0.1002}, {51.5004, -0.1004}}},
+		Altitude:    &models.AltitudeStream{Data: []float64{12.2, 12.4, 13, 12.8}},
+		Heartrate:   &models.HeartrateStream{Data: []int{110, 115, 121, 130}},
+		Cadence:     &models.CadenceStream{Data: []int{80, 85, 90, 88}},
+		Watts:       &models.PowerStream{Data: []int{150, 220, 310, 280}},
+		Temperature: &models.TemperatureStream{Data: []int{18, 18, 19, 19}},
+	}
+	return activity, streams
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	activity, streams := sample()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, activity, streams, nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, gotStreams, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if got.Name != activity.Name || got.Type != activity.Type || !got.StartDate.Equal(activity.StartDate) {
+		t.Errorf("activity = %q %q %v, want %q %q %v", got.Name, got.Type, got.StartDate, activity.Name, activity.Type, activity.StartDate)
+	}
+	if !slices.EqualFunc(gotStreams.LatLng.Data, streams.LatLng.Data, slices.Equal) {
+		t.Errorf("latlng = %v, want %v", gotStreams.LatLng.Data, streams.LatLng.Data)
+	}
+	if !slices.Equal(gotStreams.Altitude.Data, streams.Altitude.Data) {
+		t.Errorf("altitude = %v, want %v", gotStreams.Altitude.Data, streams.Altitude.Data)
+	}
+	for name, ints := range map[string][2][]int{
+		"time":      {streams.Time.Data, gotStreams.Time.Data},
+		"heartrate": {streams.Heartrate.Data, gotStreams.Heartrate.Data},
+		"cadence":   {streams.Cadence.Data, gotStreams.Cadence.Data},
+		"watts":     {streams.Watts.Data, gotStreams.Watts.Data},
+		"temp":      {streams.Temperature.Data, gotStreams.Temperature.Data},
+	} {
+		if !slices.Equal(ints[0], ints[1]) {
+			t.Errorf("%s = %v, want %v", name, ints[1], ints[0])
+		}
+	}
+	if d := gotStreams.Distance.Data; len(d) != 4 || math.Abs(d[3]-53) > 1 {
+		t.Errorf("distance = %v, want about 53 m over 4 points", d)
+	}
+}
+
+func TestWriteNoExtensions(t *testing.T) {
+	activity, streams := sample()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, activity, streams, &WriteOptions{NoExtensions: true}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_, gotStreams, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if gotStreams.Heartrate != nil || gotStreams.Watts != nil || gotStreams.Cadence != nil || gotStreams.Temperature != nil {
+		t.Error("extension streams were written")
+	}
+	if gotStreams.Time == nil || gotStreams.Altitude == nil {
+		t.Error("time or altitude was not written")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *models.Activity, s *models.StreamSet)
+		want   error
+	}{
+		{"valid", func(a *models.Activity, s *models.StreamSet) {}, nil},
+		{"no track", func(a *models.Activity, s *models.StreamSet) { s.LatLng = nil }, ErrNoTrack},
+		{"short heartrate", func(a *models.Activity, s *models.StreamSet) { s.Heartrate.Data = s.Heartrate.Data[:3] }, ErrLengthMismatch},
+		{"invalid coordinate", func(a *models.Activity, s *models.StreamSet) { s.LatLng.Data[1] = []float64{91, 0} }, ErrInvalidCoordinate},
+		{"NaN latitude", func(a *models.Activity, s *models.StreamSet) { s.LatLng.Data[1] = []float64{math.NaN(), 0} }, ErrInvalidCoordinate},
+		{"NaN longitude", func(a *models.Activity, s *models.StreamSet) { s.LatLng.Data[1] = []float64{51.5, math.NaN()} }, ErrInvalidCoordinate},
+		{"time goes backwards", func(a *models.Activity, s *models.StreamSet) { s.Time.Data[2] = 0 }, ErrTimeOrder},
+		{"no start date", func(a *models.Activity, s *models.StreamSet) { a.StartDate = time.Time{} }, ErrMissingStartDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activity, streams := sample()
+			tt.modify(activity, streams)
+			if err := Validate(activity, streams); !errors.Is(err, tt.want) {
+				t.Errorf("Validate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
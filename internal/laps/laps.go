@@ -0,0 +1,157 @@
+package laps
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Lap is a span of stream points and its summary values
+type Lap struct {
+	// First and Last are the indices of the lap's own points
+	First int
+	Last  int
+
+	ElapsedTime  float64 // seconds
+	TimerTime    float64 // seconds
+	Distance     float64 // meters
+	Ascent       float64 // meters
+	Calories     float64
+	AvgSpeed     float64 // m/s
+	MaxSpeed     float64 // m/s
+	AvgHeartrate float64
+	MaxHeartrate int
+	AvgCadence   float64
+	AvgWatts     float64
+	MaxWatts     int
+}
+
+// Split divides the streams into the activity's laps, each running from its
+// start index to the next lap's, with points before the first lap's start
+// index added to the first lap. Summary values are Strava's where reported
+// and are otherwise computed from the streams. When whole is set or the
+// activity has no laps, the whole activity is a single lap. The streams must
+// have a time stream that every other stream is aligned with, and lap start
+// indices must be increasing and within the streams.
+func Split(activity *models.Activity, streams *models.StreamSet, whole bool) []Lap {
+	n := len(streams.Time.Data)
+
+	reported := activity.Laps
+	if whole || len(reported) == 0 {
+		reported = []models.Lap{{
+			ElapsedTime:        activity.ElapsedTime,
+			MovingTime:         activity.MovingTime,
+			Distance:           activity.Distance,
+			TotalElevationGain: activity.TotalElevationGain,
+			AverageSpeed:       activity.AverageSpeed,
+			MaxSpeed:           activity.MaxSpeed,
+			AverageHeartrate:   activity.AverageHeartrate,
+			MaxHeartrate:       activity.MaxHeartrate,
+			AverageCadence:     activity.AverageCadence,
+			AverageWatts:       activity.AverageWatts,
+		}}
+	}
+
+	laps := make([]Lap, len(reported))
+	for k, r := range reported {
+		first, next, last := r.StartIndex, n-1, n-1
+		if k == 0 {
+			first = 0
+		}
+		if k+1 < len(reported) {
+			next = reported[k+1].StartIndex
+			last = next - 1
+		}
+
+		l := compute(streams, first, next, last)
+		l.override(r)
+		if activity.Calories > 0 && activity.ElapsedTime > 0 {
+			l.Calories = activity.Calories * l.ElapsedTime / activity.ElapsedTime
+		}
+		laps[k] = l
+	}
+	return laps
+}
+
+// compute computes a lap from the streams. Time, distance and ascent run
+// from first to next, the first point of the following lap; averages and
+// maxima cover the lap's own points from first to last.
+func compute(streams *models.StreamSet, first, next, last int) Lap {
+	l := Lap{First: first, Last: last}
+	l.ElapsedTime = float64(streams.Time.Data[next] - streams.Time.Data[first])
+	l.TimerTime = l.ElapsedTime
+	if streams.Distance != nil {
+		l.Distance = streams.Distance.Data[next] - streams.Distance.Data[first]
+	}
+	if l.ElapsedTime > 0 {
+		l.AvgSpeed = l.Distance / l.ElapsedTime
+	}
+	if streams.Altitude != nil {
+		for i := first + 1; i <= next; i++ {
+			if d := streams.Altitude.Data[i] - streams.Altitude.Data[i-1]; d > 0 {
+				l.Ascent += d
+			}
+		}
+	}
+
+	count := float64(last - first + 1)
+	var hr, cad, watts float64
+	for i := first; i <= last; i++ {
+		if streams.VelocitySmooth != nil {
+			l.MaxSpeed = math.Max(l.MaxSpeed, streams.VelocitySmooth.Data[i])
+		}
+		if streams.Heartrate != nil {
+			hr += float64(streams.Heartrate.Data[i])
+			if streams.Heartrate.Data[i] > l.MaxHeartrate {
+				l.MaxHeartrate = streams.Heartrate.Data[i]
+			}
+		}
+		if streams.Cadence != nil {
+			cad += float64(streams.Cadence.Data[i])
+		}
+		if streams.Watts != nil {
+			watts += float64(streams.Watts.Data[i])
+			if streams.Watts.Data[i] > l.MaxWatts {
+				l.MaxWatts = streams.Watts.Data[i]
+			}
+		}
+	}
+	l.AvgHeartrate = hr / count
+	l.AvgCadence = cad / count
+	l.AvgWatts = watts / count
+	return l
+}
+
+// override replaces computed values with the ones Strava reports
+func (l *Lap) override(r models.Lap) {
+	if r.ElapsedTime > 0 {
+		l.ElapsedTime = r.ElapsedTime
+	}
+	if r.MovingTime > 0 {
+		l.TimerTime = r.MovingTime
+	}
+	if r.Distance > 0 {
+		l.Distance = r.Distance
+	}
+	if r.TotalElevationGain > 0 {
+		l.Ascent = r.TotalElevationGain
+	}
+	if r.AverageSpeed > 0 {
+		l.AvgSpeed = r.AverageSpeed
+	}
+	if r.MaxSpeed > 0 {
+		l.MaxSpeed = r.MaxSpeed
+	}
+	if r.AverageHeartrate > 0 {
+		l.AvgHeartrate = r.AverageHeartrate
+	}
+	if r.MaxHeartrate > 0 {
+		l.MaxHeartrate = r.MaxHeartrate
+	}
+	if r.AverageCadence > 0 {
+		l.AvgCadence = r.AverageCadence
+	}
+	if r.AverageWatts > 0 {
+		l.AvgWatts = r.AverageWatts
+	}
+}
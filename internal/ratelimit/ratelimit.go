@@ -8,19 +8,42 @@ import (
 	"github.com/kpi-studio/go-strava-api/internal"
 )
 
-// RateLimitInfo contains rate limit information from response headers
+// shortWindow is the length of Strava's short-term rate limit window. Both
+// windows reset at fixed times: every quarter hour and at midnight UTC.
+const shortWindow = 15 * time.Minute
+
+// RateLimitInfo contains rate limit information from response headers.
+// Strava limits requests per 15 minutes and per day.
 type RateLimitInfo struct {
 	Limit int
 	Usage int
 	Reset time.Time
+
+	DailyLimit int
+	DailyUsage int
+	DailyReset time.Time
+}
+
+// Resets returns when the 15 minute and daily windows current at now end
+func Resets(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	short := now.Truncate(shortWindow).Add(shortWindow)
+	daily := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return short, daily
 }
 
-// RateLimiter manages API rate limiting
+// RateLimiter manages API rate limiting across the 15 minute and daily
+// windows. Requests are counted as they are made and the counts are
+// corrected from each response's headers.
 type RateLimiter struct {
 	mu         sync.Mutex
 	limit      int
 	usage      int
 	reset      time.Time
+	dailyLimit int
+	dailyUsage int
+	dailyReset time.Time
+	next       time.Time // earliest start of the next request
 	minDelay   time.Duration
 	maxRetries int
 	enabled    bool
@@ -61,36 +84,53 @@ func NewRateLimiter(config *RateLimiterConfig) *RateLimiter {
 	return rl
 }
 
-// Wait blocks until it's safe to make another request
+// Wait blocks until it's safe to make another request, waiting for the
+// daily or 15 minute window to reset when its limit is used up. The lock is
+// only held to check the windows, so waiting callers do not block Update.
 func (rl *RateLimiter) Wait(ctx context.Context) error {
 	if !rl.enabled {
 		return nil
 	}
 
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	for {
+		rl.mu.Lock()
+		d, ok := rl.reserve(time.Now())
+		rl.mu.Unlock()
 
-	// Check if we need to wait for rate limit reset
-	if rl.limit > 0 && rl.usage >= rl.limit {
-		waitTime := time.Until(rl.reset)
-		if waitTime > 0 {
-			timer := time.NewTimer(waitTime)
-			defer timer.Stop()
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-timer.C:
-				// Reset completed, continue
-				rl.usage = 0
-			}
+		if err := sleep(ctx, d); err != nil || ok {
+			return err
 		}
 	}
+}
+
+// reserve counts a request and returns how long to wait before making it,
+// or returns how long to wait for a window to reset and false when its
+// limit is used up. rl.mu must be held.
+func (rl *RateLimiter) reserve(now time.Time) (time.Duration, bool) {
+	// Wait out the daily window first, as it also resets the 15 minute one
+	if rl.dailyLimit > 0 && rl.dailyUsage >= rl.dailyLimit && now.Before(rl.dailyReset) {
+		return rl.dailyReset.Sub(now), false
+	}
+	if !rl.dailyReset.IsZero() && !now.Before(rl.dailyReset) {
+		rl.dailyUsage, rl.usage = 0, 0
+	}
+	if rl.limit > 0 && rl.usage >= rl.limit && now.Before(rl.reset) {
+		return rl.reset.Sub(now), false
+	}
+	if !now.Before(rl.reset) {
+		rl.usage = 0
+	}
 
 	// Apply minimum delay between requests
-	time.Sleep(rl.minDelay)
+	start := now
+	if rl.next.After(start) {
+		start = rl.next
+	}
+	rl.next = start.Add(rl.minDelay)
 
-	return nil
+	rl.usage++
+	rl.dailyUsage++
+	return start.Sub(now), true
 }
 
 // Update updates rate limit information from response headers
@@ -111,6 +151,28 @@ func (rl *RateLimiter) Update(info RateLimitInfo) {
 	if !info.Reset.IsZero() {
 		rl.reset = info.Reset
 	}
+	if info.DailyLimit > 0 {
+		rl.dailyLimit = info.DailyLimit
+	}
+	if info.DailyUsage > 0 {
+		rl.dailyUsage = info.DailyUsage
+	}
+	if !info.DailyReset.IsZero() {
+		rl.dailyReset = info.DailyReset
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // RetryWithBackoff retries a function with exponential backoff
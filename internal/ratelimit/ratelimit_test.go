@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitReleasesLock(t *testing.T) {
+	rl := NewRateLimiter(&RateLimiterConfig{Enabled: true, MinDelay: time.Millisecond})
+	rl.Update(RateLimitInfo{Limit: 1, Usage: 1, Reset: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- rl.Wait(ctx) }()
+
+	// Update must not block behind the waiting caller
+	updated := make(chan struct{})
+	go func() {
+		rl.Update(RateLimitInfo{DailyLimit: 1000})
+		close(updated)
+	}()
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("Update blocked while Wait was sleeping")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait ignored the cancelled context")
+	}
+}
+
+func TestWaitMinDelay(t *testing.T) {
+	rl := NewRateLimiter(&RateLimiterConfig{Enabled: true, MinDelay: time.Hour})
+
+	if err := rl.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second Wait = %v, want context.DeadlineExceeded", err)
+	}
+}
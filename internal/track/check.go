@@ -0,0 +1,85 @@
+package track
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+var (
+	// ErrLengthMismatch is returned when streams are not aligned with each other
+	ErrLengthMismatch = errors.New("track: stream lengths do not match")
+
+	// ErrInvalidCoordinate is returned for malformed or out of range coordinates
+	ErrInvalidCoordinate = errors.New("track: invalid coordinate")
+
+	// ErrTimeOrder is returned when the time stream goes backwards
+	ErrTimeOrder = errors.New("track: time stream is not increasing")
+)
+
+// streamLength is the length of a named stream
+type streamLength struct {
+	name   string
+	length int
+}
+
+// CheckLengths checks that every stream present has n points
+func CheckLengths(streams *models.StreamSet, n int) error {
+	var lengths []streamLength
+	if streams.Time != nil {
+		lengths = append(lengths, streamLength{"time", len(streams.Time.Data)})
+	}
+	if streams.LatLng != nil {
+		lengths = append(lengths, streamLength{"latlng", len(streams.LatLng.Data)})
+	}
+	if streams.Distance != nil {
+		lengths = append(lengths, streamLength{"distance", len(streams.Distance.Data)})
+	}
+	if streams.Altitude != nil {
+		lengths = append(lengths, streamLength{"altitude", len(streams.Altitude.Data)})
+	}
+	if streams.VelocitySmooth != nil {
+		lengths = append(lengths, streamLength{"velocity_smooth", len(streams.VelocitySmooth.Data)})
+	}
+	if streams.Heartrate != nil {
+		lengths = append(lengths, streamLength{"heartrate", len(streams.Heartrate.Data)})
+	}
+	if streams.Cadence != nil {
+		lengths = append(lengths, streamLength{"cadence", len(streams.Cadence.Data)})
+	}
+	if streams.Watts != nil {
+		lengths = append(lengths, streamLength{"watts", len(streams.Watts.Data)})
+	}
+	if streams.Temperature != nil {
+		lengths = append(lengths, streamLength{"temp", len(streams.Temperature.Data)})
+	}
+	for _, l := range lengths {
+		if l.length != n {
+			return fmt.Errorf("%w: %s has %d points, expected %d", ErrLengthMismatch, l.name, l.length, n)
+		}
+	}
+	return nil
+}
+
+// CheckCoordinates checks that every coordinate is a valid [lat, lng] pair
+func CheckCoordinates(coords [][]float64) error {
+	for i, c := range coords {
+		if len(c) != 2 || math.IsNaN(c[0]) || math.IsNaN(c[1]) ||
+			c[0] < -90 || c[0] > 90 || c[1] < -180 || c[1] > 180 {
+			return fmt.Errorf("%w %v at index %d", ErrInvalidCoordinate, c, i)
+		}
+	}
+	return nil
+}
+
+// CheckTimes checks that the time stream never goes backwards
+func CheckTimes(ts []int) error {
+	for i := 1; i < len(ts); i++ {
+		if ts[i] < ts[i-1] {
+			return fmt.Errorf("%w at index %d", ErrTimeOrder, i)
+		}
+	}
+	return nil
+}
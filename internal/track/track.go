@@ -0,0 +1,198 @@
+package track
+
+import (
+	"math"
+
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Point is a sample read from an activity file. Pointer fields are nil when
+// the file has no value for the sample.
+type Point struct {
+	Lat, Lng    *float64
+	Time        *int64 // unix seconds
+	Altitude    *float64
+	Distance    *float64
+	Speed       *float64
+	Heartrate   *int
+	Cadence     *int
+	Watts       *int
+	Temperature *int
+}
+
+// Streams builds a stream set from parsed points. A stream is included when
+// any point has a value for it. Missing positions are filled from the nearest
+// earlier point, or the first later one at the start, and other missing
+// values are zero, except that the time stream is only included when every
+// point has a time. Times are seconds from the first point's time, which is
+// returned as the start time. Distance is integrated from the positions when
+// the file has none.
+func Streams(points []Point) (*models.StreamSet, int64) {
+	streams := &models.StreamSet{}
+	n := len(points)
+	if n == 0 {
+		return streams, 0
+	}
+
+	var hasPos, hasAlt, hasDist, hasSpeed, hasHR, hasCad, hasWatts, hasTemp bool
+	allTimes := true
+	for _, p := range points {
+		hasPos = hasPos || (p.Lat != nil && p.Lng != nil)
+		hasAlt = hasAlt || p.Altitude != nil
+		hasDist = hasDist || p.Distance != nil
+		hasSpeed = hasSpeed || p.Speed != nil
+		hasHR = hasHR || p.Heartrate != nil
+		hasCad = hasCad || p.Cadence != nil
+		hasWatts = hasWatts || p.Watts != nil
+		hasTemp = hasTemp || p.Temperature != nil
+		allTimes = allTimes && p.Time != nil
+	}
+
+	var start int64
+	if allTimes {
+		start = *points[0].Time
+		streams.Time = &models.TimeStream{Data: make([]int, n)}
+		for i, p := range points {
+			streams.Time.Data[i] = int(*p.Time - start)
+		}
+	}
+
+	if hasPos {
+		data := make([][]float64, n)
+		var last []float64
+		for i, p := range points {
+			if p.Lat != nil && p.Lng != nil {
+				last = []float64{*p.Lat, *p.Lng}
+			}
+			data[i] = last
+		}
+		for i := n - 1; i >= 0; i-- {
+			if data[i] == nil {
+				data[i] = data[i+1]
+			}
+		}
+		streams.LatLng = &models.LatLngStream{Data: data}
+	}
+
+	if hasAlt {
+		streams.Altitude = &models.AltitudeStream{Data: floats(points, func(p Point) *float64 { return p.Altitude })}
+	}
+	if hasDist {
+		streams.Distance = &models.DistanceStream{Data: floats(points, func(p Point) *float64 { return p.Distance })}
+	} else if hasPos {
+		streams.Distance = &models.DistanceStream{Data: distances(streams.LatLng.Data)}
+	}
+	if hasSpeed {
+		streams.VelocitySmooth = &models.VelocityStream{Data: floats(points, func(p Point) *float64 { return p.Speed })}
+	}
+	if hasHR {
+		streams.Heartrate = &models.HeartrateStream{Data: ints(points, func(p Point) *int { return p.Heartrate })}
+	}
+	if hasCad {
+		streams.Cadence = &models.CadenceStream{Data: ints(points, func(p Point) *int { return p.Cadence })}
+	}
+	if hasWatts {
+		streams.Watts = &models.PowerStream{Data: ints(points, func(p Point) *int { return p.Watts })}
+	}
+	if hasTemp {
+		streams.Temperature = &models.TemperatureStream{Data: ints(points, func(p Point) *int { return p.Temperature })}
+	}
+
+	return streams, start
+}
+
+// Summarize fills the activity's totals and averages from the streams
+func Summarize(activity *models.Activity, streams *models.StreamSet) {
+	if streams.Time != nil && len(streams.Time.Data) > 0 {
+		ts := streams.Time.Data
+		activity.ElapsedTime = float64(ts[len(ts)-1] - ts[0])
+		activity.MovingTime = activity.ElapsedTime
+	}
+	if streams.Distance != nil && len(streams.Distance.Data) > 0 {
+		d := streams.Distance.Data
+		activity.Distance = d[len(d)-1] - d[0]
+	}
+	if activity.ElapsedTime > 0 {
+		activity.AverageSpeed = activity.Distance / activity.ElapsedTime
+	}
+	if streams.VelocitySmooth != nil {
+		for _, v := range streams.VelocitySmooth.Data {
+			activity.MaxSpeed = math.Max(activity.MaxSpeed, v)
+		}
+	}
+	if streams.Altitude != nil {
+		alt := streams.Altitude.Data
+		for i := 1; i < len(alt); i++ {
+			if d := alt[i] - alt[i-1]; d > 0 {
+				activity.TotalElevationGain += d
+			}
+		}
+	}
+	if streams.LatLng != nil && len(streams.LatLng.Data) > 0 {
+		activity.StartLatlng = streams.LatLng.Data[0]
+		activity.EndLatlng = streams.LatLng.Data[len(streams.LatLng.Data)-1]
+	}
+	if streams.Heartrate != nil {
+		activity.HasHeartrate = true
+		activity.AverageHeartrate, activity.MaxHeartrate = averageMax(streams.Heartrate.Data)
+	}
+	if streams.Cadence != nil {
+		activity.AverageCadence, _ = averageMax(streams.Cadence.Data)
+	}
+	if streams.Watts != nil {
+		activity.DeviceWatts = true
+		activity.AverageWatts, activity.MaxWatts = averageMax(streams.Watts.Data)
+	}
+	if streams.Temperature != nil {
+		avg, _ := averageMax(streams.Temperature.Data)
+		activity.AverageTemp = int(math.Round(avg))
+	}
+}
+
+// distances integrates a latlng stream into cumulative distances
+func distances(latlng [][]float64) []float64 {
+	d := make([]float64, len(latlng))
+	for i := 1; i < len(latlng); i++ {
+		a, b := latlng[i-1], latlng[i]
+		d[i] = d[i-1] + utils.CalculateDistance(a[0], a[1], b[0], b[1])
+	}
+	return d
+}
+
+// floats returns a float stream, with zero for missing values
+func floats(points []Point, value func(Point) *float64) []float64 {
+	out := make([]float64, len(points))
+	for i, p := range points {
+		if v := value(p); v != nil {
+			out[i] = *v
+		}
+	}
+	return out
+}
+
+// ints returns an integer stream, with zero for missing values
+func ints(points []Point, value func(Point) *int) []int {
+	out := make([]int, len(points))
+	for i, p := range points {
+		if v := value(p); v != nil {
+			out[i] = *v
+		}
+	}
+	return out
+}
+
+// averageMax returns the average and maximum of the values
+func averageMax(values []int) (float64, int) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum, max int
+	for i, v := range values {
+		sum += v
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+	return float64(sum) / float64(len(values)), max
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/kpi-studio/go-strava-api/models"
+	"github.com/kpi-studio/go-strava-api/polyline"
 )
 
 // Distance conversion helpers
@@ -32,6 +33,11 @@ func KilometersToMeters(km float64) float64 {
 	return km * 1000
 }
 
+// MetersToFeet converts meters to feet
+func MetersToFeet(meters float64) float64 {
+	return meters * 3.28084
+}
+
 // Speed conversion helpers
 
 // MetersPerSecondToMilesPerHour converts m/s to mph
@@ -114,100 +120,19 @@ func PoundsToKilograms(lbs float64) float64 {
 
 // Polyline decoding
 
-// DecodePolyline decodes a Google polyline string to coordinates
-func DecodePolyline(encoded string) [][]float64 {
-	var coordinates [][]float64
-	index := 0
-	lat := 0
-	lng := 0
-
-	for index < len(encoded) {
-		var b, shift, result int
-
-		// Decode latitude
-		shift = 0
-		result = 0
-		for {
-			b = int(encoded[index]) - 63
-			index++
-			result |= (b & 0x1f) << shift
-			shift += 5
-			if b < 0x20 {
-				break
-			}
-		}
-		dlat := result
-		if (result & 1) != 0 {
-			dlat = ^(result >> 1)
-		} else {
-			dlat = result >> 1
-		}
-		lat += dlat
-
-		// Decode longitude
-		shift = 0
-		result = 0
-		for {
-			b = int(encoded[index]) - 63
-			index++
-			result |= (b & 0x1f) << shift
-			shift += 5
-			if b < 0x20 {
-				break
-			}
-		}
-		dlng := result
-		if (result & 1) != 0 {
-			dlng = ^(result >> 1)
-		} else {
-			dlng = result >> 1
-		}
-		lng += dlng
-
-		coordinates = append(coordinates, []float64{
-			float64(lat) / 1e5,
-			float64(lng) / 1e5,
-		})
+// DecodePolyline decodes a Google polyline string to coordinates. Truncated or
+// corrupted input returns an error instead of panicking.
+func DecodePolyline(encoded string) ([][]float64, error) {
+	points, err := polyline.Decode(encoded)
+	if err != nil {
+		return nil, err
 	}
-
-	return coordinates
+	return polyline.ToSlices(points), nil
 }
 
 // EncodePolyline encodes coordinates to a Google polyline string
 func EncodePolyline(coordinates [][]float64) string {
-	var encoded strings.Builder
-	prevLat := 0
-	prevLng := 0
-
-	for _, coord := range coordinates {
-		lat := int(math.Round(coord[0] * 1e5))
-		lng := int(math.Round(coord[1] * 1e5))
-
-		dlat := lat - prevLat
-		dlng := lng - prevLng
-
-		prevLat = lat
-		prevLng = lng
-
-		encodeValue(&encoded, dlat)
-		encodeValue(&encoded, dlng)
-	}
-
-	return encoded.String()
-}
-
-func encodeValue(encoded *strings.Builder, value int) {
-	if value < 0 {
-		value = ^(value << 1)
-	} else {
-		value = value << 1
-	}
-
-	for value >= 0x20 {
-		encoded.WriteByte(byte((0x20 | (value & 0x1f)) + 63))
-		value >>= 5
-	}
-	encoded.WriteByte(byte(value + 63))
+	return polyline.Encode(polyline.FromSlices(coordinates))
 }
 
 // Distance calculation
@@ -342,14 +267,15 @@ func CalculateIntensityFactor(normalizedPower float64, ftp int) float64 {
 	return normalizedPower / float64(ftp)
 }
 
-// CalculateTSS calculates Training Stress Score
-func CalculateTSS(normalizedPower float64, intensityFactor float64, durationSeconds int) float64 {
+// CalculateTSS calculates Training Stress Score for the athlete's FTP
+func CalculateTSS(normalizedPower float64, intensityFactor float64, durationSeconds int, ftp int) float64 {
+	if ftp == 0 {
+		return 0
+	}
 	hours := float64(durationSeconds) / 3600
 	return (hours * normalizedPower * intensityFactor * 100) / float64(ftp)
 }
 
-var ftp int // This should be set from athlete's FTP
-
 // Base64 encoding for file uploads
 
 // EncodeFileToBase64 encodes file content to base64
@@ -0,0 +1,50 @@
+package xmlw
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer writes formatted XML text, remembering the first error so that
+// output can be written without checking every call
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// NewWriter creates a buffered writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Printf writes formatted text unless an earlier write failed
+func (w *Writer) Printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// Flush flushes buffered output and returns the first error
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// Escape escapes text for use in XML content and attributes
+func Escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Float formats a float with the fewest digits needed
+func Float(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,125 @@
+package kml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/geojson"
+	"github.com/kpi-studio/go-strava-api/internal/xmlw"
+)
+
+// Namespace is the KML 2.2 namespace
+const Namespace = "http://www.opengis.net/kml/2.2"
+
+// lineColor is the color of line placemarks in KML's aabbggrr order
+const lineColor = "ff024cfc"
+
+// ErrUnsupportedGeometry is returned for geometries other than points and line strings
+var ErrUnsupportedGeometry = errors.New("kml: unsupported geometry")
+
+// Write writes the features to w as a KML document. Each feature becomes a
+// placemark named after its "name" property and described by its
+// "description" property, with all properties as extended data. Build the
+// features with the geojson package, for example geojson.Activity.
+func Write(w io.Writer, name string, features ...*geojson.Feature) error {
+	out := xmlw.NewWriter(w)
+	out.Printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	out.Printf("<kml xmlns=\"%s\">\n <Document>\n", Namespace)
+	if name != "" {
+		out.Printf("  <name>%s</name>\n", xmlw.Escape(name))
+	}
+	out.Printf("  <Style id=\"line\"><LineStyle><color>%s</color><width>3</width></LineStyle></Style>\n", lineColor)
+
+	for _, f := range features {
+		if err := placemark(out, f); err != nil {
+			return err
+		}
+	}
+
+	out.Printf(" </Document>\n</kml>\n")
+	return out.Flush()
+}
+
+// placemark writes a feature as a placemark
+func placemark(out *xmlw.Writer, f *geojson.Feature) error {
+	if f.Geometry == nil {
+		return fmt.Errorf("%w: none", ErrUnsupportedGeometry)
+	}
+
+	out.Printf("  <Placemark")
+	if kind, ok := f.Properties["kind"].(string); ok && f.ID != 0 {
+		// KML ids cannot start with a digit
+		out.Printf(" id=\"%s-%d\"", xmlw.Escape(kind), f.ID)
+	}
+	out.Printf(">\n")
+	if s, ok := f.Properties["name"].(string); ok && s != "" {
+		out.Printf("   <name>%s</name>\n", xmlw.Escape(s))
+	}
+	if s, ok := f.Properties["description"].(string); ok && s != "" {
+		out.Printf("   <description>%s</description>\n", xmlw.Escape(s))
+	}
+
+	// KML orders the style and extended data before the geometry
+	if _, ok := f.Geometry.Coordinates.([][]float64); ok {
+		out.Printf("   <styleUrl>#line</styleUrl>\n")
+	}
+	if len(f.Properties) > 0 {
+		keys := make([]string, 0, len(f.Properties))
+		for k := range f.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out.Printf("   <ExtendedData>\n")
+		for _, k := range keys {
+			out.Printf("    <Data name=\"%s\"><value>%s</value></Data>\n", xmlw.Escape(k), xmlw.Escape(value(f.Properties[k])))
+		}
+		out.Printf("   </ExtendedData>\n")
+	}
+
+	switch coords := f.Geometry.Coordinates.(type) {
+	case []float64:
+		out.Printf("   <Point><coordinates>%s</coordinates></Point>\n", position(coords))
+	case [][]float64:
+		out.Printf("   <LineString>\n    <tessellate>1</tessellate>\n    <coordinates>")
+		for i, c := range coords {
+			if i > 0 {
+				out.Printf(" ")
+			}
+			out.Printf("%s", position(c))
+		}
+		out.Printf("</coordinates>\n   </LineString>\n")
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedGeometry, f.Geometry.Type)
+	}
+
+	out.Printf("  </Placemark>\n")
+	return nil
+}
+
+// position formats a GeoJSON position as KML coordinates
+func position(c []float64) string {
+	parts := make([]string, len(c))
+	for i, v := range c {
+		parts[i] = xmlw.Float(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// value formats a property value
+func value(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return xmlw.Float(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
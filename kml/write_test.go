@@ -0,0 +1,102 @@
+package kml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/kpi-studio/go-strava-api/geojson"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+func TestWritePlacemarkOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		geometry *geojson.Geometry
+		want     []string
+	}{
+		{
+			name:     "point",
+			geometry: geojson.NewPoint(models.LatLng{Lat: 51.5, Lng: -0.1}),
+			want:     []string{"name", "description", "ExtendedData", "Point"},
+		},
+		{
+			name:     "line string",
+			geometry: geojson.NewLineString([]models.LatLng{{Lat: 51.5, Lng: -0.1}, {Lat: 51.6, Lng: -0.2}}),
+			want:     []string{"name", "description", "styleUrl", "ExtendedData", "LineString"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &geojson.Feature{
+				Type:     "Feature",
+				ID:       1,
+				Geometry: tt.geometry,
+				Properties: map[string]interface{}{
+					"kind":        "activity",
+					"name":        "Morning Ride",
+					"description": "Loop",
+					"distance":    1200.5,
+				},
+			}
+
+			var buf bytes.Buffer
+			if err := Write(&buf, "Test", f); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got, err := placemarkChildren(&buf)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("placemark children = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteUnsupportedGeometry(t *testing.T) {
+	f := &geojson.Feature{Geometry: &geojson.Geometry{Type: "Polygon", Coordinates: [][][]float64{}}}
+	if err := Write(io.Discard, "", f); err == nil {
+		t.Error("Write: want an error for a polygon")
+	}
+}
+
+// placemarkChildren returns the names of the child elements of the first
+// placemark, in document order
+func placemarkChildren(r io.Reader) ([]string, error) {
+	dec := xml.NewDecoder(r)
+	var names []string
+	depth := -1
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case depth < 0 && tok.Name.Local == "Placemark":
+				depth = 0
+			case depth >= 0:
+				if depth == 0 {
+					names = append(names, tok.Name.Local)
+				}
+				depth++
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				return names, nil
+			}
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+}
@@ -54,6 +54,7 @@ type Activity struct {
 	Description          string        `json:"description"`
 	Calories             float64       `json:"calories"`
 	SegmentEfforts       []SegmentEffort `json:"segment_efforts"`
+	BestEfforts          []BestEffort  `json:"best_efforts"`
 	SplitsMetric         []Split       `json:"splits_metric"`
 	SplitsStandard       []Split       `json:"splits_standard"`
 	Laps                 []Lap         `json:"laps"`
@@ -96,9 +97,28 @@ type Split struct {
 	ElevationDifference float64 `json:"elevation_difference"`
 	SplitNumber         int     `json:"split"`
 	AverageSpeed        float64 `json:"average_speed"`
+	AverageHeartrate    float64 `json:"average_heartrate"`
 	PaceZone            int     `json:"pace_zone"`
 }
 
+// BestEffort represents an activity's fastest effort over a standard distance
+type BestEffort struct {
+	ID             int64         `json:"id"`
+	ResourceState  ResourceState `json:"resource_state"`
+	Name           string        `json:"name"`
+	Activity       *Activity     `json:"activity"`
+	Athlete        *Athlete      `json:"athlete"`
+	ElapsedTime    float64       `json:"elapsed_time"`
+	MovingTime     float64       `json:"moving_time"`
+	StartDate      time.Time     `json:"start_date"`
+	StartDateLocal time.Time     `json:"start_date_local"`
+	Distance       float64       `json:"distance"`
+	StartIndex     int           `json:"start_index"`
+	EndIndex       int           `json:"end_index"`
+	PRRank         int           `json:"pr_rank"`
+	Achievements   []Achievement `json:"achievements"`
+}
+
 // Lap represents a lap in an activity
 type Lap struct {
 	ID                 int64         `json:"id"`
@@ -26,6 +26,8 @@ type Athlete struct {
 	Shoes         []Gear        `json:"shoes"`
 	Clubs         []Club        `json:"clubs"`
 	FTP           int           `json:"ftp"`
+
+	MeasurementPreference string `json:"measurement_preference"` // feet or meters
 }
 
 // Stats represents athlete stats
@@ -65,6 +65,25 @@ type Map struct {
 	SummaryPolyline string        `json:"summary_polyline"`
 }
 
+// LatLng represents a latitude/longitude pair in degrees
+type LatLng struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// LatLngFromSlice converts a [lat, lng] pair as used in the API to a LatLng
+func LatLngFromSlice(latlng []float64) (LatLng, bool) {
+	if len(latlng) != 2 {
+		return LatLng{}, false
+	}
+	return LatLng{Lat: latlng[0], Lng: latlng[1]}, true
+}
+
+// Slice returns the point as a [lat, lng] pair as used in the API
+func (p LatLng) Slice() []float64 {
+	return []float64{p.Lat, p.Lng}
+}
+
 // Photos represents photos attached to an activity
 type Photos struct {
 	Primary *Photo `json:"primary"`
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// WebhookAspect is the kind of change a webhook event reports
+type WebhookAspect string
+
+const (
+	WebhookAspectCreate WebhookAspect = "create"
+	WebhookAspectUpdate WebhookAspect = "update"
+	WebhookAspectDelete WebhookAspect = "delete"
+)
+
+// WebhookObjectType is the kind of object a webhook event is about
+type WebhookObjectType string
+
+const (
+	WebhookObjectActivity WebhookObjectType = "activity"
+	WebhookObjectAthlete  WebhookObjectType = "athlete"
+)
+
+// WebhookEvent is an event pushed to a webhook subscription's callback URL
+type WebhookEvent struct {
+	AspectType     WebhookAspect          `json:"aspect_type"`
+	EventTime      int64                  `json:"event_time"`
+	ObjectID       int64                  `json:"object_id"`
+	ObjectType     WebhookObjectType      `json:"object_type"`
+	OwnerID        int64                  `json:"owner_id"`
+	SubscriptionID int64                  `json:"subscription_id"`
+	Updates        map[string]interface{} `json:"updates"` // title, type, private or authorized
+}
+
+// Time returns when the event happened
+func (e *WebhookEvent) Time() time.Time {
+	return time.Unix(e.EventTime, 0)
+}
+
+// Update returns an updated field's new value as a string, such as "true"
+// for private
+func (e *WebhookEvent) Update(field string) (string, bool) {
+	v, ok := e.Updates[field]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+// Deauthorized reports whether the event is an athlete revoking access
+func (e *WebhookEvent) Deauthorized() bool {
+	v, ok := e.Update("authorized")
+	return e.ObjectType == WebhookObjectAthlete && ok && v == "false"
+}
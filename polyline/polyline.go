@@ -0,0 +1,205 @@
+package polyline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+const (
+	// Precision5 is the precision of Google and Strava polylines (1e5)
+	Precision5 = 5
+
+	// Precision6 is the precision used by OSRM and Valhalla (1e6)
+	Precision6 = 6
+)
+
+var (
+	// ErrTruncated is returned when a polyline ends in the middle of a value or point
+	ErrTruncated = errors.New("polyline: truncated input")
+
+	// ErrInvalidCharacter is returned for bytes outside the polyline alphabet
+	ErrInvalidCharacter = errors.New("polyline: invalid character")
+
+	// ErrOverflow is returned when a value is too long to decode
+	ErrOverflow = errors.New("polyline: value overflows")
+
+	// ErrInvalidPrecision is returned for precisions outside 1 to 9
+	ErrInvalidPrecision = errors.New("polyline: invalid precision")
+)
+
+// Decoder decodes a polyline one point at a time without allocating
+type Decoder struct {
+	encoded string
+	index   int
+	factor  float64
+	lat     int64
+	lng     int64
+	err     error
+}
+
+// NewDecoder creates a decoder for an encoded polyline at the given precision
+func NewDecoder(encoded string, precision int) *Decoder {
+	d := &Decoder{encoded: encoded}
+	if precision < 1 || precision > 9 {
+		d.err = ErrInvalidPrecision
+		return d
+	}
+	d.factor = math.Pow10(precision)
+	return d
+}
+
+// Next decodes the next point. It returns false at the end of the polyline or
+// on error; check Err to tell them apart.
+func (d *Decoder) Next() (models.LatLng, bool) {
+	if d.err != nil || d.index >= len(d.encoded) {
+		return models.LatLng{}, false
+	}
+
+	dlat, err := d.value()
+	if err != nil {
+		d.err = err
+		return models.LatLng{}, false
+	}
+	if d.index >= len(d.encoded) {
+		d.err = fmt.Errorf("%w: missing longitude at offset %d", ErrTruncated, d.index)
+		return models.LatLng{}, false
+	}
+	dlng, err := d.value()
+	if err != nil {
+		d.err = err
+		return models.LatLng{}, false
+	}
+
+	d.lat += dlat
+	d.lng += dlng
+	return models.LatLng{
+		Lat: float64(d.lat) / d.factor,
+		Lng: float64(d.lng) / d.factor,
+	}, true
+}
+
+// Err returns the first error encountered while decoding
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// value decodes a single zigzag-encoded varint
+func (d *Decoder) value() (int64, error) {
+	var result int64
+	var shift uint
+	for {
+		if d.index >= len(d.encoded) {
+			return 0, fmt.Errorf("%w at offset %d", ErrTruncated, d.index)
+		}
+		c := d.encoded[d.index]
+		if c < 63 || c > 126 {
+			return 0, fmt.Errorf("%w %q at offset %d", ErrInvalidCharacter, c, d.index)
+		}
+		if shift > 55 {
+			return 0, fmt.Errorf("%w at offset %d", ErrOverflow, d.index)
+		}
+		d.index++
+
+		b := int64(c) - 63
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+	}
+
+	if result&1 != 0 {
+		return ^(result >> 1), nil
+	}
+	return result >> 1, nil
+}
+
+// Decode decodes a Google polyline at precision 5
+func Decode(encoded string) ([]models.LatLng, error) {
+	return DecodePrecision(encoded, Precision5)
+}
+
+// DecodePrecision decodes a polyline at the given precision
+func DecodePrecision(encoded string, precision int) ([]models.LatLng, error) {
+	d := NewDecoder(encoded, precision)
+
+	// Every point takes at least two bytes
+	points := make([]models.LatLng, 0, len(encoded)/2)
+	for {
+		p, ok := d.Next()
+		if !ok {
+			break
+		}
+		points = append(points, p)
+	}
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	return points, nil
+}
+
+// Encode encodes points as a Google polyline at precision 5
+func Encode(points []models.LatLng) string {
+	return EncodePrecision(points, Precision5)
+}
+
+// EncodePrecision encodes points as a polyline at the given precision
+func EncodePrecision(points []models.LatLng, precision int) string {
+	factor := math.Pow10(precision)
+
+	var encoded strings.Builder
+	encoded.Grow(len(points) * 8)
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * factor))
+		lng := int64(math.Round(p.Lng * factor))
+
+		encodeValue(&encoded, lat-prevLat)
+		encodeValue(&encoded, lng-prevLng)
+
+		prevLat = lat
+		prevLng = lng
+	}
+
+	return encoded.String()
+}
+
+// encodeValue writes a zigzag-encoded varint
+func encodeValue(encoded *strings.Builder, value int64) {
+	if value < 0 {
+		value = ^(value << 1)
+	} else {
+		value = value << 1
+	}
+
+	for value >= 0x20 {
+		encoded.WriteByte(byte((0x20 | (value & 0x1f)) + 63))
+		value >>= 5
+	}
+	encoded.WriteByte(byte(value + 63))
+}
+
+// ToSlices converts points to [lat, lng] pairs as used in the API
+func ToSlices(points []models.LatLng) [][]float64 {
+	coords := make([][]float64, len(points))
+	for i, p := range points {
+		coords[i] = p.Slice()
+	}
+	return coords
+}
+
+// FromSlices converts [lat, lng] pairs as used in the API to points, skipping
+// malformed pairs
+func FromSlices(coords [][]float64) []models.LatLng {
+	points := make([]models.LatLng, 0, len(coords))
+	for _, c := range coords {
+		if p, ok := models.LatLngFromSlice(c); ok {
+			points = append(points, p)
+		}
+	}
+	return points
+}
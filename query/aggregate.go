@@ -0,0 +1,48 @@
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Total sums the activities of a calendar period
+type Total struct {
+	Start         time.Time `json:"start"`
+	Activities    int       `json:"activities"`
+	Distance      float64   `json:"distance"`       // meters
+	MovingTime    float64   `json:"moving_time"`    // seconds
+	ElapsedTime   float64   `json:"elapsed_time"`   // seconds
+	ElevationGain float64   `json:"elevation_gain"` // meters
+}
+
+// Aggregate sums activities by the period of their local start date, in
+// chronological order. Periods without activities are left out.
+func Aggregate(activities []*models.Activity, period analytics.Period) []Total {
+	totals := make(map[time.Time]*Total)
+	for _, a := range activities {
+		start := analytics.PeriodStart(analytics.LocalStart(a), period)
+		total, ok := totals[start]
+		if !ok {
+			total = &Total{Start: start}
+			totals[start] = total
+		}
+
+		total.Activities++
+		total.Distance += a.Distance
+		total.MovingTime += a.MovingTime
+		total.ElapsedTime += a.ElapsedTime
+		total.ElevationGain += a.TotalElevationGain
+	}
+
+	result := make([]Total, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
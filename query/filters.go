@@ -0,0 +1,256 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// filters parse the value of each filter field
+var filters = map[string]func(op, value string) (filter, error){
+	"type": func(op, value string) (filter, error) {
+		return oneOf(op, value, func(a *models.Activity) string { return string(a.Type) })
+	},
+	"sport": func(op, value string) (filter, error) {
+		return oneOf(op, value, analytics.Sport)
+	},
+	"gear": func(op, value string) (filter, error) {
+		if err := equality(op); err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(value, "none") {
+			value = ""
+		}
+		return func(a *models.Activity) bool { return a.GearID == value }, nil
+	},
+	"name": func(op, value string) (filter, error) {
+		if err := equality(op); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return nil, err
+		}
+		return func(a *models.Activity) bool { return re.MatchString(a.Name) }, nil
+	},
+	"commute": func(op, value string) (filter, error) {
+		return flag(op, value, func(a *models.Activity) bool { return a.Commute })
+	},
+	"trainer": func(op, value string) (filter, error) {
+		return flag(op, value, func(a *models.Activity) bool { return a.Trainer })
+	},
+	"private": func(op, value string) (filter, error) {
+		return flag(op, value, func(a *models.Activity) bool { return a.Private })
+	},
+	"manual": func(op, value string) (filter, error) {
+		return flag(op, value, func(a *models.Activity) bool { return a.Manual })
+	},
+	"bbox": bbox,
+	"after": func(op, value string) (filter, error) {
+		if err := equality(op); err != nil {
+			return nil, err
+		}
+		return date(">=", value)
+	},
+	"before": func(op, value string) (filter, error) {
+		if err := equality(op); err != nil {
+			return nil, err
+		}
+		return date("<", value)
+	},
+	"date": date,
+	"distance": func(op, value string) (filter, error) {
+		meters, err := quantity(value, "km", map[string]float64{"m": 1, "km": 1000, "mi": 1609.344})
+		if err != nil {
+			return nil, err
+		}
+		return compare(op, meters, func(a *models.Activity) float64 { return a.Distance })
+	},
+	"elevation": func(op, value string) (filter, error) {
+		meters, err := quantity(value, "m", map[string]float64{"m": 1, "ft": 0.3048})
+		if err != nil {
+			return nil, err
+		}
+		return compare(op, meters, func(a *models.Activity) float64 { return a.TotalElevationGain })
+	},
+	"moving_time": func(op, value string) (filter, error) {
+		seconds, err := duration(value)
+		if err != nil {
+			return nil, err
+		}
+		return compare(op, seconds, func(a *models.Activity) float64 { return a.MovingTime })
+	},
+	"elapsed_time": func(op, value string) (filter, error) {
+		seconds, err := duration(value)
+		if err != nil {
+			return nil, err
+		}
+		return compare(op, seconds, func(a *models.Activity) float64 { return a.ElapsedTime })
+	},
+}
+
+// errNeedsColon is returned for comparisons on fields that only match values
+var errNeedsColon = errors.New("use field:value")
+
+// equality checks that an operator matches values rather than comparing them
+func equality(op string) error {
+	if op != ":" && op != "=" {
+		return errNeedsColon
+	}
+	return nil
+}
+
+// oneOf matches a string field against comma separated values, case
+// insensitively
+func oneOf(op, value string, field func(a *models.Activity) string) (filter, error) {
+	if err := equality(op); err != nil {
+		return nil, err
+	}
+	values := strings.Split(value, ",")
+	return func(a *models.Activity) bool {
+		for _, v := range values {
+			if strings.EqualFold(field(a), v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// flag matches a boolean field
+func flag(op, value string, field func(a *models.Activity) bool) (filter, error) {
+	if err := equality(op); err != nil {
+		return nil, err
+	}
+	want, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not true or false", value)
+	}
+	return func(a *models.Activity) bool { return field(a) == want }, nil
+}
+
+// bbox matches activities starting inside minLat,minLng,maxLat,maxLng
+func bbox(op, value string) (filter, error) {
+	if err := equality(op); err != nil {
+		return nil, err
+	}
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("bbox needs minLat,minLng,maxLat,maxLng")
+	}
+	var box [4]float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", p)
+		}
+		box[i] = v
+	}
+	return func(a *models.Activity) bool {
+		start, ok := models.LatLngFromSlice(a.StartLatlng)
+		return ok && start.Lat >= box[0] && start.Lng >= box[1] && start.Lat <= box[2] && start.Lng <= box[3]
+	}, nil
+}
+
+// dateLayouts are the accepted date formats, with the length of the period
+// each names as years, months and days
+var dateLayouts = []struct {
+	layout              string
+	years, months, days int
+}{
+	{"2006-01-02", 0, 0, 1},
+	{"2006-01", 0, 1, 0},
+	{"2006", 1, 0, 0},
+}
+
+// date compares the local start date with a date, month or year. Strava's
+// local start dates are encoded as UTC, so the date is too.
+func date(op, value string) (filter, error) {
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return compareTime(op, t, t.AddDate(l.years, l.months, l.days))
+		}
+	}
+	return nil, fmt.Errorf("%q is not a date like 2024-01-15, 2024-01 or 2024", value)
+}
+
+// compareTime compares the local start time with the period from start to
+// end, which matches ":" and "="
+func compareTime(op string, start, end time.Time) (filter, error) {
+	switch op {
+	case ":", "=":
+		return func(a *models.Activity) bool { t := analytics.LocalStart(a); return !t.Before(start) && t.Before(end) }, nil
+	case "<":
+		return func(a *models.Activity) bool { return analytics.LocalStart(a).Before(start) }, nil
+	case "<=":
+		return func(a *models.Activity) bool { return analytics.LocalStart(a).Before(end) }, nil
+	case ">":
+		return func(a *models.Activity) bool { return !analytics.LocalStart(a).Before(end) }, nil
+	default:
+		return func(a *models.Activity) bool { return !analytics.LocalStart(a).Before(start) }, nil
+	}
+}
+
+// compare compares a numeric field with a value
+func compare(op string, value float64, field func(a *models.Activity) float64) (filter, error) {
+	switch op {
+	case ":", "=":
+		return func(a *models.Activity) bool { return field(a) == value }, nil
+	case "<":
+		return func(a *models.Activity) bool { return field(a) < value }, nil
+	case "<=":
+		return func(a *models.Activity) bool { return field(a) <= value }, nil
+	case ">":
+		return func(a *models.Activity) bool { return field(a) > value }, nil
+	default:
+		return func(a *models.Activity) bool { return field(a) >= value }, nil
+	}
+}
+
+// quantity parses a number with an optional unit, returning it in the
+// units' base unit
+func quantity(value, defaultUnit string, units map[string]float64) (float64, error) {
+	number := strings.TrimRightFunc(value, func(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' })
+	unit := strings.ToLower(value[len(number):])
+	if unit == "" {
+		unit = defaultUnit
+	}
+	scale, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", unit)
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", number)
+	}
+	return v * scale, nil
+}
+
+// duration parses minutes or a duration such as 1h30m, returning seconds
+func duration(value string) (float64, error) {
+	if minutes, err := strconv.ParseFloat(value, 64); err == nil {
+		return minutes * 60, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not minutes or a duration like 1h30m", value)
+	}
+	return d.Seconds(), nil
+}
+
+// contains matches activities whose name contains a word, case insensitively
+func contains(word string) filter {
+	word = strings.ToLower(word)
+	return func(a *models.Activity) bool { return strings.Contains(strings.ToLower(a.Name), word) }
+}
+
+// not negates a filter
+func not(f filter) filter {
+	return func(a *models.Activity) bool { return !f(a) }
+}
@@ -0,0 +1,285 @@
+package query
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kpi-studio/go-strava-api/activitysync"
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+var (
+	// ErrSyntax is returned for queries that cannot be parsed
+	ErrSyntax = errors.New("query: syntax error")
+
+	// ErrUnknownField is returned for filter and sort fields that do not exist
+	ErrUnknownField = errors.New("query: unknown field")
+)
+
+// Query filters, sorts and limits activities. Parse builds one from the
+// query language:
+//
+//	type:Ride distance>50km after:2024-01-01 sort:-distance limit:10
+//
+// A query is a list of terms separated by spaces, all of which must match.
+// A term is a field, an operator and a value, such as distance>50km, or a
+// bare word matched against the activity name. Values with spaces are
+// quoted, as in name:"morning ride", and a term starting with "-" is
+// negated, as in -type:VirtualRide.
+//
+// Fields compared with ":" (or "="):
+//
+//	type, sport      activity or sport type, case insensitive; several
+//	                 types separated by commas match any of them
+//	gear             gear ID, or "none" for activities without gear
+//	name             regular expression matched against the name, case insensitive
+//	commute, trainer, private, manual
+//	                 true or false
+//	bbox             minLat,minLng,maxLat,maxLng around the start point
+//	after, before    a date (2024-01-15, 2024-01 or 2024); after includes
+//	                 the date, before excludes it
+//
+// Fields compared with ":", "=", "<", "<=", ">" or ">=":
+//
+//	date             a local start date, month or year, as for after and
+//	                 before; date:2024-03 matches all of March
+//	distance         in km by default, or with a unit: 800m, 50km, 26.2mi
+//	elevation        total elevation gain in m by default, or in ft
+//	moving_time, elapsed_time
+//	                 in minutes by default, or a duration such as 1h30m
+//
+// Options:
+//
+//	sort:field       sort by date, name, type, distance, elevation,
+//	                 moving_time, elapsed_time, speed or kudos; sort:-field
+//	                 sorts in descending order
+//	limit:n          keep the first n results
+//	group:period     aggregate the results by day, week, month or year
+//	                 with Aggregate
+type Query struct {
+	// Sort is the field results are sorted by, or empty to keep their order
+	Sort string
+
+	// Descending sorts results from the largest value
+	Descending bool
+
+	// Limit is the maximum number of results, or 0 for all
+	Limit int
+
+	// Group is the period results are meant to be aggregated by, or empty
+	Group analytics.Period
+
+	filters []filter
+}
+
+// filter reports whether an activity matches a term
+type filter func(a *models.Activity) bool
+
+// Parse parses a query
+func Parse(s string) (*Query, error) {
+	terms, err := split(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, term := range terms {
+		if err := q.parseTerm(term); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Match reports whether an activity matches every term of the query
+func (q *Query) Match(a *models.Activity) bool {
+	for _, f := range q.filters {
+		if !f(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the matching activities, sorted and limited
+func (q *Query) Apply(activities []*models.Activity) []*models.Activity {
+	var result []*models.Activity
+	for _, a := range activities {
+		if q.Match(a) {
+			result = append(result, a)
+		}
+	}
+
+	if q.Sort != "" {
+		// Parse has checked the field
+		SortBy(result, q.Sort, q.Descending)
+	}
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result
+}
+
+// Run applies the query to the activities in a store
+func (q *Query) Run(ctx context.Context, store activitysync.Store) ([]*models.Activity, error) {
+	ids, err := store.ActivityIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var activities []*models.Activity
+	for _, id := range ids {
+		a, err := store.Activity(ctx, id)
+		if errors.Is(err, activitysync.ErrNotFound) {
+			// Deleted since listing
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return q.Apply(activities), nil
+}
+
+// sortFields compare activities by each sort field
+var sortFields = map[string]func(a, b *models.Activity) int{
+	"date":         func(a, b *models.Activity) int { return a.StartDate.Compare(b.StartDate) },
+	"name":         func(a, b *models.Activity) int { return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"type":         func(a, b *models.Activity) int { return cmp.Compare(a.Type, b.Type) },
+	"distance":     func(a, b *models.Activity) int { return cmp.Compare(a.Distance, b.Distance) },
+	"elevation":    func(a, b *models.Activity) int { return cmp.Compare(a.TotalElevationGain, b.TotalElevationGain) },
+	"moving_time":  func(a, b *models.Activity) int { return cmp.Compare(a.MovingTime, b.MovingTime) },
+	"elapsed_time": func(a, b *models.Activity) int { return cmp.Compare(a.ElapsedTime, b.ElapsedTime) },
+	"speed":        func(a, b *models.Activity) int { return cmp.Compare(a.AverageSpeed, b.AverageSpeed) },
+	"kudos":        func(a, b *models.Activity) int { return cmp.Compare(a.KudosCount, b.KudosCount) },
+}
+
+// SortBy sorts activities by a sort field, keeping the order of equal
+// activities
+func SortBy(activities []*models.Activity, field string, descending bool) error {
+	compare, ok := sortFields[field]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownField, field)
+	}
+	slices.SortStableFunc(activities, func(a, b *models.Activity) int {
+		if descending {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+	return nil
+}
+
+// parseTerm adds a term to the query
+func (q *Query) parseTerm(term string) error {
+	negate := false
+	if len(term) > 1 && term[0] == '-' {
+		negate, term = true, term[1:]
+	}
+
+	var f filter
+	key, op, value := cut(term)
+	key = strings.ToLower(key)
+	switch key {
+	case "":
+		f = contains(term)
+	case "sort", "limit", "group":
+		if negate || op != ":" {
+			return fmt.Errorf("%w: %q: use %s:value", ErrSyntax, term, key)
+		}
+		return q.parseOption(key, value)
+	default:
+		parse, ok := filters[key]
+		if !ok {
+			return fmt.Errorf("%w %q", ErrUnknownField, key)
+		}
+		var err error
+		if f, err = parse(op, value); err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrSyntax, term, err)
+		}
+	}
+	if negate {
+		f = not(f)
+	}
+	q.filters = append(q.filters, f)
+	return nil
+}
+
+// parseOption sets the sort, limit or group option
+func (q *Query) parseOption(key, value string) error {
+	switch key {
+	case "sort":
+		field, descending := strings.CutPrefix(strings.ToLower(value), "-")
+		if _, ok := sortFields[field]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownField, field)
+		}
+		q.Sort, q.Descending = field, descending
+	case "limit":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%w: limit %q is not a positive number", ErrSyntax, value)
+		}
+		q.Limit = n
+	case "group":
+		period := analytics.Period(strings.ToLower(value))
+		switch period {
+		case analytics.PeriodDay, analytics.PeriodWeek, analytics.PeriodMonth, analytics.PeriodYear:
+			q.Group = period
+		default:
+			return fmt.Errorf("%w: group %q is not day, week, month or year", ErrSyntax, value)
+		}
+	}
+	return nil
+}
+
+// split splits a query into terms at spaces outside double quotes,
+// removing the quotes
+func split(s string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted, inTerm := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted, inTerm = !quoted, true
+		case unicode.IsSpace(r) && !quoted:
+			if inTerm {
+				terms = append(terms, term.String())
+				term.Reset()
+				inTerm = false
+			}
+		default:
+			term.WriteRune(r)
+			inTerm = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrSyntax)
+	}
+	if inTerm {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// cut splits a term at its first operator, returning an empty operator for
+// bare words
+func cut(term string) (key, op, value string) {
+	i := strings.IndexAny(term, ":=<>")
+	if i <= 0 {
+		return "", "", term
+	}
+	op = term[i : i+1]
+	if (op == "<" || op == ">") && i+1 < len(term) && term[i+1] == '=' {
+		op += "="
+	}
+	return term[:i], op, term[i+len(op):]
+}
@@ -0,0 +1,137 @@
+package query
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// activities returns a small history to query
+func activities() []*models.Activity {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 8, 0, 0, 0, time.UTC) }
+	return []*models.Activity{
+		{ID: 1, Name: "Morning Ride", Type: models.ActivityTypeRide, SportType: "Ride", Distance: 62000, TotalElevationGain: 800, MovingTime: 9000, StartDateLocal: day(2024, 1, 15), GearID: "b1", StartLatlng: []float64{51.5, -0.1}},
+		{ID: 2, Name: "Zwift race", Type: models.ActivityTypeVirtualRide, Distance: 30000, MovingTime: 3600, StartDateLocal: day(2024, 2, 3), Trainer: true},
+		{ID: 3, Name: "Commute home", Type: models.ActivityTypeRide, SportType: "Ride", Distance: 8000, TotalElevationGain: 40, MovingTime: 1500, StartDateLocal: day(2024, 2, 20), Commute: true, GearID: "b1"},
+		{ID: 4, Name: "Long run", Type: models.ActivityTypeRun, SportType: "TrailRun", Distance: 21097.5, TotalElevationGain: 350, MovingTime: 7200, StartDateLocal: day(2023, 12, 31), StartLatlng: []float64{48.8, 2.3}},
+		{ID: 5, Name: "Morning run", Type: models.ActivityTypeRun, SportType: "Run", Distance: 5000, MovingTime: 1500, StartDate: day(2024, 3, 1)},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{1, 2, 3, 4, 5}},
+		{"type:ride", []int64{1, 3}},
+		{"type:Ride,VirtualRide", []int64{1, 2, 3}},
+		{"-type:VirtualRide type:ride,virtualride", []int64{1, 3}},
+		{"sport:trailrun", []int64{4}},
+		{"sport:virtualride", []int64{2}},
+		{"gear:b1", []int64{1, 3}},
+		{"gear:none", []int64{2, 4, 5}},
+		{"name:^morning", []int64{1, 5}},
+		{`name:"ride$"`, []int64{1}},
+		{"morning", []int64{1, 5}},
+		{`"long run"`, []int64{4}},
+		{"commute:true", []int64{3}},
+		{"trainer:false commute:false", []int64{1, 4, 5}},
+		{"distance>50km", []int64{1}},
+		{"distance>=21.0975", []int64{1, 2, 4}},
+		{"distance<8000m", []int64{5}},
+		{"distance=13.1094mi", nil},
+		{"distance>13mi", []int64{1, 2, 4}},
+		{"elevation>1000ft", []int64{1, 4}},
+		{"moving_time>=2h", []int64{1, 4}},
+		{"moving_time<30", []int64{3, 5}},
+		{"elapsed_time>0", nil},
+		{"after:2024-02-01", []int64{2, 3, 5}},
+		{"before:2024", []int64{4}},
+		{"date:2024-02", []int64{2, 3}},
+		{"date:2024-03-01", []int64{5}},
+		{"date>=2024-02-20", []int64{3, 5}},
+		{"bbox:51,-1,52,0", []int64{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var got []int64
+			for _, a := range q.Apply(activities()) {
+				got = append(got, a.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	q, err := Parse("type:ride,run sort:-distance limit:3 group:month")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if q.Sort != "distance" || !q.Descending || q.Limit != 3 || q.Group != analytics.PeriodMonth {
+		t.Errorf("options = %q %v %d %q, want distance true 3 month", q.Sort, q.Descending, q.Limit, q.Group)
+	}
+
+	var got []int64
+	for _, a := range q.Apply(activities()) {
+		got = append(got, a.ID)
+	}
+	if want := []int64{1, 4, 3}; !slices.Equal(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	totals := Aggregate(activities(), analytics.PeriodMonth)
+
+	var got []string
+	for _, total := range totals {
+		got = append(got, total.Start.Format("2006-01")+" "+strconv.Itoa(total.Activities))
+	}
+	if want := []string{"2023-12 1", "2024-01 1", "2024-02 2", "2024-03 1"}; !slices.Equal(got, want) {
+		t.Errorf("totals %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  error
+	}{
+		{"colour:red", ErrUnknownField},
+		{"sort:color", ErrUnknownField},
+		{`name:"unterminated`, ErrSyntax},
+		{"name:(", ErrSyntax},
+		{"distance>far", ErrSyntax},
+		{"distance>5parsecs", ErrSyntax},
+		{"type>ride", ErrSyntax},
+		{"commute:maybe", ErrSyntax},
+		{"after:yesterday", ErrSyntax},
+		{"bbox:1,2,3", ErrSyntax},
+		{"limit:0", ErrSyntax},
+		{"limit<5", ErrSyntax},
+		{"-sort:date", ErrSyntax},
+		{"group:fortnight", ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if _, err := Parse(tt.query); !errors.Is(err, tt.want) {
+				t.Errorf("Parse = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/export"
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// activityURL is the web page of an activity
+const activityURL = "https://www.strava.com/activities/%d"
+
+// view is a report laid out for rendering, with every value formatted
+type view struct {
+	Title   string
+	Summary string
+	Tables  []table
+}
+
+// table is a titled table of a view
+type table struct {
+	Heading string
+	Header  []string
+	Rows    [][]cell
+}
+
+// cell is a table cell, linked when URL is set
+type cell struct {
+	Text string
+	URL  string
+}
+
+// htmlTemplate renders a view as an HTML fragment
+var htmlTemplate = template.Must(template.New("report").Parse(`<div class="strava-report">
+<h1>{{.Title}}</h1>
+<p>{{.Summary}}</p>
+{{range .Tables}}<h2>{{.Heading}}</h2>
+<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{if .URL}}<a href="{{.URL}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}</div>
+`))
+
+// WriteMarkdown writes a report as a Markdown document
+func WriteMarkdown(w io.Writer, r *Report) error {
+	v := r.view()
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "# %s\n\n%s\n", markdown(v.Title), markdown(v.Summary))
+	for _, t := range v.Tables {
+		fmt.Fprintf(out, "\n## %s\n\n|", markdown(t.Heading))
+		for _, h := range t.Header {
+			fmt.Fprintf(out, " %s |", markdown(h))
+		}
+		out.WriteString("\n|")
+		for range t.Header {
+			out.WriteString(" --- |")
+		}
+		out.WriteString("\n")
+		for _, row := range t.Rows {
+			out.WriteString("|")
+			for _, c := range row {
+				if c.URL != "" {
+					fmt.Fprintf(out, " [%s](%s) |", markdown(c.Text), c.URL)
+				} else {
+					fmt.Fprintf(out, " %s |", markdown(c.Text))
+				}
+			}
+			out.WriteString("\n")
+		}
+	}
+	return out.Flush()
+}
+
+// WriteHTML writes a report as an HTML fragment, a div of headings and
+// tables without styles, to embed in a page or an email
+func WriteHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, r.view())
+}
+
+// view lays out the report
+func (r *Report) view() *view {
+	previous := analytics.PeriodStart(r.Start.AddDate(0, 0, -1), r.Period)
+	v := &view{Title: label(r.Start, r.Period)}
+
+	if r.Totals.Activities == 0 {
+		v.Summary = "No activities."
+	} else {
+		v.Summary = fmt.Sprintf("%s, %s, %s moving, %s climbed.",
+			plural(r.Totals.Activities, "activity", "activities"), r.distance(r.Totals.Distance),
+			duration(r.Totals.MovingTime), r.elevation(r.Totals.ElevationGain))
+	}
+
+	v.Tables = append(v.Tables, table{
+		Heading: "Compared with " + label(previous, r.Period),
+		Header:  []string{"", label(r.Start, r.Period), label(previous, r.Period), "Change"},
+		Rows: [][]cell{
+			compareRow("Activities", float64(r.Totals.Activities), float64(r.Previous.Activities), func(v float64) string { return fmt.Sprint(v) }),
+			compareRow("Distance", r.Totals.Distance, r.Previous.Distance, r.distance),
+			compareRow("Moving time", r.Totals.MovingTime, r.Previous.MovingTime, duration),
+			compareRow("Elevation gain", r.Totals.ElevationGain, r.Previous.ElevationGain, r.elevation),
+		},
+	})
+
+	sports := table{
+		Heading: "By sport",
+		Header:  []string{"Sport", "Activities", "Distance", "Moving time", "Elevation gain", "Time change"},
+	}
+	highlights := table{
+		Heading: "Highlights",
+		Header:  []string{"Sport", "Longest", "Fastest", "Most climbing"},
+	}
+	for _, s := range r.Sports {
+		sports.Rows = append(sports.Rows, []cell{
+			{Text: s.Sport},
+			{Text: fmt.Sprint(s.Totals.Activities)},
+			{Text: r.distance(s.Totals.Distance)},
+			{Text: duration(s.Totals.MovingTime)},
+			{Text: r.elevation(s.Totals.ElevationGain)},
+			{Text: change(s.Totals.MovingTime, s.Previous.MovingTime)},
+		})
+		if s.Longest != nil || s.MostClimbing != nil {
+			highlights.Rows = append(highlights.Rows, []cell{
+				{Text: s.Sport},
+				activityCell(s.Longest, func(a *models.Activity) string { return r.distance(a.Distance) }),
+				activityCell(s.Fastest, r.speed),
+				activityCell(s.MostClimbing, func(a *models.Activity) string { return r.elevation(a.TotalElevationGain) }),
+			})
+		}
+	}
+	if len(sports.Rows) > 0 {
+		v.Tables = append(v.Tables, sports)
+	}
+	if len(highlights.Rows) > 0 {
+		v.Tables = append(v.Tables, highlights)
+	}
+
+	if len(r.Zones) > 0 {
+		zones := table{Heading: "Time in zones", Header: []string{"Zone", "Range", "Time", "Share"}}
+		var total int
+		for _, z := range r.Zones {
+			total += z.Time
+		}
+		for i, z := range r.Zones {
+			bounds := fmt.Sprintf("%d–%d", z.Min, z.Max)
+			if z.Max < 0 {
+				bounds = fmt.Sprintf("%d+", z.Min)
+			}
+			share := 0.0
+			if total > 0 {
+				share = float64(z.Time) / float64(total)
+			}
+			zones.Rows = append(zones.Rows, []cell{
+				{Text: fmt.Sprintf("Z%d", i+1)},
+				{Text: bounds},
+				{Text: duration(float64(z.Time))},
+				{Text: fmt.Sprintf("%.0f%%", share*100)},
+			})
+		}
+		v.Tables = append(v.Tables, zones)
+	}
+
+	v.Tables = append(v.Tables, table{
+		Heading: "Streaks",
+		Header:  []string{"Streak", "Length", "Since"},
+		Rows: [][]cell{
+			streakRow("Daily", r.DailyStreak, analytics.PeriodDay),
+			streakRow("Weekly", r.WeeklyStreak, analytics.PeriodWeek),
+		},
+	})
+	return v
+}
+
+// compareRow returns a row comparing a total with the previous period's
+func compareRow(name string, current, previous float64, format func(float64) string) []cell {
+	return []cell{{Text: name}, {Text: format(current)}, {Text: format(previous)}, {Text: change(current, previous)}}
+}
+
+// streakRow returns a row describing a streak
+func streakRow(name string, s analytics.Streak, period analytics.Period) []cell {
+	if s.Length == 0 {
+		return []cell{{Text: name}, {Text: "none"}, {}}
+	}
+	unit := map[analytics.Period][2]string{
+		analytics.PeriodDay:  {"day", "days"},
+		analytics.PeriodWeek: {"week", "weeks"},
+	}[period]
+	return []cell{{Text: name}, {Text: plural(s.Length, unit[0], unit[1])}, {Text: label(s.Start, period)}}
+}
+
+// activityCell links to an activity, with a value describing it
+func activityCell(a *models.Activity, value func(a *models.Activity) string) cell {
+	if a == nil {
+		return cell{}
+	}
+	return cell{Text: fmt.Sprintf("%s, %s", a.Name, value(a)), URL: fmt.Sprintf(activityURL, a.ID)}
+}
+
+// label names the period starting at start
+func label(start time.Time, period analytics.Period) string {
+	switch period {
+	case analytics.PeriodWeek:
+		return "Week of " + start.Format("Jan 2, 2006")
+	case analytics.PeriodMonth:
+		return start.Format("January 2006")
+	case analytics.PeriodYear:
+		return start.Format("2006")
+	default:
+		return start.Format("Mon, Jan 2, 2006")
+	}
+}
+
+// change formats the relative change from previous to current
+func change(current, previous float64) string {
+	c, ok := Change(current, previous)
+	switch {
+	case !ok && current == 0:
+		return ""
+	case !ok:
+		return "new"
+	default:
+		return fmt.Sprintf("%+.0f%%", c*100)
+	}
+}
+
+// distance formats meters as kilometers or miles
+func (r *Report) distance(meters float64) string {
+	if r.Units == export.UnitsImperial {
+		return fmt.Sprintf("%.1f mi", utils.MetersToMiles(meters))
+	}
+	return fmt.Sprintf("%.1f km", utils.MetersToKilometers(meters))
+}
+
+// elevation formats meters as meters or feet
+func (r *Report) elevation(meters float64) string {
+	if r.Units == export.UnitsImperial {
+		return fmt.Sprintf("%.0f ft", utils.MetersToFeet(meters))
+	}
+	return fmt.Sprintf("%.0f m", meters)
+}
+
+// speed formats an activity's average speed, as a pace for runs
+func (r *Report) speed(a *models.Activity) string {
+	imperial := r.Units == export.UnitsImperial
+	if utils.IsRunActivity(a.Type) {
+		if imperial {
+			return utils.FormatPace(utils.CalculatePacePerMile(a.Distance, int(a.MovingTime))) + " /mi"
+		}
+		return utils.FormatPace(utils.CalculatePacePerKilometer(a.Distance, int(a.MovingTime))) + " /km"
+	}
+	if imperial {
+		return fmt.Sprintf("%.1f mph", utils.MetersPerSecondToMilesPerHour(a.AverageSpeed))
+	}
+	return fmt.Sprintf("%.1f km/h", utils.MetersPerSecondToKilometersPerHour(a.AverageSpeed))
+}
+
+// duration formats seconds as H:MM:SS
+func duration(seconds float64) string {
+	return utils.FormatDuration(int(math.Round(seconds)))
+}
+
+// plural formats a count with the singular or plural noun
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
+
+// markdownEscaper escapes characters with a meaning in Markdown text and
+// tables
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "|", `\|`, "<", `\<`, "#", `\#`,
+)
+
+// markdown escapes text for Markdown
+func markdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/export"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// Options contains options for generating a report
+type Options struct {
+	// Units are the units the report is rendered in (default: metric). Use
+	// UnitsFor to follow the athlete's preference.
+	Units export.Units
+
+	// Zones are heart rate or power zone distributions of the activities,
+	// such as from analytics.HeartrateTimeInZones, summed over the period
+	// for the time in zones (default: none)
+	Zones []analytics.ZoneDistribution
+}
+
+// Report summarizes the activities of a calendar period and compares them
+// with the period before
+type Report struct {
+	Period analytics.Period `json:"period"`
+	Start  time.Time        `json:"start"` // local start of the period
+	End    time.Time        `json:"end"`   // local start of the next period
+	Units  export.Units     `json:"units"`
+
+	Totals   Totals `json:"totals"`
+	Previous Totals `json:"previous"` // totals of the previous period
+
+	// Sports are the totals of each sport done in either period, by moving
+	// time in the period, longest first
+	Sports []SportSummary `json:"sports"`
+
+	// Zones is the time in each zone over the period, when distributions
+	// were given
+	Zones []models.ZoneBucket `json:"zones,omitempty"`
+
+	// DailyStreak and WeeklyStreak are the streaks running at the end of
+	// the period
+	DailyStreak  analytics.Streak `json:"daily_streak"`
+	WeeklyStreak analytics.Streak `json:"weekly_streak"`
+}
+
+// Totals sums a set of activities
+type Totals struct {
+	Activities    int     `json:"activities"`
+	Distance      float64 `json:"distance"`       // meters
+	MovingTime    float64 `json:"moving_time"`    // seconds
+	ElapsedTime   float64 `json:"elapsed_time"`   // seconds
+	ElevationGain float64 `json:"elevation_gain"` // meters
+}
+
+// SportSummary is the totals and highlights of one sport
+type SportSummary struct {
+	Sport    string `json:"sport"` // sport type, or activity type when there is none
+	Totals   Totals `json:"totals"`
+	Previous Totals `json:"previous"`
+
+	// Longest, Fastest and MostClimbing are the activities of the period
+	// with the greatest distance, average speed and elevation gain, or nil
+	Longest      *models.Activity `json:"longest,omitempty"`
+	Fastest      *models.Activity `json:"fastest,omitempty"`
+	MostClimbing *models.Activity `json:"most_climbing,omitempty"`
+}
+
+// Generate summarizes the activities of the period containing at, a local
+// time, by their local start dates. Activities may span any range; those
+// outside the period and the previous one only count towards the streaks.
+func Generate(activities []*models.Activity, period analytics.Period, at time.Time, opts *Options) *Report {
+	o := Options{Units: export.UnitsMetric}
+	if opts != nil {
+		o.Zones = opts.Zones
+		if opts.Units != "" {
+			o.Units = opts.Units
+		}
+	}
+
+	start := analytics.PeriodStart(at, period)
+	end := analytics.NextPeriod(start, period)
+	previous := analytics.PeriodStart(start.AddDate(0, 0, -1), period)
+	r := &Report{Period: period, Start: start, End: end, Units: o.Units}
+
+	sports := make(map[string]*SportSummary)
+	for _, a := range activities {
+		date := analytics.LocalStart(a)
+		current := !date.Before(start) && date.Before(end)
+		if !current && (date.Before(previous) || !date.Before(start)) {
+			continue
+		}
+
+		s, ok := sports[analytics.Sport(a)]
+		if !ok {
+			s = &SportSummary{Sport: analytics.Sport(a)}
+			sports[s.Sport] = s
+		}
+		if !current {
+			r.Previous.add(a)
+			s.Previous.add(a)
+			continue
+		}
+
+		r.Totals.add(a)
+		s.Totals.add(a)
+		if a.Distance > 0 && (s.Longest == nil || a.Distance > s.Longest.Distance) {
+			s.Longest = a
+		}
+		if a.Distance > 0 && (s.Fastest == nil || a.AverageSpeed > s.Fastest.AverageSpeed) {
+			s.Fastest = a
+		}
+		if a.TotalElevationGain > 0 && (s.MostClimbing == nil || a.TotalElevationGain > s.MostClimbing.TotalElevationGain) {
+			s.MostClimbing = a
+		}
+	}
+
+	for _, s := range sports {
+		r.Sports = append(r.Sports, *s)
+	}
+	sort.Slice(r.Sports, func(i, j int) bool {
+		a, b := r.Sports[i], r.Sports[j]
+		if a.Totals.MovingTime != b.Totals.MovingTime {
+			return a.Totals.MovingTime > b.Totals.MovingTime
+		}
+		return a.Sport < b.Sport
+	})
+
+	var zones []analytics.ZoneDistribution
+	for _, d := range o.Zones {
+		if !d.Date.Before(start) && d.Date.Before(end) {
+			zones = append(zones, d)
+		}
+	}
+	if totals := analytics.RollupZones(zones, period); len(totals) > 0 {
+		r.Zones = totals[0].Buckets
+	}
+
+	last := end.Add(-time.Nanosecond)
+	r.DailyStreak = analytics.CurrentStreak(activities, analytics.PeriodDay, last)
+	r.WeeklyStreak = analytics.CurrentStreak(activities, analytics.PeriodWeek, last)
+	return r
+}
+
+// UnitsFor returns the units of an athlete's measurement preference
+func UnitsFor(athlete *models.Athlete) export.Units {
+	if athlete != nil && athlete.MeasurementPreference == "feet" {
+		return export.UnitsImperial
+	}
+	return export.UnitsMetric
+}
+
+// Change returns the relative change from previous to current, such as 0.25
+// for a quarter more, or false when there was nothing to compare with
+func Change(current, previous float64) (float64, bool) {
+	if previous == 0 {
+		return 0, false
+	}
+	return (current - previous) / previous, true
+}
+
+// add adds an activity to the totals
+func (t *Totals) add(a *models.Activity) {
+	t.Activities++
+	t.Distance += a.Distance
+	t.MovingTime += a.MovingTime
+	t.ElapsedTime += a.ElapsedTime
+	t.ElevationGain += a.TotalElevationGain
+}
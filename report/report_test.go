@@ -0,0 +1,57 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/analytics"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+func TestGenerate(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 8, 0, 0, 0, time.UTC) }
+	activities := []*models.Activity{
+		{ID: 1, Type: models.ActivityTypeRun, SportType: "TrailRun", Distance: 12000, MovingTime: 4000, StartDateLocal: day(9)},
+		{ID: 2, Type: models.ActivityTypeRide, SportType: "Ride", Distance: 40000, MovingTime: 5000, TotalElevationGain: 300, StartDateLocal: day(10)},
+		// From before sport types and local start dates
+		{ID: 3, Type: models.ActivityTypeRun, Distance: 8000, MovingTime: 2400, StartDate: day(11)},
+		{ID: 4, Type: models.ActivityTypeRun, Distance: 5000, MovingTime: 1500, StartDate: day(12)},
+		// The week before
+		{ID: 5, Type: models.ActivityTypeRun, SportType: "Run", Distance: 10000, MovingTime: 3000, StartDateLocal: day(3)},
+		// Outside both weeks, ending the weekly streak
+		{ID: 6, Type: models.ActivityTypeRun, SportType: "Run", Distance: 10000, MovingTime: 3000, StartDateLocal: day(1).AddDate(0, 0, -13)},
+	}
+
+	r := Generate(activities, analytics.PeriodWeek, day(12), nil)
+
+	if !r.Start.Equal(day(6).Truncate(24*time.Hour)) || !r.End.Equal(r.Start.AddDate(0, 0, 7)) {
+		t.Errorf("period = %v to %v, want the week of 6 May", r.Start, r.End)
+	}
+	if want := (Totals{Activities: 4, Distance: 65000, MovingTime: 12900, ElevationGain: 300}); r.Totals != want {
+		t.Errorf("totals = %+v, want %+v", r.Totals, want)
+	}
+	if want := (Totals{Activities: 1, Distance: 10000, MovingTime: 3000}); r.Previous != want {
+		t.Errorf("previous = %+v, want %+v", r.Previous, want)
+	}
+
+	// Activities without a sport type are summed under their type, with
+	// those of the previous week
+	var sports []string
+	for _, s := range r.Sports {
+		sports = append(sports, s.Sport)
+	}
+	if want := []string{"Ride", "TrailRun", "Run"}; len(sports) != len(want) || sports[0] != want[0] || sports[1] != want[1] || sports[2] != want[2] {
+		t.Fatalf("sports = %v, want %v", sports, want)
+	}
+	run := r.Sports[2]
+	if run.Totals.Activities != 2 || run.Previous.Activities != 1 || run.Longest.ID != 3 {
+		t.Errorf("run = %d activities, %d previous, longest %d, want 2, 1 and 3",
+			run.Totals.Activities, run.Previous.Activities, run.Longest.ID)
+	}
+
+	// The streaks count activities by their start date when there is no
+	// local one
+	if r.DailyStreak.Length != 4 || r.WeeklyStreak.Length != 2 {
+		t.Errorf("streaks = %d days and %d weeks, want 4 and 2", r.DailyStreak.Length, r.WeeklyStreak.Length)
+	}
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 )
 
@@ -14,6 +16,22 @@ type Client interface {
 	Delete(ctx context.Context, path string) error
 }
 
+// ErrUnsupportedClient is returned when a client lacks a method a call needs,
+// such as a Client other than strava.Client without GetRaw or PostMultipart
+var ErrUnsupportedClient = errors.New("services: client does not support the request")
+
+// rawGetter is implemented by clients that can copy undecoded response
+// bodies, for file exports
+type rawGetter interface {
+	GetRaw(ctx context.Context, path string, query url.Values, w io.Writer) (string, error)
+}
+
+// multipartPoster is implemented by clients that can post multipart forms,
+// for uploads
+type multipartPoster interface {
+	PostMultipart(ctx context.Context, path string, contentType string, body io.Reader, result interface{}) error
+}
+
 // Pagination represents pagination parameters
 type Pagination struct {
 	Page   int `json:"page,omitempty"`
@@ -2,12 +2,58 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
 
 	"github.com/kpi-studio/go-strava-api/models"
 )
 
+// RouteFormat is a file format routes can be exported in
+type RouteFormat string
+
+const (
+	RouteFormatGPX RouteFormat = "gpx"
+	RouteFormatTCX RouteFormat = "tcx"
+)
+
+const (
+	// routesPerPage is the page size used to list all of an athlete's routes
+	routesPerPage = 100
+
+	// maxRouteSlugLength limits the route name part of file names
+	maxRouteSlugLength = 60
+)
+
+// ErrUnknownRouteFormat is returned for route formats other than GPX and TCX
+var ErrUnknownRouteFormat = errors.New("services: unknown route format")
+
+// ContentType returns the MIME type of files in the format
+func (f RouteFormat) ContentType() string {
+	switch f {
+	case RouteFormatGPX:
+		return "application/gpx+xml"
+	case RouteFormatTCX:
+		return "application/vnd.garmin.tcx+xml"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// RouteExportOptions contains options for saving routes to disk
+type RouteExportOptions struct {
+	// Format is the file format (default: gpx)
+	Format RouteFormat
+
+	// Overwrite replaces files that already exist instead of skipping them
+	Overwrite bool
+}
+
 // RoutesService handles route-related API calls
 type RoutesService struct {
 	client Client
@@ -29,24 +75,138 @@ func (s *RoutesService) Get(ctx context.Context, routeID int64) (*models.Route,
 
 // GetGPX exports a route as GPX
 func (s *RoutesService) GetGPX(ctx context.Context, routeID int64) (string, error) {
-	path := fmt.Sprintf("/routes/%d/export_gpx", routeID)
-
-	var result struct {
-		GPX string `json:"gpx"`
-	}
-	err := s.client.Get(ctx, path, nil, &result)
-	return result.GPX, err
+	var buf strings.Builder
+	_, err := s.Export(ctx, routeID, RouteFormatGPX, &buf)
+	return buf.String(), err
 }
 
 // GetTCX exports a route as TCX
 func (s *RoutesService) GetTCX(ctx context.Context, routeID int64) (string, error) {
-	path := fmt.Sprintf("/routes/%d/export_tcx", routeID)
+	var buf strings.Builder
+	_, err := s.Export(ctx, routeID, RouteFormatTCX, &buf)
+	return buf.String(), err
+}
 
-	var result struct {
-		TCX string `json:"tcx"`
+// Export streams a route's file in the given format to w and returns its
+// content type, as sent by Strava or the format's when there is none. When w
+// is an http.ResponseWriter, the client sets its Content-Type header to
+// Strava's before the file is written.
+func (s *RoutesService) Export(ctx context.Context, routeID int64, format RouteFormat, w io.Writer) (string, error) {
+	var path string
+	switch format {
+	case RouteFormatGPX:
+		path = fmt.Sprintf("/routes/%d/export_gpx", routeID)
+	case RouteFormatTCX:
+		path = fmt.Sprintf("/routes/%d/export_tcx", routeID)
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownRouteFormat, format)
+	}
+	getter, ok := s.client.(rawGetter)
+	if !ok {
+		return "", fmt.Errorf("%w: raw downloads", ErrUnsupportedClient)
+	}
+
+	contentType, err := getter.GetRaw(ctx, path, nil, w)
+	if err != nil {
+		return "", err
+	}
+	if contentType == "" {
+		contentType = format.ContentType()
 	}
-	err := s.client.Get(ctx, path, nil, &result)
-	return result.TCX, err
+	return contentType, nil
+}
+
+// Save exports a route to a file in dir named after its ID and name, and
+// returns the file's path. The file is written under a temporary name and
+// renamed when complete, so a failed export leaves no partial file.
+func (s *RoutesService) Save(ctx context.Context, route *models.Route, dir string, format RouteFormat) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, RouteFileName(route, format))
+
+	tmp, err := os.CreateTemp(dir, ".route-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := s.Export(ctx, route.ID, format, tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// SaveAll saves every route of an athlete to dir with Save and returns the
+// paths written. Existing files are skipped unless opts.Overwrite is set, so
+// an interrupted export can be resumed.
+func (s *RoutesService) SaveAll(ctx context.Context, athleteID int64, dir string, opts *RouteExportOptions) ([]string, error) {
+	o := RouteExportOptions{Format: RouteFormatGPX}
+	if opts != nil {
+		o.Overwrite = opts.Overwrite
+		if opts.Format != "" {
+			o.Format = opts.Format
+		}
+	}
+
+	var paths []string
+	for page := 1; ; page++ {
+		routes, err := s.ListByAthlete(ctx, athleteID, &models.Pagination{Page: page, PerPage: routesPerPage})
+		if err != nil {
+			return paths, err
+		}
+		for _, route := range routes {
+			if !o.Overwrite {
+				if _, err := os.Stat(filepath.Join(dir, RouteFileName(route, o.Format))); err == nil {
+					continue
+				}
+			}
+			path, err := s.Save(ctx, route, dir, o.Format)
+			if err != nil {
+				return paths, fmt.Errorf("route %d: %w", route.ID, err)
+			}
+			paths = append(paths, path)
+		}
+		if len(routes) < routesPerPage {
+			return paths, nil
+		}
+	}
+}
+
+// RouteFileName returns the file name Save uses for a route, such as
+// "1234-morning-loop.gpx"
+func RouteFileName(route *models.Route, format RouteFormat) string {
+	var slug strings.Builder
+	dash := false
+	length := 0
+	for _, r := range strings.ToLower(route.Name) {
+		if length >= maxRouteSlugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+				length++
+			}
+			slug.WriteRune(r)
+			length++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if slug.Len() == 0 {
+		return fmt.Sprintf("%d.%s", route.ID, format)
+	}
+	return fmt.Sprintf("%d-%s.%s", route.ID, slug.String(), format)
 }
 
 // ListByAthlete returns routes for an athlete
@@ -61,4 +221,4 @@ func (s *RoutesService) ListByAthlete(ctx context.Context, athleteID int64, pagi
 	var routes []*models.Route
 	err := s.client.Get(ctx, path, query, &routes)
 	return routes, err
-}
\ No newline at end of file
+}
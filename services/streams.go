@@ -1,12 +1,13 @@
 package services
 
 import (
-	"github.com/kpi-studio/go-strava-api/models"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
+
+	"github.com/kpi-studio/go-strava-api/models"
 )
 
 // StreamsService handles stream-related API calls
@@ -39,8 +40,12 @@ func (s *StreamsService) GetActivityStreams(ctx context.Context, activityID int6
 	}
 
 	// Get raw response first
-	var rawStreams []json.RawMessage
-	err := s.client.Get(ctx, path, query, &rawStreams)
+	var data json.RawMessage
+	err := s.client.Get(ctx, path, query, &data)
+	if err != nil {
+		return nil, err
+	}
+	rawStreams, err := decodeStreams(data)
 	if err != nil {
 		return nil, err
 	}
@@ -124,8 +129,12 @@ func (s *StreamsService) GetSegmentStreams(ctx context.Context, segmentID int64,
 	}
 
 	// Get raw response first
-	var rawStreams []json.RawMessage
-	err := s.client.Get(ctx, path, query, &rawStreams)
+	var data json.RawMessage
+	err := s.client.Get(ctx, path, query, &data)
+	if err != nil {
+		return nil, err
+	}
+	rawStreams, err := decodeStreams(data)
 	if err != nil {
 		return nil, err
 	}
@@ -177,8 +186,12 @@ func (s *StreamsService) GetSegmentEffortStreams(ctx context.Context, effortID i
 	}
 
 	// Get raw response first
-	var rawStreams []json.RawMessage
-	err := s.client.Get(ctx, path, query, &rawStreams)
+	var data json.RawMessage
+	err := s.client.Get(ctx, path, query, &data)
+	if err != nil {
+		return nil, err
+	}
+	rawStreams, err := decodeStreams(data)
 	if err != nil {
 		return nil, err
 	}
@@ -254,8 +267,12 @@ func (s *StreamsService) GetRouteStreams(ctx context.Context, routeID int64, typ
 	query.Set("key_by_type", "true")
 
 	// Get raw response first
-	var rawStreams []json.RawMessage
-	err := s.client.Get(ctx, path, query, &rawStreams)
+	var data json.RawMessage
+	err := s.client.Get(ctx, path, query, &data)
+	if err != nil {
+		return nil, err
+	}
+	rawStreams, err := decodeStreams(data)
 	if err != nil {
 		return nil, err
 	}
@@ -285,4 +302,34 @@ func (s *StreamsService) GetRouteStreams(ctx context.Context, routeID int64, typ
 	}
 
 	return streamSet, nil
-}
\ No newline at end of file
+}
+
+// decodeStreams returns the streams of a response, which is an object keyed
+// by stream type when key_by_type is set and an array otherwise. Keyed
+// streams get their type from the key.
+func decodeStreams(data json.RawMessage) ([]json.RawMessage, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+
+	var keyed map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keyed); err != nil {
+		return nil, err
+	}
+	for key, raw := range keyed {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		if _, ok := fields["type"]; !ok {
+			fields["type"], _ = json.Marshal(key)
+			var err error
+			if raw, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, raw)
+	}
+	return list, nil
+}
@@ -1,12 +1,28 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
 
 	"github.com/kpi-studio/go-strava-api/models"
 )
 
+// uploadPollInterval is how often WaitForUpload checks an upload
+const uploadPollInterval = 2 * time.Second
+
+var (
+	// ErrNoUploadFile is returned when upload options have no file
+	ErrNoUploadFile = errors.New("services: upload has no file")
+
+	// ErrUploadFailed is returned when Strava could not process an upload
+	ErrUploadFailed = errors.New("services: upload failed")
+)
+
 // UploadsService handles upload-related API calls
 type UploadsService struct {
 	client Client
@@ -26,6 +42,87 @@ func (s *UploadsService) GetUploadStatus(ctx context.Context, uploadID int64) (*
 	return &upload, err
 }
 
-// TODO: The Upload method needs to be implemented properly
-// It requires special handling for multipart form data which isn't
-// supported by the simple Client interface
+// Upload uploads an activity file. The returned upload is usually still being
+// processed; use WaitForUpload to get the created activity.
+func (s *UploadsService) Upload(ctx context.Context, opts *models.UploadOptions) (*models.Upload, error) {
+	if opts == nil || opts.File == nil {
+		return nil, ErrNoUploadFile
+	}
+	poster, ok := s.client.(multipartPoster)
+	if !ok {
+		return nil, fmt.Errorf("%w: multipart uploads", ErrUnsupportedClient)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := [][2]string{
+		{"name", opts.Name},
+		{"description", opts.Description},
+		{"activity_type", string(opts.ActivityType)},
+		{"data_type", opts.DataType},
+		{"external_id", opts.ExternalID},
+	}
+	if opts.Trainer {
+		fields = append(fields, [2]string{"trainer", "1"})
+	}
+	if opts.Commute {
+		fields = append(fields, [2]string{"commute", "1"})
+	}
+	if opts.Private {
+		fields = append(fields, [2]string{"private", "1"})
+	}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := form.WriteField(f[0], f[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	part, err := form.CreateFormFile("file", "activity."+opts.DataType)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, opts.File); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var upload models.Upload
+	err = poster.PostMultipart(ctx, "/uploads", form.FormDataContentType(), &body, &upload)
+	return &upload, err
+}
+
+// WaitForUpload polls an upload until Strava has created its activity or
+// reported an error, giving up after timeout (default: until ctx is done).
+// Processing errors, such as a duplicate upload, are returned wrapping
+// ErrUploadFailed.
+func (s *UploadsService) WaitForUpload(ctx context.Context, uploadID int64, timeout time.Duration) (*models.Upload, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for {
+		upload, err := s.GetUploadStatus(ctx, uploadID)
+		if err != nil {
+			return upload, err
+		}
+		if upload.Error != "" {
+			return upload, fmt.Errorf("%w: %s", ErrUploadFailed, upload.Error)
+		}
+		if upload.ActivityID != 0 {
+			return upload, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return upload, ctx.Err()
+		case <-time.After(uploadPollInterval):
+		}
+	}
+}
@@ -144,6 +144,17 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body inter
 
 // Do performs an API request
 func (c *Client) Do(ctx context.Context, req *http.Request, result interface{}) (*Response, error) {
+	return c.do(ctx, req, func(resp *http.Response) error {
+		// Parse response body if needed
+		if result == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(result)
+	})
+}
+
+// do performs an API request and hands successful responses to read
+func (c *Client) do(ctx context.Context, req *http.Request, read func(resp *http.Response) error) (*Response, error) {
 	// Apply rate limiting
 	if err := c.rateLimiter.Wait(ctx); err != nil {
 		return nil, err
@@ -168,14 +179,7 @@ func (c *Client) Do(ctx context.Context, req *http.Request, result interface{})
 		return response, internal.ParseError(resp)
 	}
 
-	// Parse response body if needed
-	if result != nil {
-		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
-			return response, err
-		}
-	}
-
-	return response, nil
+	return response, read(resp)
 }
 
 // Get performs a GET request
@@ -193,6 +197,32 @@ func (c *Client) Get(ctx context.Context, path string, query url.Values, result
 	return err
 }
 
+// GetRaw performs a GET request and copies the response body to w without
+// decoding it, returning the response's content type. When w is an
+// http.ResponseWriter, its Content-Type header is set to the response's
+// before the body is copied.
+func (c *Client) GetRaw(ctx context.Context, path string, query url.Values, w io.Writer) (string, error) {
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var contentType string
+	_, err = c.do(ctx, req, func(resp *http.Response) error {
+		contentType = resp.Header.Get("Content-Type")
+		if rw, ok := w.(http.ResponseWriter); ok && contentType != "" {
+			rw.Header().Set("Content-Type", contentType)
+		}
+		_, err := io.Copy(w, resp.Body)
+		return err
+	})
+	return contentType, err
+}
+
 // Post performs a POST request
 func (c *Client) Post(ctx context.Context, path string, body interface{}, result interface{}) error {
 	req, err := c.NewRequest(ctx, http.MethodPost, path, body)
@@ -204,6 +234,19 @@ func (c *Client) Post(ctx context.Context, path string, body interface{}, result
 	return err
 }
 
+// PostMultipart performs a POST request with a body already encoded as
+// multipart form data; contentType must carry the form's boundary
+func (c *Client) PostMultipart(ctx context.Context, path string, contentType string, body io.Reader, result interface{}) error {
+	req, err := c.NewRequest(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	_, err = c.Do(ctx, req, result)
+	return err
+}
+
 // Put performs a PUT request
 func (c *Client) Put(ctx context.Context, path string, body interface{}, result interface{}) error {
 	req, err := c.NewRequest(ctx, http.MethodPut, path, body)
@@ -226,22 +269,56 @@ func (c *Client) Delete(ctx context.Context, path string) error {
 	return err
 }
 
-// parseRateLimitHeaders extracts rate limit information from response headers
+// parseRateLimitHeaders extracts rate limit information from response
+// headers, which hold the 15 minute and daily values as "600,6000". When the
+// read limits are also sent, the window closer to its limit is used.
 func parseRateLimitHeaders(headers http.Header) ratelimit.RateLimitInfo {
 	info := ratelimit.RateLimitInfo{}
 
 	// Parse rate limit headers
 	if limit := headers.Get("X-RateLimit-Limit"); limit != "" {
-		fmt.Sscanf(limit, "%d", &info.Limit)
+		fmt.Sscanf(limit, "%d,%d", &info.Limit, &info.DailyLimit)
 	}
 
 	if usage := headers.Get("X-RateLimit-Usage"); usage != "" {
-		fmt.Sscanf(usage, "%d", &info.Usage)
+		fmt.Sscanf(usage, "%d,%d", &info.Usage, &info.DailyUsage)
+	}
+
+	var read ratelimit.RateLimitInfo
+	if limit := headers.Get("X-ReadRateLimit-Limit"); limit != "" {
+		fmt.Sscanf(limit, "%d,%d", &read.Limit, &read.DailyLimit)
+	}
+	if usage := headers.Get("X-ReadRateLimit-Usage"); usage != "" {
+		fmt.Sscanf(usage, "%d,%d", &read.Usage, &read.DailyUsage)
+	}
+	if read.Limit > 0 && (info.Limit == 0 || read.Limit-read.Usage < info.Limit-info.Usage) {
+		info.Limit, info.Usage = read.Limit, read.Usage
+	}
+	if read.DailyLimit > 0 && (info.DailyLimit == 0 || read.DailyLimit-read.DailyUsage < info.DailyLimit-info.DailyUsage) {
+		info.DailyLimit, info.DailyUsage = read.DailyLimit, read.DailyUsage
+	}
+
+	if info.Limit > 0 || info.DailyLimit > 0 {
+		info.Reset, info.DailyReset = ratelimit.Resets(time.Now())
 	}
 
 	return info
 }
 
+// Re-export rate limit and error types for convenience
+type (
+	RateLimiterConfig = ratelimit.RateLimiterConfig
+	RateLimitInfo     = ratelimit.RateLimitInfo
+	Error             = internal.Error
+)
+
+// Re-export error checks
+var (
+	IsRateLimitError = internal.IsRateLimitError
+	IsAuthError      = internal.IsAuthError
+	IsNotFoundError  = internal.IsNotFoundError
+)
+
 // Re-export auth types for convenience
 type (
 	OAuth2Config           = auth.OAuth2Config
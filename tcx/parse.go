@@ -0,0 +1,191 @@
+package tcx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+var (
+	// ErrNoActivity is returned when a TCX file has no activity
+	ErrNoActivity = errors.New("tcx: no activity")
+
+	// ErrInvalidTime is returned for times that are not RFC 3339
+	ErrInvalidTime = errors.New("tcx: invalid time")
+)
+
+// document is the subset of a TCX file that Parse reads
+type document struct {
+	XMLName    xml.Name `xml:"TrainingCenterDatabase"`
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		ID    string `xml:"Id"`
+		Notes string `xml:"Notes"`
+		Laps  []lap  `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// lap is a TCX lap
+type lap struct {
+	StartTime        string   `xml:"StartTime,attr"`
+	TotalTimeSeconds float64  `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64  `xml:"DistanceMeters"`
+	MaximumSpeed     float64  `xml:"MaximumSpeed"`
+	Calories         float64  `xml:"Calories"`
+	AverageHeartRate *float64 `xml:"AverageHeartRateBpm>Value"`
+	MaximumHeartRate *int     `xml:"MaximumHeartRateBpm>Value"`
+	Cadence          *float64 `xml:"Cadence"`
+	Trackpoints      []point  `xml:"Track>Trackpoint"`
+	Extensions       struct {
+		AvgSpeed      *float64 `xml:"LX>AvgSpeed"`
+		AvgRunCadence *float64 `xml:"LX>AvgRunCadence"`
+		AvgWatts      *float64 `xml:"LX>AvgWatts"`
+	} `xml:"Extensions"`
+}
+
+// point is a TCX trackpoint
+type point struct {
+	Time       string   `xml:"Time"`
+	Lat        *float64 `xml:"Position>LatitudeDegrees"`
+	Lng        *float64 `xml:"Position>LongitudeDegrees"`
+	Altitude   *float64 `xml:"AltitudeMeters"`
+	Distance   *float64 `xml:"DistanceMeters"`
+	HeartRate  *int     `xml:"HeartRateBpm>Value"`
+	Cadence    *int     `xml:"Cadence"`
+	Extensions struct {
+		Speed      *float64 `xml:"TPX>Speed"`
+		RunCadence *int     `xml:"TPX>RunCadence"`
+		Watts      *int     `xml:"TPX>Watts"`
+	} `xml:"Extensions"`
+}
+
+// Parse reads the first activity of a TCX file into an activity summary,
+// laps and streams, the reverse of Write. Lap start and end indices refer to
+// the streams. The time stream is only set when every trackpoint has a time;
+// positions missing from some trackpoints are filled from neighbouring ones
+// and other missing values are zero.
+func Parse(r io.Reader) (*models.Activity, *models.StreamSet, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("tcx: %w", err)
+	}
+	if len(doc.Activities) == 0 {
+		return nil, nil, ErrNoActivity
+	}
+	a := doc.Activities[0]
+
+	activity := &models.Activity{
+		Name: strings.TrimSpace(a.Notes),
+		Type: activityType(a.Sport),
+	}
+
+	var points []track.Point
+	for k, l := range a.Laps {
+		first := len(points)
+		for _, p := range l.Trackpoints {
+			tp, err := p.trackPoint()
+			if err != nil {
+				return nil, nil, err
+			}
+			points = append(points, tp)
+		}
+
+		lapData := models.Lap{
+			Name:        fmt.Sprintf("Lap %d", k+1),
+			LapIndex:    k + 1,
+			Split:       k + 1,
+			ElapsedTime: l.TotalTimeSeconds,
+			MovingTime:  l.TotalTimeSeconds,
+			Distance:    l.DistanceMeters,
+			MaxSpeed:    l.MaximumSpeed,
+			StartIndex:  first,
+			EndIndex:    len(points) - 1,
+		}
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(l.StartTime)); err == nil {
+			lapData.StartDate = t.UTC()
+			lapData.StartDateLocal = lapData.StartDate
+		}
+		if l.AverageHeartRate != nil {
+			lapData.AverageHeartrate = *l.AverageHeartRate
+		}
+		if l.MaximumHeartRate != nil {
+			lapData.MaxHeartrate = *l.MaximumHeartRate
+		}
+		if l.Cadence != nil {
+			lapData.AverageCadence = *l.Cadence
+		} else if l.Extensions.AvgRunCadence != nil {
+			lapData.AverageCadence = *l.Extensions.AvgRunCadence
+		}
+		if l.Extensions.AvgSpeed != nil {
+			lapData.AverageSpeed = *l.Extensions.AvgSpeed
+		} else if l.TotalTimeSeconds > 0 {
+			lapData.AverageSpeed = l.DistanceMeters / l.TotalTimeSeconds
+		}
+		if l.Extensions.AvgWatts != nil {
+			lapData.AverageWatts = *l.Extensions.AvgWatts
+			lapData.DeviceWatts = true
+		}
+		activity.Laps = append(activity.Laps, lapData)
+		activity.Calories += l.Calories
+	}
+
+	streams, start := track.Streams(points)
+	switch {
+	case streams.Time != nil:
+		activity.StartDate = time.Unix(start, 0).UTC()
+	case len(activity.Laps) > 0:
+		activity.StartDate = activity.Laps[0].StartDate
+	}
+	if activity.StartDate.IsZero() {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(a.ID)); err == nil {
+			activity.StartDate = t.UTC()
+		}
+	}
+	track.Summarize(activity, streams)
+
+	return activity, streams, nil
+}
+
+// trackPoint converts a TCX trackpoint
+func (p point) trackPoint() (track.Point, error) {
+	tp := track.Point{
+		Lat:       p.Lat,
+		Lng:       p.Lng,
+		Altitude:  p.Altitude,
+		Distance:  p.Distance,
+		Speed:     p.Extensions.Speed,
+		Heartrate: p.HeartRate,
+		Cadence:   p.Cadence,
+		Watts:     p.Extensions.Watts,
+	}
+	if tp.Cadence == nil {
+		tp.Cadence = p.Extensions.RunCadence
+	}
+	if s := strings.TrimSpace(p.Time); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return tp, fmt.Errorf("%w %q", ErrInvalidTime, s)
+		}
+		unix := t.Unix()
+		tp.Time = &unix
+	}
+	return tp, nil
+}
+
+// activityType maps a TCX sport to an activity type
+func activityType(sport string) models.ActivityType {
+	switch sport {
+	case SportRunning:
+		return models.ActivityTypeRun
+	case SportBiking:
+		return models.ActivityTypeRide
+	default:
+		return models.ActivityTypeWorkout
+	}
+}
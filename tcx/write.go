@@ -0,0 +1,238 @@
+package tcx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/internal/laps"
+	"github.com/kpi-studio/go-strava-api/internal/track"
+	"github.com/kpi-studio/go-strava-api/internal/utils"
+	"github.com/kpi-studio/go-strava-api/internal/xmlw"
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+const (
+	// Namespace is the TrainingCenterDatabase v2 namespace
+	Namespace = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+
+	// ActivityExtensionNamespace is the Garmin ActivityExtension v2 namespace
+	ActivityExtensionNamespace = "http://www.garmin.com/xmlschemas/ActivityExtension/v2"
+
+	schemaLocation = Namespace + " http://www.garmin.com/xmlschemas/TrainingCenterDatabasev2.xsd"
+)
+
+// Sports supported by TCX
+const (
+	SportRunning = "Running"
+	SportBiking  = "Biking"
+	SportOther   = "Other"
+)
+
+var (
+	// ErrNoTime is returned when the streams have no time data
+	ErrNoTime = errors.New("tcx: no time stream")
+
+	// ErrMissingStartDate is returned when the activity has no start date
+	ErrMissingStartDate = errors.New("tcx: activity has no start date")
+
+	// ErrLengthMismatch is returned when a stream is not aligned with the time stream
+	ErrLengthMismatch = track.ErrLengthMismatch
+
+	// ErrInvalidCoordinate is returned for malformed or out of range coordinates
+	ErrInvalidCoordinate = track.ErrInvalidCoordinate
+
+	// ErrTimeOrder is returned when the time stream goes backwards
+	ErrTimeOrder = track.ErrTimeOrder
+
+	// ErrLapIndex is returned when lap start indices are out of range or out of order
+	ErrLapIndex = errors.New("tcx: invalid lap start index")
+)
+
+// WriteOptions contains options for writing TCX
+type WriteOptions struct {
+	// NoLaps writes the whole activity as a single lap
+	NoLaps bool
+
+	// NoExtensions omits the speed, power and run cadence extensions
+	NoExtensions bool
+}
+
+// Sport maps an activity type to a TCX sport
+func Sport(activityType models.ActivityType) string {
+	switch {
+	case utils.IsRunActivity(activityType):
+		return SportRunning
+	case utils.IsRideActivity(activityType):
+		return SportBiking
+	default:
+		return SportOther
+	}
+}
+
+// Validate checks that the activity and streams can be written as TCX with
+// opts: a start date, a non-decreasing time stream with every other stream
+// aligned to it, valid coordinates and, unless opts.NoLaps is set, lap start
+// indices within the streams
+func Validate(activity *models.Activity, streams *models.StreamSet, opts *WriteOptions) error {
+	if activity == nil || activity.StartDate.IsZero() {
+		return ErrMissingStartDate
+	}
+	if streams == nil || streams.Time == nil || len(streams.Time.Data) == 0 {
+		return ErrNoTime
+	}
+
+	n := len(streams.Time.Data)
+	if err := track.CheckTimes(streams.Time.Data); err != nil {
+		return err
+	}
+	if err := track.CheckLengths(streams, n); err != nil {
+		return err
+	}
+	if streams.LatLng != nil {
+		if err := track.CheckCoordinates(streams.LatLng.Data); err != nil {
+			return err
+		}
+	}
+
+	if opts != nil && opts.NoLaps {
+		return nil
+	}
+	prev := -1
+	for i, lap := range activity.Laps {
+		if lap.StartIndex < 0 || lap.StartIndex >= n || lap.StartIndex <= prev {
+			return fmt.Errorf("%w %d for lap %d", ErrLapIndex, lap.StartIndex, i+1)
+		}
+		prev = lap.StartIndex
+	}
+
+	return nil
+}
+
+// Write validates the activity and writes it to w as a TCX activity, one
+// point at a time. Each of the activity's laps becomes a TCX lap running from
+// its start index to the next lap's; lap totals are Strava's where reported
+// and are otherwise computed from the streams. Speed, power and run cadence
+// use the ActivityExtension v2.
+func Write(w io.Writer, activity *models.Activity, streams *models.StreamSet, opts *WriteOptions) error {
+	if err := Validate(activity, streams, opts); err != nil {
+		return err
+	}
+
+	var o WriteOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	sport := Sport(activity.Type)
+	start := activity.StartDate.UTC()
+
+	ew := xmlw.NewWriter(w)
+	ew.Printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	ew.Printf("<TrainingCenterDatabase xmlns=\"%s\" xmlns:ns3=\"%s\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:schemaLocation=\"%s\">\n",
+		Namespace, ActivityExtensionNamespace, schemaLocation)
+	ew.Printf(" <Activities>\n")
+	ew.Printf("  <Activity Sport=\"%s\">\n", sport)
+	ew.Printf("   <Id>%s</Id>\n", start.Format(time.RFC3339))
+
+	for _, lap := range laps.Split(activity, streams, o.NoLaps) {
+		ew.Printf("   <Lap StartTime=\"%s\">\n", start.Add(time.Duration(streams.Time.Data[lap.First])*time.Second).Format(time.RFC3339))
+		ew.Printf("    <TotalTimeSeconds>%s</TotalTimeSeconds>\n", xmlw.Float(lap.ElapsedTime))
+		ew.Printf("    <DistanceMeters>%s</DistanceMeters>\n", xmlw.Float(lap.Distance))
+		if lap.MaxSpeed > 0 {
+			ew.Printf("    <MaximumSpeed>%s</MaximumSpeed>\n", xmlw.Float(lap.MaxSpeed))
+		}
+		ew.Printf("    <Calories>%d</Calories>\n", int(math.Round(lap.Calories)))
+		if lap.AvgHeartrate > 0 {
+			ew.Printf("    <AverageHeartRateBpm><Value>%d</Value></AverageHeartRateBpm>\n", int(math.Round(lap.AvgHeartrate)))
+		}
+		if lap.MaxHeartrate > 0 {
+			ew.Printf("    <MaximumHeartRateBpm><Value>%d</Value></MaximumHeartRateBpm>\n", lap.MaxHeartrate)
+		}
+		ew.Printf("    <Intensity>Active</Intensity>\n")
+		if sport == SportBiking && lap.AvgCadence > 0 {
+			ew.Printf("    <Cadence>%d</Cadence>\n", int(math.Round(lap.AvgCadence)))
+		}
+		ew.Printf("    <TriggerMethod>Manual</TriggerMethod>\n")
+
+		ew.Printf("    <Track>\n")
+		for i := lap.First; i <= lap.Last; i++ {
+			writeTrackpoint(ew, streams, start, sport, i, o.NoExtensions)
+		}
+		ew.Printf("    </Track>\n")
+
+		if !o.NoExtensions && (lap.AvgSpeed > 0 || lap.AvgWatts > 0 || lap.MaxWatts > 0) {
+			ew.Printf("    <Extensions>\n")
+			ew.Printf("     <ns3:LX>\n")
+			// The schema requires AvgSpeed, AvgRunCadence, AvgWatts, MaxWatts in this order
+			if lap.AvgSpeed > 0 {
+				ew.Printf("      <ns3:AvgSpeed>%s</ns3:AvgSpeed>\n", xmlw.Float(lap.AvgSpeed))
+			}
+			if sport == SportRunning && lap.AvgCadence > 0 {
+				ew.Printf("      <ns3:AvgRunCadence>%d</ns3:AvgRunCadence>\n", int(math.Round(lap.AvgCadence)))
+			}
+			if lap.AvgWatts > 0 {
+				ew.Printf("      <ns3:AvgWatts>%d</ns3:AvgWatts>\n", int(math.Round(lap.AvgWatts)))
+			}
+			if lap.MaxWatts > 0 {
+				ew.Printf("      <ns3:MaxWatts>%d</ns3:MaxWatts>\n", lap.MaxWatts)
+			}
+			ew.Printf("     </ns3:LX>\n")
+			ew.Printf("    </Extensions>\n")
+		}
+		ew.Printf("   </Lap>\n")
+	}
+
+	if activity.Name != "" {
+		ew.Printf("   <Notes>%s</Notes>\n", xmlw.Escape(activity.Name))
+	}
+	ew.Printf("  </Activity>\n")
+	ew.Printf(" </Activities>\n")
+	ew.Printf("</TrainingCenterDatabase>\n")
+
+	return ew.Flush()
+}
+
+// writeTrackpoint writes the trackpoint at index i
+func writeTrackpoint(ew *xmlw.Writer, streams *models.StreamSet, start time.Time, sport string, i int, noExtensions bool) {
+	ew.Printf("     <Trackpoint>\n")
+	ew.Printf("      <Time>%s</Time>\n", start.Add(time.Duration(streams.Time.Data[i])*time.Second).Format(time.RFC3339))
+	if streams.LatLng != nil {
+		c := streams.LatLng.Data[i]
+		ew.Printf("      <Position><LatitudeDegrees>%s</LatitudeDegrees><LongitudeDegrees>%s</LongitudeDegrees></Position>\n",
+			xmlw.Float(c[0]), xmlw.Float(c[1]))
+	}
+	if streams.Altitude != nil {
+		ew.Printf("      <AltitudeMeters>%s</AltitudeMeters>\n", xmlw.Float(streams.Altitude.Data[i]))
+	}
+	if streams.Distance != nil {
+		ew.Printf("      <DistanceMeters>%s</DistanceMeters>\n", xmlw.Float(streams.Distance.Data[i]))
+	}
+	if streams.Heartrate != nil {
+		ew.Printf("      <HeartRateBpm><Value>%d</Value></HeartRateBpm>\n", streams.Heartrate.Data[i])
+	}
+	// Running cadence goes in the RunCadence extension instead
+	if streams.Cadence != nil && sport != SportRunning {
+		ew.Printf("      <Cadence>%d</Cadence>\n", streams.Cadence.Data[i])
+	}
+
+	runCadence := streams.Cadence != nil && sport == SportRunning
+	if !noExtensions && (streams.VelocitySmooth != nil || streams.Watts != nil || runCadence) {
+		ew.Printf("      <Extensions>\n")
+		ew.Printf("       <ns3:TPX>\n")
+		if streams.VelocitySmooth != nil {
+			ew.Printf("        <ns3:Speed>%s</ns3:Speed>\n", xmlw.Float(streams.VelocitySmooth.Data[i]))
+		}
+		if runCadence {
+			ew.Printf("        <ns3:RunCadence>%d</ns3:RunCadence>\n", streams.Cadence.Data[i])
+		}
+		if streams.Watts != nil {
+			ew.Printf("        <ns3:Watts>%d</ns3:Watts>\n", streams.Watts.Data[i])
+		}
+		ew.Printf("       </ns3:TPX>\n")
+		ew.Printf("      </Extensions>\n")
+	}
+	ew.Printf("     </Trackpoint>\n")
+}
@@ -0,0 +1,115 @@
+package tcx
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kpi-studio/go-strava-api/models"
+)
+
+// sample returns a short two-lap ride with every stream TCX can hold
+func sample() (*models.Activity, *models.StreamSet) {
+	activity := &models.Activity{
+		Type:      models.ActivityTypeRide,
+		StartDate: time.Date(2024, 5, 4, 7, 30, 0, 0, time.UTC),
+		Laps:      []models.Lap{{StartIndex: 0}, {StartIndex: 2}},
+	}
+	streams := &models.StreamSet{
+		Time:           &models.TimeStream{Data: []int{0, 1, 2, 4}},
+		LatLng:         &models.LatLngStream{Data: [][]float64{{51.5, -0.1}, {51.5001, -0.1001}, {51.5002, -0.1002}, {51.5004, -0.1004}}},
+		Distance:       &models.DistanceStream{Data: []float64{0, 13.2, 26.4, 52.8}},
+		Altitude:       &models.AltitudeStream{Data: []float64{12.2, 12.4, 13, 12.8}},
+		VelocitySmooth: &models.VelocityStream{Data: []float64{0, 13.2, 13.2, 13.2}},
+		Heartrate:      &models.HeartrateStream{Data: []int{110, 115, 121, 130}},
+		Cadence:        &models.CadenceStream{Data: []int{80, 85, 90, 88}},
+		Watts:          &models.PowerStream{Data: []int{150, 220, 310, 280}},
+	}
+	return activity, streams
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	activity, streams := sample()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, activity, streams, nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, gotStreams, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if got.Type != activity.Type || !got.StartDate.Equal(activity.StartDate) {
+		t.Errorf("activity = %q %v, want %q %v", got.Type, got.StartDate, activity.Type, activity.StartDate)
+	}
+	if len(got.Laps) != 2 || got.Laps[1].StartIndex != 2 || got.Laps[1].EndIndex != 3 {
+		t.Errorf("laps = %+v, want two laps with the second covering 2 to 3", got.Laps)
+	}
+	if !slices.EqualFunc(gotStreams.LatLng.Data, streams.LatLng.Data, slices.Equal) {
+		t.Errorf("latlng = %v, want %v", gotStreams.LatLng.Data, streams.LatLng.Data)
+	}
+	for name, floats := range map[string][2][]float64{
+		"distance":        {streams.Distance.Data, gotStreams.Distance.Data},
+		"altitude":        {streams.Altitude.Data, gotStreams.Altitude.Data},
+		"velocity_smooth": {streams.VelocitySmooth.Data, gotStreams.VelocitySmooth.Data},
+	} {
+		if !slices.Equal(floats[0], floats[1]) {
+			t.Errorf("%s = %v, want %v", name, floats[1], floats[0])
+		}
+	}
+	for name, ints := range map[string][2][]int{
+		"time":      {streams.Time.Data, gotStreams.Time.Data},
+		"heartrate": {streams.Heartrate.Data, gotStreams.Heartrate.Data},
+		"cadence":   {streams.Cadence.Data, gotStreams.Cadence.Data},
+		"watts":     {streams.Watts.Data, gotStreams.Watts.Data},
+	} {
+		if !slices.Equal(ints[0], ints[1]) {
+			t.Errorf("%s = %v, want %v", name, ints[1], ints[0])
+		}
+	}
+}
+
+func TestWriteNoLaps(t *testing.T) {
+	activity, streams := sample()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, activity, streams, &WriteOptions{NoLaps: true}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, _, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got.Laps) != 1 || got.Laps[0].EndIndex != 3 {
+		t.Errorf("laps = %+v, want one lap covering every point", got.Laps)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *models.Activity, s *models.StreamSet)
+		opts   *WriteOptions
+		want   error
+	}{
+		{"valid", func(a *models.Activity, s *models.StreamSet) {}, nil, nil},
+		{"no time", func(a *models.Activity, s *models.StreamSet) { s.Time = nil }, nil, ErrNoTime},
+		{"short distance", func(a *models.Activity, s *models.StreamSet) { s.Distance.Data = s.Distance.Data[:3] }, nil, ErrLengthMismatch},
+		{"invalid coordinate", func(a *models.Activity, s *models.StreamSet) { s.LatLng.Data[1] = []float64{0, 181} }, nil, ErrInvalidCoordinate},
+		{"time goes backwards", func(a *models.Activity, s *models.StreamSet) { s.Time.Data[2] = 0 }, nil, ErrTimeOrder},
+		{"lap out of range", func(a *models.Activity, s *models.StreamSet) { a.Laps[1].StartIndex = 4 }, nil, ErrLapIndex},
+		{"lap out of range without laps", func(a *models.Activity, s *models.StreamSet) { a.Laps[1].StartIndex = 4 }, &WriteOptions{NoLaps: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activity, streams := sample()
+			tt.modify(activity, streams)
+			if err := Validate(activity, streams, tt.opts); !errors.Is(err, tt.want) {
+				t.Errorf("Validate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}